@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"KubeDiskGuard/pkg/api"
 	"KubeDiskGuard/pkg/config"
 	"KubeDiskGuard/pkg/service"
+	"KubeDiskGuard/pkg/webhook"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -43,9 +48,10 @@ func main() {
 	})
 
 	// 启动HTTP服务器
+	httpServer := &http.Server{Addr: *metricsAddr, Handler: router}
 	go func() {
 		log.Printf("[INFO] HTTP server listening on %s", *metricsAddr)
-		if err := http.ListenAndServe(*metricsAddr, router); err != nil {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("[FATAL] HTTP server error: %v", err)
 		}
 	}()
@@ -59,6 +65,17 @@ func main() {
 	// 打印配置
 	log.Printf("Configuration: %s", cfg.ToJSON())
 
+	// webhook 模式只校验Pod注解，不创建限速服务
+	if cfg.WebhookMode {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+		if err := webhook.Run(ctx, cfg); err != nil {
+			log.Fatalf("Validating webhook failed: %v", err)
+		}
+		log.Println("KubeDiskGuard webhook stopped")
+		return
+	}
+
 	// 创建并运行服务
 	svc, err := service.NewKubeDiskGuardService(cfg)
 	if err != nil {
@@ -67,6 +84,11 @@ func main() {
 
 	// 创建并注册 API 服务器
 	apiServer := api.NewAPIServer(svc.GetSmartLimitManager())
+	apiServer.SetAuditRecorder(svc.GetAuditRecorder())
+	apiServer.SetEffectiveLimitsProvider(svc)
+	apiServer.SetFilterDecisionProvider(svc)
+	apiServer.SetScheduleStatusProvider(svc)
+	apiServer.SetNodeBudgetProvider(svc)
 	apiServer.RegisterRoutes(router)
 	log.Printf("[INFO] API routes registered")
 
@@ -78,8 +100,28 @@ func main() {
 		os.Exit(0)
 	}
 
+	// 收到 SIGTERM/SIGINT 后取消 ctx，排空进行中的工作后按退出策略处理限速
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
 	// 运行服务
-	if err := svc.Run(); err != nil {
-		log.Fatalf("Service failed: %v", err)
+	runErr := svc.Run(ctx)
+	if runErr != nil {
+		log.Printf("Service failed: %v", runErr)
+	}
+	stop()
+
+	log.Printf("Shutting down (on-exit policy: %s)...", cfg.OnExitPolicy)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := svc.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Service shutdown error: %v", err)
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+	if runErr != nil {
+		os.Exit(1)
 	}
+	log.Println("KubeDiskGuard stopped")
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -16,6 +17,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestDetectRuntime(t *testing.T) {
@@ -161,8 +163,20 @@ func (m *mockKubeClient) WatchPods() (watch.Interface, error) {
 	return watch.NewFake(), nil
 }
 
-func (m *mockKubeClient) WatchNodePods() (watch.Interface, error) {
-	return watch.NewFake(), nil
+func (m *mockKubeClient) NodePodListWatcher() (cache.ListerWatcher, error) {
+	return nil, fmt.Errorf("not supported in mock")
+}
+
+func (m *mockKubeClient) NamespaceListWatcher() (cache.ListerWatcher, error) {
+	return nil, fmt.Errorf("not supported in mock")
+}
+
+func (m *mockKubeClient) GetClaimStorage(namespace, claimName string) (*kubeclient.ClaimStorage, error) {
+	return nil, fmt.Errorf("not supported in mock")
+}
+
+func (m *mockKubeClient) PatchNodeCapacity(capacity corev1.ResourceList) error {
+	return nil
 }
 
 func (m *mockKubeClient) GetNodeSummary() (*kubeclient.NodeSummary, error) {
@@ -9,6 +9,12 @@ const (
 	BpsAnnotationKey       = "bps"
 	ReadBpsAnnotationKey   = "read-bps"
 	WriteBpsAnnotationKey  = "write-bps"
+	// 引用命名限速配置，如 kubediskguard.io/profile: batch
+	ProfileAnnotationKey = "profile"
+	// 按时间窗口切换限速的时间表（JSON），如 kubediskguard.io/schedule: {"timezone": "Asia/Shanghai", "windows": [...]}
+	ScheduleAnnotationKey = "schedule"
+	// 节点IO预算分配权重，如 kubediskguard.io/budget-weight: "2"，默认1
+	BudgetWeightAnnotationKey = "budget-weight"
 	// Legacy nvme annotation keys
 	LegacyIopsAnnotationKey      = "nvme-iops"
 	LegacyReadIopsAnnotationKey  = "nvme-iops-read"
@@ -17,3 +23,31 @@ const (
 	LegacyReadBpsAnnotationKey   = "nvme-bps-read"
 	LegacyWriteBpsAnnotationKey  = "nvme-bps-write"
 )
+
+// 容器级注解，格式为 <prefix>/container.<容器名>.<key>，如 kubediskguard.io/container.app.write-iops
+const (
+	ContainerAnnotationKeyPrefix = "container."
+	ExcludeAnnotationKey         = "exclude"
+	// 智能限速写入的状态注解
+	TriggeredByAnnotationKey   = "triggered-by"
+	TriggerReasonAnnotationKey = "trigger-reason"
+	LimitRemovedAnnotationKey  = "limit-removed"
+	RemovedAtAnnotationKey     = "removed-at"
+	RemovedReasonAnnotationKey = "removed-reason"
+	// 准入时注入限速注解的来源，如 global,class:best-effort,namespace:batch
+	InjectedFromAnnotationKey = "injected-from"
+)
+
+// 卷级注解，格式为 <prefix>/volume.<卷名>.<key>，如 kubediskguard.io/volume.data.write-iops，
+// 覆盖从PVC存储类参数得到的该卷所在设备的限速
+const VolumeAnnotationKeyPrefix = "volume."
+
+// ContainerKey 返回容器级注解key（不含前缀），如 ContainerKey("app", WriteIopsAnnotationKey) = "container.app.write-iops"
+func ContainerKey(containerName, key string) string {
+	return ContainerAnnotationKeyPrefix + containerName + "." + key
+}
+
+// VolumeKey 返回卷级注解key（不含前缀），如 VolumeKey("data", WriteIopsAnnotationKey) = "volume.data.write-iops"
+func VolumeKey(volumeName, key string) string {
+	return VolumeAnnotationKeyPrefix + volumeName + "." + key
+}
@@ -2,41 +2,52 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"KubeDiskGuard/pkg/audit"
+	"KubeDiskGuard/pkg/budget"
+	"KubeDiskGuard/pkg/filter"
+	"KubeDiskGuard/pkg/limits"
+	"KubeDiskGuard/pkg/schedule"
 	"KubeDiskGuard/pkg/smartlimit"
 )
 
 // ContainerMetricsResponse 容器指标响应
 type ContainerMetricsResponse struct {
-	ContainerID string                    `json:"container_id"`
-	PodName     string                    `json:"pod_name"`
-	Namespace   string                    `json:"namespace"`
-	LastUpdate  time.Time                 `json:"last_update"`
-	Trend       *smartlimit.IOTrend       `json:"trend,omitempty"`
-	History     []ContainerIOStatsHistory `json:"history,omitempty"`
+	ContainerID   string                    `json:"container_id"`
+	ContainerName string                    `json:"container_name,omitempty"`
+	PodName       string                    `json:"pod_name"`
+	Namespace     string                    `json:"namespace"`
+	LastUpdate    time.Time                 `json:"last_update"`
+	Trend         *smartlimit.IOTrend       `json:"trend,omitempty"`
+	History       []ContainerIOStatsHistory `json:"history,omitempty"`
 }
 
 // ContainerIOStatsHistory IO 统计历史
 type ContainerIOStatsHistory struct {
-	Timestamp time.Time `json:"timestamp"`
-	ReadIOPS  int64     `json:"read_iops"`
-	WriteIOPS int64     `json:"write_iops"`
-	ReadBPS   int64     `json:"read_bps"`
-	WriteBPS  int64     `json:"write_bps"`
+	Timestamp    time.Time `json:"timestamp"`
+	ReadIOPS     int64     `json:"read_iops"`
+	WriteIOPS    int64     `json:"write_iops"`
+	ReadBPS      int64     `json:"read_bps"`
+	WriteBPS     int64     `json:"write_bps"`
+	ReadLatency  int64     `json:"read_latency_us"` // 采集间隔内的平均读取延迟（微秒），数据源不提供时为0
+	WriteLatency int64     `json:"write_latency_us"`
 }
 
 // ContainerLimitStatusResponse 容器限速状态响应
 type ContainerLimitStatusResponse struct {
-	ContainerID string                   `json:"container_id"`
-	PodName     string                   `json:"pod_name"`
-	Namespace   string                   `json:"namespace"`
-	IsLimited   bool                     `json:"is_limited"`
-	TriggeredBy string                   `json:"triggered_by,omitempty"`
-	LimitResult *smartlimit.LimitResult  `json:"limit_result,omitempty"`
-	AppliedAt   *time.Time               `json:"applied_at,omitempty"`
-	LastCheckAt *time.Time               `json:"last_check_at,omitempty"`
+	ContainerID    string                  `json:"container_id"`
+	ContainerName  string                  `json:"container_name,omitempty"`
+	PodName        string                  `json:"pod_name"`
+	Namespace      string                  `json:"namespace"`
+	IsLimited      bool                    `json:"is_limited"`
+	TriggeredBy    string                  `json:"triggered_by,omitempty"`
+	LimitResult    *smartlimit.LimitResult `json:"limit_result,omitempty"`
+	AppliedAt      *time.Time              `json:"applied_at,omitempty"`
+	LastCheckAt    *time.Time              `json:"last_check_at,omitempty"`
+	ScheduleWindow *schedule.Status        `json:"schedule_window,omitempty"` // 按时间表限速时当前所处的窗口
 }
 
 // APIResponse 通用 API 响应
@@ -82,10 +93,11 @@ func (s *APIServer) handleGetAllContainerMetrics(w http.ResponseWriter, r *http.
 		}
 
 		response := ContainerMetricsResponse{
-			ContainerID: containerID,
-			PodName:     history.PodName,
-			Namespace:   history.Namespace,
-			LastUpdate:  history.LastUpdate,
+			ContainerID:   containerID,
+			ContainerName: history.ContainerName,
+			PodName:       history.PodName,
+			Namespace:     history.Namespace,
+			LastUpdate:    history.LastUpdate,
 		}
 
 		if includeTrend {
@@ -98,11 +110,13 @@ func (s *APIServer) handleGetAllContainerMetrics(w http.ResponseWriter, r *http.
 			// 转换历史数据格式
 			for _, stat := range history.Stats {
 				response.History = append(response.History, ContainerIOStatsHistory{
-					Timestamp: stat.Timestamp,
-					ReadIOPS:  stat.ReadIOPS,
-					WriteIOPS: stat.WriteIOPS,
-					ReadBPS:   stat.ReadBPS,
-					WriteBPS:  stat.WriteBPS,
+					Timestamp:    stat.Timestamp,
+					ReadIOPS:     stat.ReadIOPS,
+					WriteIOPS:    stat.WriteIOPS,
+					ReadBPS:      stat.ReadBPS,
+					WriteBPS:     stat.WriteBPS,
+					ReadLatency:  stat.ReadLatency,
+					WriteLatency: stat.WriteLatency,
 				})
 			}
 		}
@@ -138,10 +152,11 @@ func (s *APIServer) handleGetContainerMetrics(w http.ResponseWriter, r *http.Req
 	}
 
 	response := ContainerMetricsResponse{
-		ContainerID: containerID,
-		PodName:     history.PodName,
-		Namespace:   history.Namespace,
-		LastUpdate:  history.LastUpdate,
+		ContainerID:   containerID,
+		ContainerName: history.ContainerName,
+		PodName:       history.PodName,
+		Namespace:     history.Namespace,
+		LastUpdate:    history.LastUpdate,
 	}
 
 	if includeTrend {
@@ -156,11 +171,13 @@ func (s *APIServer) handleGetContainerMetrics(w http.ResponseWriter, r *http.Req
 		// 转换历史数据格式
 		for _, stat := range history.Stats {
 			response.History = append(response.History, ContainerIOStatsHistory{
-				Timestamp: stat.Timestamp,
-				ReadIOPS:  stat.ReadIOPS,
-				WriteIOPS: stat.WriteIOPS,
-				ReadBPS:   stat.ReadBPS,
-				WriteBPS:  stat.WriteBPS,
+				Timestamp:    stat.Timestamp,
+				ReadIOPS:     stat.ReadIOPS,
+				WriteIOPS:    stat.WriteIOPS,
+				ReadBPS:      stat.ReadBPS,
+				WriteBPS:     stat.WriteBPS,
+				ReadLatency:  stat.ReadLatency,
+				WriteLatency: stat.WriteLatency,
 			})
 		}
 	}
@@ -232,7 +249,11 @@ func (s *APIServer) handleGetAllLimitStatus(w http.ResponseWriter, r *http.Reque
 	onlyLimited := params["only_limited"] == "true"
 
 	// 获取所有容器的限速状态
-	allLimitStatus := s.smartLimitManager.GetAllLimitStatus()
+	var allLimitStatus map[string]*smartlimit.LimitStatus
+	if s.smartLimitManager != nil {
+		allLimitStatus = s.smartLimitManager.GetAllLimitStatus()
+	}
+	windows := s.scheduleWindows()
 
 	var statuses []ContainerLimitStatusResponse
 	count := 0
@@ -254,13 +275,16 @@ func (s *APIServer) handleGetAllLimitStatus(w http.ResponseWriter, r *http.Reque
 		}
 
 		status := ContainerLimitStatusResponse{
-			ContainerID: containerID,
-			PodName:     limitStatus.PodName,
-			Namespace:   limitStatus.Namespace,
-			IsLimited:   limitStatus.IsLimited,
-			TriggeredBy: limitStatus.TriggeredBy,
-			LimitResult: limitStatus.LimitResult,
+			ContainerID:    containerID,
+			ContainerName:  limitStatus.ContainerName,
+			PodName:        limitStatus.PodName,
+			Namespace:      limitStatus.Namespace,
+			IsLimited:      limitStatus.IsLimited,
+			TriggeredBy:    limitStatus.TriggeredBy,
+			LimitResult:    limitStatus.LimitResult,
+			ScheduleWindow: windows[containerID],
 		}
+		delete(windows, containerID)
 
 		if !limitStatus.AppliedAt.IsZero() {
 			status.AppliedAt = &limitStatus.AppliedAt
@@ -273,6 +297,27 @@ func (s *APIServer) handleGetAllLimitStatus(w http.ResponseWriter, r *http.Reque
 		count++
 	}
 
+	// 只按时间表限速（未被智能限速跟踪）的容器
+	for containerID, window := range windows {
+		if count >= limit || onlyLimited {
+			break
+		}
+		if namespace != "" && window.Namespace != namespace {
+			continue
+		}
+		if podName != "" && !strings.Contains(window.PodName, podName) {
+			continue
+		}
+		statuses = append(statuses, ContainerLimitStatusResponse{
+			ContainerID:    containerID,
+			ContainerName:  window.ContainerName,
+			PodName:        window.PodName,
+			Namespace:      window.Namespace,
+			ScheduleWindow: window,
+		})
+		count++
+	}
+
 	s.writeJSONResponse(w, APIResponse{
 		Success: true,
 		Data:    statuses,
@@ -280,6 +325,19 @@ func (s *APIServer) handleGetAllLimitStatus(w http.ResponseWriter, r *http.Reque
 	}, http.StatusOK)
 }
 
+// scheduleWindows 返回按时间表限速的容器当前所处窗口，containerID -> 状态
+func (s *APIServer) scheduleWindows() map[string]*schedule.Status {
+	windows := make(map[string]*schedule.Status)
+	if s.scheduleProvider == nil {
+		return windows
+	}
+	for _, status := range s.scheduleProvider.ScheduleStatuses() {
+		status := status
+		windows[status.ContainerID] = &status
+	}
+	return windows
+}
+
 // handleGetContainerLimitStatus 获取单个容器的限速状态
 func (s *APIServer) handleGetContainerLimitStatus(w http.ResponseWriter, r *http.Request) {
 	containerID := s.getContainerIDFromPath(r)
@@ -289,19 +347,39 @@ func (s *APIServer) handleGetContainerLimitStatus(w http.ResponseWriter, r *http
 	}
 
 	// 获取容器限速状态
-	limitStatus, exists := s.smartLimitManager.GetContainerLimitStatus(containerID)
+	window := s.scheduleWindows()[containerID]
+	var limitStatus *smartlimit.LimitStatus
+	exists := false
+	if s.smartLimitManager != nil {
+		limitStatus, exists = s.smartLimitManager.GetContainerLimitStatus(containerID)
+	}
 	if !exists {
-		s.writeErrorResponse(w, "Container not found", http.StatusNotFound)
+		if window == nil {
+			s.writeErrorResponse(w, "Container not found", http.StatusNotFound)
+			return
+		}
+		s.writeJSONResponse(w, APIResponse{
+			Success: true,
+			Data: ContainerLimitStatusResponse{
+				ContainerID:    containerID,
+				ContainerName:  window.ContainerName,
+				PodName:        window.PodName,
+				Namespace:      window.Namespace,
+				ScheduleWindow: window,
+			},
+		}, http.StatusOK)
 		return
 	}
 
 	status := ContainerLimitStatusResponse{
-		ContainerID: containerID,
-		PodName:     limitStatus.PodName,
-		Namespace:   limitStatus.Namespace,
-		IsLimited:   limitStatus.IsLimited,
-		TriggeredBy: limitStatus.TriggeredBy,
-		LimitResult: limitStatus.LimitResult,
+		ContainerID:    containerID,
+		ContainerName:  limitStatus.ContainerName,
+		PodName:        limitStatus.PodName,
+		Namespace:      limitStatus.Namespace,
+		IsLimited:      limitStatus.IsLimited,
+		TriggeredBy:    limitStatus.TriggeredBy,
+		LimitResult:    limitStatus.LimitResult,
+		ScheduleWindow: window,
 	}
 
 	if !limitStatus.AppliedAt.IsZero() {
@@ -317,6 +395,145 @@ func (s *APIServer) handleGetContainerLimitStatus(w http.ResponseWriter, r *http
 	}, http.StatusOK)
 }
 
+// handleGetEffectiveLimits 获取各容器的生效限速及其优先级链
+// 支持 namespace、pod、container 过滤
+func (s *APIServer) handleGetEffectiveLimits(w http.ResponseWriter, r *http.Request) {
+	if s.limitsProvider == nil {
+		s.writeErrorResponse(w, "Effective limits are not available", http.StatusServiceUnavailable)
+		return
+	}
+	params := s.parseQueryParams(r)
+	containerName := params["container"]
+
+	resolutions := make([]limits.Resolution, 0)
+	for _, res := range s.limitsProvider.EffectiveLimits(params["namespace"], params["pod"]) {
+		if containerName != "" && res.ContainerName != containerName {
+			continue
+		}
+		resolutions = append(resolutions, res)
+	}
+
+	s.writeJSONResponse(w, APIResponse{
+		Success: true,
+		Data:    resolutions,
+		Count:   len(resolutions),
+	}, http.StatusOK)
+}
+
+// handleGetNodeBudget 获取节点IO预算及各Pod的分配结果
+// 支持 namespace 过滤
+func (s *APIServer) handleGetNodeBudget(w http.ResponseWriter, r *http.Request) {
+	if s.budgetProvider == nil {
+		s.writeErrorResponse(w, "Node budget is not available", http.StatusServiceUnavailable)
+		return
+	}
+	namespace := s.parseQueryParams(r)["namespace"]
+
+	status := s.budgetProvider.NodeBudget()
+	if namespace != "" {
+		pods := make([]budget.PodAllocation, 0, len(status.Pods))
+		for _, p := range status.Pods {
+			if p.Namespace == namespace {
+				pods = append(pods, p)
+			}
+		}
+		status.Pods = pods
+	}
+
+	s.writeJSONResponse(w, APIResponse{
+		Success: true,
+		Data:    status,
+		Count:   len(status.Pods),
+	}, http.StatusOK)
+}
+
+// handleGetFilterDecisions 获取各容器的过滤结果及命中的规则
+// 支持 namespace、pod、container、action 过滤
+func (s *APIServer) handleGetFilterDecisions(w http.ResponseWriter, r *http.Request) {
+	if s.filterProvider == nil {
+		s.writeErrorResponse(w, "Filter decisions are not available", http.StatusServiceUnavailable)
+		return
+	}
+	params := s.parseQueryParams(r)
+	containerName, action := params["container"], params["action"]
+
+	decisions := make([]filter.Decision, 0)
+	for _, d := range s.filterProvider.FilterDecisions(params["namespace"], params["pod"]) {
+		if containerName != "" && d.ContainerName != containerName {
+			continue
+		}
+		if action != "" && d.Action != action {
+			continue
+		}
+		decisions = append(decisions, d)
+	}
+
+	s.writeJSONResponse(w, APIResponse{
+		Success: true,
+		Data:    decisions,
+		Count:   len(decisions),
+	}, http.StatusOK)
+}
+
+// handleGetBaselines 获取异常检测使用的容器IO基线（均值、标准差、当前速率及超出基线的起始时间）
+// 支持 namespace、pod、container 过滤
+func (s *APIServer) handleGetBaselines(w http.ResponseWriter, r *http.Request) {
+	if s.smartLimitManager == nil {
+		s.writeErrorResponse(w, "Smart limit is not available", http.StatusServiceUnavailable)
+		return
+	}
+	params := s.parseQueryParams(r)
+	namespace, podName, containerName := params["namespace"], params["pod"], params["container"]
+
+	baselines := make([]*smartlimit.ContainerBaseline, 0)
+	for _, b := range s.smartLimitManager.GetAllBaselines() {
+		if namespace != "" && b.Namespace != namespace {
+			continue
+		}
+		if podName != "" && !strings.Contains(b.PodName, podName) {
+			continue
+		}
+		if containerName != "" && b.ContainerName != containerName {
+			continue
+		}
+		baselines = append(baselines, b)
+	}
+
+	s.writeJSONResponse(w, APIResponse{
+		Success: true,
+		Data:    baselines,
+		Count:   len(baselines),
+	}, http.StatusOK)
+}
+
+// handleGetAuditRecords 获取限速动作审计记录（按时间倒序）
+// 支持 source、action、namespace、pod、dry_run、limit 过滤
+func (s *APIServer) handleGetAuditRecords(w http.ResponseWriter, r *http.Request) {
+	params := s.parseQueryParams(r)
+	filter := audit.Filter{
+		Source:    params["source"],
+		Action:    params["action"],
+		Namespace: params["namespace"],
+		PodName:   params["pod"],
+		Limit:     s.parseLimitParam(params, 100),
+	}
+	if val, exists := params["dry_run"]; exists {
+		dryRun, err := strconv.ParseBool(val)
+		if err != nil {
+			s.writeErrorResponse(w, "Invalid dry_run parameter", http.StatusBadRequest)
+			return
+		}
+		filter.DryRun = &dryRun
+	}
+
+	records := s.auditRecorder.List(filter)
+	s.writeJSONResponse(w, APIResponse{
+		Success: true,
+		Data:    records,
+		Count:   len(records),
+	}, http.StatusOK)
+}
+
 // handleHealth 健康检查
 func (s *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	health := map[string]interface{}{
@@ -335,20 +552,27 @@ func (s *APIServer) handleInfo(w http.ResponseWriter, r *http.Request) {
 	trends := s.smartLimitManager.AnalyzeAllContainerTrends()
 
 	info := map[string]interface{}{
-		"service":           "KubeDiskGuard API",
-		"version":           "v1",
-		"timestamp":         time.Now(),
+		"service":              "KubeDiskGuard API",
+		"version":              "v1",
+		"timestamp":            time.Now(),
 		"monitored_containers": len(trends),
 		"endpoints": map[string]string{
-			"metrics":      "/api/v1/metrics/containers",
-			"limits":       "/api/v1/limits/status",
-			"health":       "/api/v1/health",
-			"info":         "/api/v1/info",
+			"metrics":          "/api/v1/metrics/containers",
+			"limits":           "/api/v1/limits/status",
+			"effective_limits": "/api/v1/effective-limits",
+			"filter_decisions": "/api/v1/filter-decisions",
+			"node_budget":      "/api/v1/node-budget",
+			"audit":            "/api/v1/audit",
+			"health":           "/api/v1/health",
+			"info":             "/api/v1/info",
 		},
 	}
+	if usage, ok := s.smartLimitManager.GetDeviceUsage(); ok {
+		info["device_usage"] = usage
+	}
 
 	s.writeJSONResponse(w, APIResponse{
 		Success: true,
 		Data:    info,
 	}, http.StatusOK)
-}
\ No newline at end of file
+}
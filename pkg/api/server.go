@@ -9,6 +9,11 @@ import (
 	"strings"
 	"time"
 
+	"KubeDiskGuard/pkg/audit"
+	"KubeDiskGuard/pkg/budget"
+	"KubeDiskGuard/pkg/filter"
+	"KubeDiskGuard/pkg/limits"
+	"KubeDiskGuard/pkg/schedule"
 	"KubeDiskGuard/pkg/smartlimit"
 
 	"github.com/gorilla/mux"
@@ -17,6 +22,31 @@ import (
 // APIServer HTTP API 服务器
 type APIServer struct {
 	smartLimitManager *smartlimit.SmartLimitManager
+	auditRecorder     *audit.Recorder
+	limitsProvider    EffectiveLimitsProvider
+	filterProvider    FilterDecisionProvider
+	scheduleProvider  ScheduleStatusProvider
+	budgetProvider    NodeBudgetProvider
+}
+
+// EffectiveLimitsProvider 提供容器限速解析结果（含优先级链）
+type EffectiveLimitsProvider interface {
+	EffectiveLimits(namespace, podName string) []limits.Resolution
+}
+
+// FilterDecisionProvider 提供容器过滤结果（含命中的规则）
+type FilterDecisionProvider interface {
+	FilterDecisions(namespace, podName string) []filter.Decision
+}
+
+// ScheduleStatusProvider 提供按时间表限速的容器当前所处窗口
+type ScheduleStatusProvider interface {
+	ScheduleStatuses() []schedule.Status
+}
+
+// NodeBudgetProvider 提供节点IO预算及各Pod的分配结果
+type NodeBudgetProvider interface {
+	NodeBudget() budget.Status
 }
 
 // NewAPIServer 创建新的API服务器
@@ -26,6 +56,31 @@ func NewAPIServer(smartLimitManager *smartlimit.SmartLimitManager) *APIServer {
 	}
 }
 
+// SetAuditRecorder 设置限速动作审计记录器
+func (s *APIServer) SetAuditRecorder(recorder *audit.Recorder) {
+	s.auditRecorder = recorder
+}
+
+// SetEffectiveLimitsProvider 设置容器限速解析结果提供者
+func (s *APIServer) SetEffectiveLimitsProvider(provider EffectiveLimitsProvider) {
+	s.limitsProvider = provider
+}
+
+// SetFilterDecisionProvider 设置容器过滤结果提供者
+func (s *APIServer) SetFilterDecisionProvider(provider FilterDecisionProvider) {
+	s.filterProvider = provider
+}
+
+// SetScheduleStatusProvider 设置时间窗口状态提供者
+func (s *APIServer) SetScheduleStatusProvider(provider ScheduleStatusProvider) {
+	s.scheduleProvider = provider
+}
+
+// SetNodeBudgetProvider 设置节点IO预算分配结果提供者
+func (s *APIServer) SetNodeBudgetProvider(provider NodeBudgetProvider) {
+	s.budgetProvider = provider
+}
+
 // RegisterRoutes 注册API路由到给定的路由器
 func (s *APIServer) RegisterRoutes(router *mux.Router) {
 	// 创建API子路由
@@ -43,6 +98,21 @@ func (s *APIServer) RegisterRoutes(router *mux.Router) {
 	apiRouter.HandleFunc("/limit-status", s.handleGetAllLimitStatus).Methods("GET")
 	apiRouter.HandleFunc("/limit-status/{id}", s.handleGetContainerLimitStatus).Methods("GET")
 
+	// 生效限速（全局 → 命名空间 → Pod → 容器）
+	apiRouter.HandleFunc("/effective-limits", s.handleGetEffectiveLimits).Methods("GET")
+
+	// 包含/排除规则匹配结果
+	apiRouter.HandleFunc("/filter-decisions", s.handleGetFilterDecisions).Methods("GET")
+
+	// 节点IO预算分配结果
+	apiRouter.HandleFunc("/node-budget", s.handleGetNodeBudget).Methods("GET")
+
+	// 异常检测的容器IO基线
+	apiRouter.HandleFunc("/baselines", s.handleGetBaselines).Methods("GET")
+
+	// 限速动作审计路由
+	apiRouter.HandleFunc("/audit", s.handleGetAuditRecords).Methods("GET")
+
 	// 系统信息路由
 	apiRouter.HandleFunc("/health", s.handleHealth).Methods("GET")
 	apiRouter.HandleFunc("/info", s.handleInfo).Methods("GET")
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 审计来源
+const (
+	SourceService    = "service"    // 注解/规则驱动的静态限速
+	SourceSmartLimit = "smartlimit" // 智能限速
+)
+
+// 审计动作
+const (
+	ActionSetLimits        = "set-limits"         // 写入容器cgroup限速
+	ActionResetLimits      = "reset-limits"       // 解除容器cgroup限速
+	ActionApplySmartLimit  = "apply-smart-limit"  // 写入智能限速注解
+	ActionRemoveSmartLimit = "remove-smart-limit" // 移除智能限速注解
+	ActionRelaxSmartLimit  = "relax-smart-limit"  // 逐级放宽智能限速注解
+)
+
+// DefaultBufferSize 审计环形缓冲区默认容量
+const DefaultBufferSize = 1000
+
+var auditActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kubediskguard_audit_actions_total",
+	Help: "限速动作审计计数（含dry-run模式下未实际执行的动作）",
+}, []string{"source", "action", "dry_run", "result"})
+
+func init() {
+	prometheus.MustRegister(auditActions)
+}
+
+// Record 单条限速动作审计记录
+type Record struct {
+	Time          time.Time `json:"time"`
+	Source        string    `json:"source"`
+	Action        string    `json:"action"`
+	DryRun        bool      `json:"dry_run"`
+	Namespace     string    `json:"namespace,omitempty"`
+	PodName       string    `json:"pod_name,omitempty"`
+	ContainerName string    `json:"container_name,omitempty"`
+	ContainerID   string    `json:"container_id,omitempty"`
+	Device        string    `json:"device,omitempty"` // 数据盘以外的设备（major:minor），如PVC所在的盘
+	ReadIOPS      int       `json:"read_iops"`
+	WriteIOPS     int       `json:"write_iops"`
+	ReadBPS       int       `json:"read_bps"`
+	WriteBPS      int       `json:"write_bps"`
+	Reason        string    `json:"reason,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Filter 查询审计记录的过滤条件，零值表示不过滤
+type Filter struct {
+	Source    string
+	Action    string
+	Namespace string
+	PodName   string
+	DryRun    *bool
+	Limit     int
+}
+
+// Recorder 审计记录器，使用固定容量的环形缓冲区保存最近的记录
+type Recorder struct {
+	mu      sync.RWMutex
+	records []Record
+	next    int
+	full    bool
+}
+
+// NewRecorder 创建审计记录器，size<=0 时使用默认容量
+func NewRecorder(size int) *Recorder {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Recorder{records: make([]Record, size)}
+}
+
+// Record 记录一次动作并更新Prometheus计数，nil记录器只更新计数
+func (r *Recorder) Record(rec Record) {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	result := "success"
+	if rec.Error != "" {
+		result = "failure"
+	}
+	auditActions.WithLabelValues(rec.Source, rec.Action, strconv.FormatBool(rec.DryRun), result).Inc()
+
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = rec
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// List 按时间倒序返回匹配过滤条件的记录
+func (r *Recorder) List(filter Filter) []Record {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.records)
+	}
+	var result []Record
+	for i := 0; i < count; i++ {
+		idx := (r.next - 1 - i + len(r.records)) % len(r.records)
+		rec := r.records[idx]
+		if !filter.matches(rec) {
+			continue
+		}
+		result = append(result, rec)
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result
+}
+
+func (f Filter) matches(rec Record) bool {
+	if f.Source != "" && rec.Source != f.Source {
+		return false
+	}
+	if f.Action != "" && rec.Action != f.Action {
+		return false
+	}
+	if f.Namespace != "" && rec.Namespace != f.Namespace {
+		return false
+	}
+	if f.PodName != "" && rec.PodName != f.PodName {
+		return false
+	}
+	if f.DryRun != nil && rec.DryRun != *f.DryRun {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorderRingBuffer(t *testing.T) {
+	r := NewRecorder(3)
+	for i := 0; i < 5; i++ {
+		r.Record(Record{Source: SourceService, Action: ActionSetLimits, WriteIOPS: i, DryRun: i%2 == 0})
+	}
+
+	records := r.List(Filter{})
+	assert.Len(t, records, 3)
+	// 最新的记录在前，最旧的两条被覆盖
+	assert.Equal(t, []int{4, 3, 2}, []int{records[0].WriteIOPS, records[1].WriteIOPS, records[2].WriteIOPS})
+
+	dryRun := true
+	records = r.List(Filter{DryRun: &dryRun})
+	assert.Len(t, records, 2)
+	assert.Len(t, r.List(Filter{Limit: 1}), 1)
+	assert.Empty(t, r.List(Filter{Action: ActionResetLimits}))
+
+	var nilRecorder *Recorder
+	nilRecorder.Record(Record{Source: SourceService, Action: ActionResetLimits})
+	assert.Nil(t, nilRecorder.List(Filter{}))
+}
@@ -0,0 +1,185 @@
+package budget
+
+import (
+	"math"
+	"time"
+
+	"KubeDiskGuard/pkg/limits"
+)
+
+// Usage 四项IO的近期用量（IOPS 为次/秒，BPS 为字节/秒）
+type Usage struct {
+	ReadIOPS  float64 `json:"read_iops"`
+	WriteIOPS float64 `json:"write_iops"`
+	ReadBPS   float64 `json:"read_bps"`
+	WriteBPS  float64 `json:"write_bps"`
+}
+
+// Add 返回两组用量之和
+func (u Usage) Add(o Usage) Usage {
+	return Usage{ReadIOPS: u.ReadIOPS + o.ReadIOPS, WriteIOPS: u.WriteIOPS + o.WriteIOPS, ReadBPS: u.ReadBPS + o.ReadBPS, WriteBPS: u.WriteBPS + o.WriteBPS}
+}
+
+// Member 参与预算分配的对象（Pod，或Pod内的容器）
+type Member struct {
+	Key    string        // 唯一标识，如 namespace/name
+	Weight float64       // 权重，<=0 时按1处理
+	Demand Usage         // 近期用量
+	Cap    limits.Limits // 自身限速上限，0表示该项不限
+}
+
+// Claim 单项预算的分配请求
+type Claim struct {
+	Key    string
+	Weight float64
+	Demand float64
+	Cap    int // 0表示不限
+}
+
+// 分配参数：限速后的用量不会超过限速值，需求按 Headroom 放大，给受限对象逐轮增长的空间；
+// 每个对象至少保留 MinShareRatio 比例的加权平均份额，避免空闲对象被压到无法恢复
+const (
+	Headroom      = 0.2
+	MinShareRatio = 0.1
+)
+
+// Share 按加权 max-min 公平分配 total：
+// 先给每个对象保底份额，再按需求注水，需求（不超过上限）已满足的对象退出，其余额度按权重继续分给需求更大的对象；
+// 所有需求满足后仍有剩余时，剩余额度再按权重分给未达上限的对象，给空闲对象留出突发空间。
+// 分配结果至少为1（0在cgroup中表示不限速），total<=0 时返回nil
+func Share(total int, claims []Claim) map[string]int {
+	if total <= 0 || len(claims) == 0 {
+		return nil
+	}
+	alloc := make([]float64, len(claims))
+	weight := func(i int) float64 {
+		if claims[i].Weight <= 0 {
+			return 1
+		}
+		return claims[i].Weight
+	}
+	capOf := func(i int) float64 {
+		if claims[i].Cap <= 0 {
+			return math.Inf(1)
+		}
+		return float64(claims[i].Cap)
+	}
+
+	var sum float64
+	for i := range claims {
+		sum += weight(i)
+	}
+	remaining := float64(total)
+	for i := range claims {
+		alloc[i] = math.Min(float64(total)*weight(i)/sum*MinShareRatio, capOf(i))
+		remaining -= alloc[i]
+	}
+	remaining = waterFill(remaining, alloc, weight, func(i int) float64 {
+		return math.Min(math.Max(claims[i].Demand, 0)*(1+Headroom), capOf(i))
+	})
+	waterFill(remaining, alloc, weight, capOf)
+
+	shares := make(map[string]int, len(claims))
+	for i, c := range claims {
+		v := int(math.Floor(alloc[i]))
+		if v < 1 {
+			v = 1
+		}
+		shares[c.Key] = v
+	}
+	return shares
+}
+
+// waterFill 将 remaining 按权重注入 alloc，每个对象不超过 limit(i)，返回分配不完的余量
+func waterFill(remaining float64, alloc []float64, weight, limit func(i int) float64) float64 {
+	active := make([]int, 0, len(alloc))
+	for i := range alloc {
+		if limit(i) > alloc[i] {
+			active = append(active, i)
+		}
+	}
+	for remaining > 0 && len(active) > 0 {
+		var sum float64
+		for _, i := range active {
+			sum += weight(i)
+		}
+		unit := remaining / sum
+		next := active[:0:0]
+		saturated := false
+		for _, i := range active {
+			room := limit(i) - alloc[i]
+			if room <= unit*weight(i) {
+				alloc[i] += room
+				remaining -= room
+				saturated = true
+				continue
+			}
+			next = append(next, i)
+		}
+		if !saturated {
+			// 没有对象达到上限，按权重分完剩余额度
+			for _, i := range next {
+				alloc[i] += unit * weight(i)
+			}
+			return 0
+		}
+		active = next
+	}
+	return remaining
+}
+
+// Allocate 对四项预算分别按加权 max-min 公平分配，预算为0的项不分配（结果为0，即不受预算约束）
+func Allocate(total limits.Limits, members []Member) map[string]limits.Limits {
+	dimension := func(budget int, demand func(Usage) float64, capOf func(limits.Limits) int) map[string]int {
+		claims := make([]Claim, len(members))
+		for i, m := range members {
+			claims[i] = Claim{Key: m.Key, Weight: m.Weight, Demand: demand(m.Demand), Cap: capOf(m.Cap)}
+		}
+		return Share(budget, claims)
+	}
+	readIOPS := dimension(total.ReadIOPS, func(u Usage) float64 { return u.ReadIOPS }, func(l limits.Limits) int { return l.ReadIOPS })
+	writeIOPS := dimension(total.WriteIOPS, func(u Usage) float64 { return u.WriteIOPS }, func(l limits.Limits) int { return l.WriteIOPS })
+	readBPS := dimension(total.ReadBPS, func(u Usage) float64 { return u.ReadBPS }, func(l limits.Limits) int { return l.ReadBPS })
+	writeBPS := dimension(total.WriteBPS, func(u Usage) float64 { return u.WriteBPS }, func(l limits.Limits) int { return l.WriteBPS })
+
+	result := make(map[string]limits.Limits, len(members))
+	for _, m := range members {
+		result[m.Key] = limits.Limits{
+			ReadIOPS: readIOPS[m.Key], WriteIOPS: writeIOPS[m.Key],
+			ReadBPS: readBPS[m.Key], WriteBPS: writeBPS[m.Key],
+		}
+	}
+	return result
+}
+
+// Min 逐项取两组限速中更严格的值，0表示该项不限
+func Min(a, b limits.Limits) limits.Limits {
+	pick := func(x, y int) int {
+		if x == 0 || (y != 0 && y < x) {
+			return y
+		}
+		return x
+	}
+	return limits.Limits{
+		ReadIOPS: pick(a.ReadIOPS, b.ReadIOPS), WriteIOPS: pick(a.WriteIOPS, b.WriteIOPS),
+		ReadBPS: pick(a.ReadBPS, b.ReadBPS), WriteBPS: pick(a.WriteBPS, b.WriteBPS),
+	}
+}
+
+// PodAllocation 单个Pod的预算分配结果，供API展示
+type PodAllocation struct {
+	Namespace  string        `json:"namespace"`
+	PodName    string        `json:"pod_name"`
+	Weight     float64       `json:"weight"`
+	Demand     Usage         `json:"demand"`
+	Cap        limits.Limits `json:"cap"`
+	Allocation limits.Limits `json:"allocation"`
+}
+
+// Status 节点预算及最近一次分配结果
+type Status struct {
+	Total     limits.Limits   `json:"total"`
+	Reserved  limits.Limits   `json:"reserved"` // 容器以IO扩展资源声明的部分，不参与分配
+	Pods      []PodAllocation `json:"pods"`
+	UpdatedAt *time.Time      `json:"updated_at,omitempty"`
+}
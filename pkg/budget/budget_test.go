@@ -0,0 +1,55 @@
+package budget
+
+import (
+	"testing"
+
+	"KubeDiskGuard/pkg/limits"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareWeightedMaxMin(t *testing.T) {
+	// 需求小的对象按需求（含增长余量）分配，其余额度按权重分给需求更大的对象
+	shares := Share(1000, []Claim{
+		{Key: "small", Weight: 1, Demand: 100},
+		{Key: "heavy", Weight: 2, Demand: 2000},
+		{Key: "light", Weight: 1, Demand: 2000},
+	})
+	assert.Equal(t, map[string]int{"small": 120, "heavy": 586, "light": 293}, shares)
+
+	// 自身上限低于公平份额时以上限为准，多出的额度分给其他对象
+	shares = Share(1000, []Claim{
+		{Key: "capped", Weight: 1, Demand: 2000, Cap: 200},
+		{Key: "other", Weight: 1, Demand: 2000},
+	})
+	assert.Equal(t, map[string]int{"capped": 200, "other": 800}, shares)
+
+	// 需求全部满足后剩余额度按权重分给未达上限的对象
+	shares = Share(1000, []Claim{
+		{Key: "busy", Weight: 1, Demand: 300},
+		{Key: "idle", Weight: 1, Demand: 0},
+	})
+	assert.Equal(t, map[string]int{"busy": 655, "idle": 345}, shares)
+
+	// 竞争激烈时空闲对象仍保留保底份额
+	shares = Share(1000, []Claim{
+		{Key: "busy", Weight: 1, Demand: 5000},
+		{Key: "idle", Weight: 1, Demand: 0},
+	})
+	assert.Equal(t, map[string]int{"busy": 950, "idle": 50}, shares)
+
+	assert.Nil(t, Share(0, []Claim{{Key: "a", Demand: 10}}))
+}
+
+func TestAllocateAndMin(t *testing.T) {
+	members := []Member{
+		{Key: "a", Weight: 1, Demand: Usage{WriteIOPS: 900}},
+		{Key: "b", Weight: 1, Demand: Usage{WriteIOPS: 900}, Cap: limits.Limits{WriteIOPS: 100}},
+	}
+	allocs := Allocate(limits.Limits{WriteIOPS: 1000}, members)
+	// 未设置预算的项为0，不受预算约束
+	assert.Equal(t, limits.Limits{WriteIOPS: 900}, allocs["a"])
+	assert.Equal(t, limits.Limits{WriteIOPS: 100}, allocs["b"])
+
+	assert.Equal(t, limits.Limits{ReadIOPS: 300, WriteIOPS: 100, ReadBPS: 5}, Min(limits.Limits{ReadIOPS: 300, WriteIOPS: 500}, limits.Limits{WriteIOPS: 100, ReadBPS: 5}))
+}
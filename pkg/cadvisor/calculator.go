@@ -26,6 +26,7 @@ type MetricPoint struct {
 	WriteIOPS   float64 // 累积写入次数
 	ReadBytes   float64 // 累积读取字节数
 	WriteBytes  float64 // 累积写入字节数
+	IOTime      float64 // 累积加权IO时间（秒），即各IO耗时之和
 }
 
 // IORate 计算出的IO速率
@@ -36,6 +37,7 @@ type IORate struct {
 	WriteIOPS   float64 // 每秒写入操作数
 	ReadBPS     float64 // 每秒读取字节数
 	WriteBPS    float64 // 每秒写入字节数
+	Latency     float64 // 平均每次IO耗时（微秒），cAdvisor不区分读写，无IO或无加权IO时间时为0
 }
 
 // Calculator cAdvisor指标计算器
@@ -53,18 +55,22 @@ func NewCalculator() *Calculator {
 
 // AddMetricPoint 添加指标数据点
 func (c *Calculator) AddMetricPoint(containerID string, timestamp time.Time, readIOPS, writeIOPS, readBytes, writeBytes float64) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	point := MetricPoint{
+	c.addPoint(MetricPoint{
 		ContainerID: containerID,
 		Timestamp:   timestamp,
 		ReadIOPS:    readIOPS,
 		WriteIOPS:   writeIOPS,
 		ReadBytes:   readBytes,
 		WriteBytes:  writeBytes,
-	}
+	})
+}
 
+// addPoint 追加数据点
+func (c *Calculator) addPoint(point MetricPoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	containerID := point.ContainerID
 	if _, exists := c.history[containerID]; !exists {
 		c.history[containerID] = make([]MetricPoint, 0)
 	}
@@ -122,6 +128,12 @@ func (c *Calculator) CalculateIORate(containerID string, window time.Duration) (
 	readBPS := readBytesDelta / timeDiff
 	writeBPS := writeBytesDelta / timeDiff
 
+	// 计算平均延迟：加权IO时间增量除以IO次数增量
+	var latency float64
+	if ios := readIOPSDelta + writeIOPSDelta; ios > 0 && latest.IOTime >= earliest.IOTime {
+		latency = (latest.IOTime - earliest.IOTime) / ios * 1e6
+	}
+
 	return &IORate{
 		ContainerID: containerID,
 		Timestamp:   latest.Timestamp,
@@ -129,6 +141,7 @@ func (c *Calculator) CalculateIORate(containerID string, window time.Duration) (
 		WriteIOPS:   writeIOPS,
 		ReadBPS:     readBPS,
 		WriteBPS:    writeBPS,
+		Latency:     latency,
 	}, nil
 }
 
@@ -229,7 +242,15 @@ func (c *Calculator) GetTotalDataPoints() int {
 // Update a new method to process CadvisorMetrics
 func (c *Calculator) Update(metrics *CadvisorMetrics, timestamp time.Time) {
 	for id, val := range metrics.ContainerFSReadsTotal {
-		c.AddMetricPoint(id, timestamp, val, metrics.ContainerFSWritesTotal[id], metrics.ContainerFSReadsBytesTotal[id], metrics.ContainerFSWritesBytesTotal[id])
+		c.addPoint(MetricPoint{
+			ContainerID: id,
+			Timestamp:   timestamp,
+			ReadIOPS:    val,
+			WriteIOPS:   metrics.ContainerFSWritesTotal[id],
+			ReadBytes:   metrics.ContainerFSReadsBytesTotal[id],
+			WriteBytes:  metrics.ContainerFSWritesBytesTotal[id],
+			IOTime:      metrics.ContainerFSIoTimeWeighted[id],
+		})
 	}
 }
 
@@ -2,6 +2,8 @@ package cgroup
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -65,3 +67,47 @@ func TestSetAndResetLimits(t *testing.T) {
 		t.Errorf("ResetLimits failed: %v", err)
 	}
 }
+
+func TestParseIOStat(t *testing.T) {
+	content := "8:16 rbytes=1024 wbytes=4096 rios=2 wios=8 dbytes=0 dios=0\n" +
+		"8:0 rbytes=8192 wbytes=0 rios=4 wios=0 dbytes=0 dios=0 depth=max avg_lat=1500 win=100\n"
+	stats, err := ParseIOStat(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseIOStat failed: %v", err)
+	}
+	if s := stats["8:16"]; s.WriteBytes != 4096 || s.WriteIOs != 8 || s.AvgLatency != 0 {
+		t.Errorf("unexpected stat for 8:16: %+v", s)
+	}
+	if s := stats["8:0"]; s.ReadIOs != 4 || s.AvgLatency != 1500 {
+		t.Errorf("unexpected stat for 8:0: %+v", s)
+	}
+	if _, err := ParseIOStat(strings.NewReader("8:0 rios=x\n")); err == nil {
+		t.Errorf("expected error for invalid value")
+	}
+}
+
+func TestReadIOStatAndFindContainerCgroup(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "kubepods.slice", "kubepods-pod1.slice", "cri-containerd-abc123.scope")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, IOStatFile), []byte("8:0 rbytes=0 wbytes=0 rios=1 wios=1 avg_lat=250\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := FindContainerCgroup(root, "abc123")
+	if err != nil || path != dir {
+		t.Fatalf("FindContainerCgroup = %q, %v, want %q", path, err, dir)
+	}
+	if path, _ := FindContainerCgroup(root, "missing"); path != "" {
+		t.Errorf("expected no cgroup for unknown container, got %q", path)
+	}
+	stat, err := ReadIOStat(path, "8:0")
+	if err != nil || stat.AvgLatency != 250 {
+		t.Errorf("ReadIOStat = %+v, %v", stat, err)
+	}
+	if stat, _ := ReadIOStat(path, "8:16"); stat != (IOStat{}) {
+		t.Errorf("expected zero stat for idle device, got %+v", stat)
+	}
+}
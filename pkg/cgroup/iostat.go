@@ -0,0 +1,105 @@
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IOStatFile cgroup v2 的IO统计文件
+const IOStatFile = "io.stat"
+
+// IOStat cgroup v2 io.stat 中单个设备的累计统计
+type IOStat struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadIOs    uint64
+	WriteIOs   uint64
+	AvgLatency uint64 // io.latency 控制器报告的平均IO延迟（微秒，指数移动平均），内核未报告时为0
+}
+
+// ParseIOStat 解析 io.stat 格式的内容，返回 major:minor -> 统计，未识别的字段（如 depth、win、cost.*）忽略
+func ParseIOStat(r io.Reader) (map[string]IOStat, error) {
+	stats := make(map[string]IOStat)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var stat IOStat
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			var target *uint64
+			switch key {
+			case "rbytes":
+				target = &stat.ReadBytes
+			case "wbytes":
+				target = &stat.WriteBytes
+			case "rios":
+				target = &stat.ReadIOs
+			case "wios":
+				target = &stat.WriteIOs
+			case "avg_lat":
+				target = &stat.AvgLatency
+			default:
+				continue
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid io.stat line %q: %v", scanner.Text(), err)
+			}
+			*target = v
+		}
+		stats[fields[0]] = stat
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read io.stat: %v", err)
+	}
+	return stats, nil
+}
+
+// ReadIOStat 读取cgroup目录下 io.stat 中指定设备的统计，设备上没有IO时内核不输出该设备，返回零值
+func ReadIOStat(cgroupPath, majMin string) (IOStat, error) {
+	f, err := os.Open(filepath.Join(cgroupPath, IOStatFile))
+	if err != nil {
+		return IOStat{}, fmt.Errorf("failed to open io.stat: %v", err)
+	}
+	defer f.Close()
+	stats, err := ParseIOStat(f)
+	if err != nil {
+		return IOStat{}, err
+	}
+	return stats[majMin], nil
+}
+
+// FindContainerCgroup 在 root 下查找目录名包含容器ID的cgroup目录（如 cri-containerd-<id>.scope、docker-<id>.scope），
+// 未找到时返回空字符串；无权限读取的子目录跳过
+func FindContainerCgroup(root, containerID string) (string, error) {
+	if containerID == "" {
+		return "", fmt.Errorf("empty container ID")
+	}
+	var found string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if strings.Contains(d.Name(), containerID) {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to walk %s: %v", root, err)
+	}
+	return found, nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"log"
 	"os"
 	"strconv"
 	"strings"
@@ -43,16 +44,72 @@ type Config struct {
 	SmartLimitBPSThreshold15m float64 `json:"smart_limit_bps_threshold_15m"` // 15分钟BPS阈值
 	SmartLimitIOPSLimit15m    int     `json:"smart_limit_iops_limit_15m"`    // 15分钟限速IOPS值
 	SmartLimitBPSLimit15m     int     `json:"smart_limit_bps_limit_15m"`     // 15分钟限速BPS值
+	// 15分钟窗口分方向的阈值和限速值
+	SmartLimitReadIOThreshold15m   float64 `json:"smart_limit_read_io_threshold_15m"`   // 15分钟读IOPS阈值，为0时使用读写共用的值
+	SmartLimitWriteIOThreshold15m  float64 `json:"smart_limit_write_io_threshold_15m"`  // 15分钟写IOPS阈值，为0时使用读写共用的值
+	SmartLimitReadBPSThreshold15m  float64 `json:"smart_limit_read_bps_threshold_15m"`  // 15分钟读BPS阈值，为0时使用读写共用的值
+	SmartLimitWriteBPSThreshold15m float64 `json:"smart_limit_write_bps_threshold_15m"` // 15分钟写BPS阈值，为0时使用读写共用的值
+	SmartLimitReadIOPSLimit15m     int     `json:"smart_limit_read_iops_limit_15m"`     // 15分钟读IOPS限速值，为0时使用读写共用的值
+	SmartLimitWriteIOPSLimit15m    int     `json:"smart_limit_write_iops_limit_15m"`    // 15分钟写IOPS限速值，为0时使用读写共用的值
+	SmartLimitReadBPSLimit15m      int     `json:"smart_limit_read_bps_limit_15m"`      // 15分钟读BPS限速值，为0时使用读写共用的值
+	SmartLimitWriteBPSLimit15m     int     `json:"smart_limit_write_bps_limit_15m"`     // 15分钟写BPS限速值，为0时使用读写共用的值
 	// 30分钟窗口阈值和限速值
 	SmartLimitIOThreshold30m  float64 `json:"smart_limit_io_threshold_30m"`  // 30分钟IO阈值
 	SmartLimitBPSThreshold30m float64 `json:"smart_limit_bps_threshold_30m"` // 30分钟BPS阈值
 	SmartLimitIOPSLimit30m    int     `json:"smart_limit_iops_limit_30m"`    // 30分钟限速IOPS值
 	SmartLimitBPSLimit30m     int     `json:"smart_limit_bps_limit_30m"`     // 30分钟限速BPS值
+	// 30分钟窗口分方向的阈值和限速值
+	SmartLimitReadIOThreshold30m   float64 `json:"smart_limit_read_io_threshold_30m"`   // 30分钟读IOPS阈值，为0时使用读写共用的值
+	SmartLimitWriteIOThreshold30m  float64 `json:"smart_limit_write_io_threshold_30m"`  // 30分钟写IOPS阈值，为0时使用读写共用的值
+	SmartLimitReadBPSThreshold30m  float64 `json:"smart_limit_read_bps_threshold_30m"`  // 30分钟读BPS阈值，为0时使用读写共用的值
+	SmartLimitWriteBPSThreshold30m float64 `json:"smart_limit_write_bps_threshold_30m"` // 30分钟写BPS阈值，为0时使用读写共用的值
+	SmartLimitReadIOPSLimit30m     int     `json:"smart_limit_read_iops_limit_30m"`     // 30分钟读IOPS限速值，为0时使用读写共用的值
+	SmartLimitWriteIOPSLimit30m    int     `json:"smart_limit_write_iops_limit_30m"`    // 30分钟写IOPS限速值，为0时使用读写共用的值
+	SmartLimitReadBPSLimit30m      int     `json:"smart_limit_read_bps_limit_30m"`      // 30分钟读BPS限速值，为0时使用读写共用的值
+	SmartLimitWriteBPSLimit30m     int     `json:"smart_limit_write_bps_limit_30m"`     // 30分钟写BPS限速值，为0时使用读写共用的值
 	// 60分钟窗口阈值和限速值
 	SmartLimitIOThreshold60m  float64 `json:"smart_limit_io_threshold_60m"`  // 60分钟IO阈值
 	SmartLimitBPSThreshold60m float64 `json:"smart_limit_bps_threshold_60m"` // 60分钟BPS阈值
 	SmartLimitIOPSLimit60m    int     `json:"smart_limit_iops_limit_60m"`    // 60分钟限速IOPS值
 	SmartLimitBPSLimit60m     int     `json:"smart_limit_bps_limit_60m"`     // 60分钟限速BPS值
+	// 60分钟窗口分方向的阈值和限速值
+	SmartLimitReadIOThreshold60m   float64 `json:"smart_limit_read_io_threshold_60m"`   // 60分钟读IOPS阈值，为0时使用读写共用的值
+	SmartLimitWriteIOThreshold60m  float64 `json:"smart_limit_write_io_threshold_60m"`  // 60分钟写IOPS阈值，为0时使用读写共用的值
+	SmartLimitReadBPSThreshold60m  float64 `json:"smart_limit_read_bps_threshold_60m"`  // 60分钟读BPS阈值，为0时使用读写共用的值
+	SmartLimitWriteBPSThreshold60m float64 `json:"smart_limit_write_bps_threshold_60m"` // 60分钟写BPS阈值，为0时使用读写共用的值
+	SmartLimitReadIOPSLimit60m     int     `json:"smart_limit_read_iops_limit_60m"`     // 60分钟读IOPS限速值，为0时使用读写共用的值
+	SmartLimitWriteIOPSLimit60m    int     `json:"smart_limit_write_iops_limit_60m"`    // 60分钟写IOPS限速值，为0时使用读写共用的值
+	SmartLimitReadBPSLimit60m      int     `json:"smart_limit_read_bps_limit_60m"`      // 60分钟读BPS限速值，为0时使用读写共用的值
+	SmartLimitWriteBPSLimit60m     int     `json:"smart_limit_write_bps_limit_60m"`     // 60分钟写BPS限速值，为0时使用读写共用的值
+
+	// 比例限速配置：限速值为触发窗口观测速率乘以比例，限制在下限与 MaxIOPSLimit/MaxBPSLimit 之间
+	SmartLimitLimitMode           string  `json:"smart_limit_limit_mode"`            // 限速值计算方式：fixed（各窗口的固定限速值）或 proportional
+	SmartLimitReadIOPSFraction    float64 `json:"smart_limit_read_iops_fraction"`    // 读IOPS限速比例
+	SmartLimitWriteIOPSFraction   float64 `json:"smart_limit_write_iops_fraction"`   // 写IOPS限速比例
+	SmartLimitReadBPSFraction     float64 `json:"smart_limit_read_bps_fraction"`     // 读BPS限速比例
+	SmartLimitWriteBPSFraction    float64 `json:"smart_limit_write_bps_fraction"`    // 写BPS限速比例
+	SmartLimitProportionalMinIOPS int     `json:"smart_limit_proportional_min_iops"` // 比例限速IOPS下限
+	SmartLimitProportionalMinBPS  int     `json:"smart_limit_proportional_min_bps"`  // 比例限速BPS下限
+
+	// 异常检测配置：按容器维护各项速率的EWMA基线，速率持续超出基线N倍标准差时限速
+	SmartLimitDetector            string  `json:"smart_limit_detector"`              // 默认检测方式：threshold（分级阈值）或 anomaly（基线异常检测），可按命名配置/限速等级覆盖
+	SmartLimitAnomalyAlpha        float64 `json:"smart_limit_anomaly_alpha"`         // EWMA平滑系数，越小基线变化越慢
+	SmartLimitAnomalySigma        float64 `json:"smart_limit_anomaly_sigma"`         // 速率超出基线均值的标准差倍数
+	SmartLimitAnomalyReleaseSigma float64 `json:"smart_limit_anomaly_release_sigma"` // 速率回落到基线均值加该倍数标准差以内时解除
+	SmartLimitAnomalySustain      int     `json:"smart_limit_anomaly_sustain"`       // 持续超出基线该时间后限速（分钟）
+	SmartLimitAnomalyWarmup       int     `json:"smart_limit_anomaly_warmup"`        // 基线至少学习的样本数，之前不检测
+	SmartLimitAnomalyMinIOPS      int     `json:"smart_limit_anomaly_min_iops"`      // 低于该IOPS不视为异常，也是异常限速的IOPS下限
+	SmartLimitAnomalyMinBPS       int     `json:"smart_limit_anomaly_min_bps"`       // 低于该BPS不视为异常，也是异常限速的BPS下限
+
+	// 按窗口统计量的触发条件，如 15m:p95:write_iops:1000，与分级阈值同时生效，可被命名配置/限速等级替换
+	SmartLimitTriggers string `json:"smart_limit_triggers"`
+
+	// 设备争用判断：数据盘繁忙（利用率、等待时间或邻居容器延迟超过阈值）且容器占设备IO的比例超过阈值时才限速
+	SmartLimitContentionEnabled bool    `json:"smart_limit_contention_enabled"` // 是否启用争用判断，关闭时超过阈值即限速
+	SmartLimitContentionUtil    float64 `json:"smart_limit_contention_util"`    // 设备利用率阈值（%），0表示不按利用率判断
+	SmartLimitContentionAwait   float64 `json:"smart_limit_contention_await"`   // 设备IO平均等待时间阈值（毫秒），0表示不按等待时间判断
+	SmartLimitContentionShare   float64 `json:"smart_limit_contention_share"`   // 容器IOPS或BPS占设备的比例阈值（0~1）
+	SmartLimitContentionLatency float64 `json:"smart_limit_contention_latency"` // 其他容器IO延迟阈值（毫秒），有容器达到时视为设备繁忙，0表示不按延迟判断
 
 	// kubelet API配置
 KubeletTokenPath        string `json:"kubelet_token_path,omitempty"`  // kubelet token路径
@@ -66,11 +123,77 @@ SmartLimitUseKubeletAPI bool   `json:"smart_limit_use_kubelet_api"`   // 是否
 	SmartLimitRemoveDelay         int     `json:"smart_limit_remove_delay"`          // 解除限速延迟（分钟）
 	SmartLimitRemoveCheckInterval int     `json:"smart_limit_remove_check_interval"` // 解除限速检查间隔（分钟）
 
+	// 逐级放宽配置：满足解除条件后每个检查间隔把限速值放宽一级，放宽 SmartLimitRelaxSteps 级后才解除
+	SmartLimitRelaxFactor     float64 `json:"smart_limit_relax_factor"`      // 每级放宽的倍数，不大于1时满足解除条件即一次性解除
+	SmartLimitRelaxSteps      int     `json:"smart_limit_relax_steps"`       // 放宽级数
+	SmartLimitFlapWindow      int     `json:"smart_limit_flap_window"`       // 解除后该时间内再次触发计为一次抖动（分钟）
+	SmartLimitFlapCooldown    int     `json:"smart_limit_flap_cooldown"`     // 抖动冷却基数（分钟），每次抖动翻倍，冷却期内不开始放宽
+	SmartLimitFlapCooldownMax int     `json:"smart_limit_flap_cooldown_max"` // 抖动冷却上限（分钟）
+
+	// 智能限速持久化配置
+	SmartLimitStoreDir string `json:"smart_limit_store_dir"` // 采集数据和限速状态的本地存储目录（需挂载hostPath），为空时不持久化
+
 	// 新增全局默认和最大限额配置
 	DefaultIOPSLimit int `yaml:"default_iops_limit" json:"default_iops_limit"`
 	DefaultBPSLimit  int `yaml:"default_bps_limit" json:"default_bps_limit"`
 	MaxIOPSLimit     int `yaml:"max_iops_limit" json:"max_iops_limit"`
 	MaxBPSLimit      int `yaml:"max_bps_limit" json:"max_bps_limit"`
+
+	// Pod 同步配置（SharedInformer + 工作队列）
+	PodResyncPeriod        int `json:"pod_resync_period"`         // 全量重新同步周期（秒），0表示不重新同步
+	PodSyncWorkers         int `json:"pod_sync_workers"`          // 并发处理Pod的worker数量
+	WorkQueueMaxRetries    int `json:"workqueue_max_retries"`     // 单个Pod限速下发失败的最大重试次数
+	KubeletPodPollInterval int `json:"kubelet_pod_poll_interval"` // kubelet API 模式下轮询 /pods 的间隔（秒）
+
+	// 容器类型开关（普通容器始终处理）
+	ProcessInitContainers      bool `json:"process_init_containers"`      // 是否对运行中的init容器限速
+	ProcessEphemeralContainers bool `json:"process_ephemeral_containers"` // 是否对临时调试容器限速
+
+	// 退出配置
+	OnExitPolicy      string `json:"on_exit_policy"`      // 退出策略：keep（保留限速）、release（解除本实例下发的限速）、handoff（写入状态快照交接）
+	StateSnapshotFile string `json:"state_snapshot_file"` // handoff 状态快照文件路径
+	ShutdownTimeout   int    `json:"shutdown_timeout"`    // 优雅退出超时（秒）
+
+	// 审计配置
+	DryRun          bool `json:"dry_run"`           // 只计算并审计限速决策，不写cgroup、不修改Pod
+	AuditBufferSize int  `json:"audit_buffer_size"` // 审计记录环形缓冲区容量
+
+	// 过滤规则
+	FilterRulesFile string `json:"filter_rules_file,omitempty"` // 包含/排除规则文件（JSON），设置后忽略 EXCLUDE_* 配置
+
+	// 限速等级与命名配置
+	LimitClassesFile  string `json:"limit_classes_file,omitempty"`  // 按QoS/PriorityClass映射限速等级的规则文件（JSON）
+	LimitProfilesFile string `json:"limit_profiles_file,omitempty"` // 命名限速配置文件（JSON），可挂载ConfigMap
+
+	// 时间窗口限速
+	ScheduleCheckInterval int `json:"schedule_check_interval"` // 检查时间窗口切换的间隔（秒），0为关闭
+
+	// 节点IO预算（按权重 max-min 公平分配给各Pod），各项为0表示不做预算
+	NodeReadIOPSBudget    int    `json:"node_read_iops_budget"`              // 节点读IOPS预算
+	NodeWriteIOPSBudget   int    `json:"node_write_iops_budget"`             // 节点写IOPS预算
+	NodeReadBPSBudget     int    `json:"node_read_bps_budget"`               // 节点读BPS预算
+	NodeWriteBPSBudget    int    `json:"node_write_bps_budget"`              // 节点写BPS预算
+	NodeDeviceProfileFile string `json:"node_device_profile_file,omitempty"` // 设备实测性能文件（JSON），未设置绝对预算的项按其百分比计算
+	NodeBudgetPercent     int    `json:"node_budget_percent"`                // 按设备性能计算预算时的百分比
+	NodeBudgetInterval    int    `json:"node_budget_interval"`               // 重新分配预算的间隔（秒）
+	NodeCapacityPatch     bool   `json:"node_capacity_patch"`                // 将节点预算作为IO扩展资源发布到 Node status，供调度器按容器资源声明调度
+
+	// 注解校验 webhook 模式（只运行 admission webhook，不做限速）
+	WebhookMode          bool   `json:"webhook_mode"`           // 是否以 validating webhook 模式运行
+	WebhookAddr          string `json:"webhook_addr"`           // webhook HTTPS 监听地址
+	WebhookCertFile      string `json:"webhook_cert_file"`      // TLS 证书文件
+	WebhookKeyFile       string `json:"webhook_key_file"`       // TLS 私钥文件
+	WebhookRejectInvalid bool   `json:"webhook_reject_invalid"` // 拒绝非法或超过上限的注解，为false时只返回警告
+
+	// 按PVC存储类/卷属性类参数对卷所在设备限速（需要访问API Server）
+	StorageClassLimits         bool   `json:"storage_class_limits"`          // 是否启用
+	StorageClassParameterMap   string `json:"storage_class_parameter_map"`   // 限速key到类参数名的映射，如 iops=iops,bps=throughput
+	StorageClassThroughputUnit string `json:"storage_class_throughput_unit"` // BPS参数为纯数字时的单位，如 Mi 表示 MiB/s
+	KubeletRootDir             string `json:"kubelet_root_dir"`              // kubelet 根目录（需挂载到容器内相同路径），用于定位卷的挂载点
+
+	// 独立模式（无kubelet，直接从容器运行时发现容器）
+	StandaloneMode      bool   `json:"standalone_mode"`                 // 是否启用独立模式
+	StandaloneRulesFile string `json:"standalone_rules_file,omitempty"` // 静态规则文件路径（JSON）
 }
 
 // GetDefaultConfig 获取默认配置
@@ -117,13 +240,71 @@ func GetDefaultConfig() *Config {
 		SmartLimitBPSThreshold60m:     0.8,
 		SmartLimitIOPSLimit60m:        0,
 		SmartLimitBPSLimit60m:         0,
+		SmartLimitLimitMode:           "fixed",
+		SmartLimitReadIOPSFraction:    0.8,
+		SmartLimitWriteIOPSFraction:   0.8,
+		SmartLimitReadBPSFraction:     0.8,
+		SmartLimitWriteBPSFraction:    0.8,
+		SmartLimitProportionalMinIOPS: 100,
+		SmartLimitProportionalMinBPS:  1024 * 1024, // 1MB
+		SmartLimitDetector:            "threshold",
+		SmartLimitAnomalyAlpha:        0.05,
+		SmartLimitAnomalySigma:        3,
+		SmartLimitAnomalyReleaseSigma: 1,
+		SmartLimitAnomalySustain:      5,
+		SmartLimitAnomalyWarmup:       30,
+		SmartLimitAnomalyMinIOPS:      100,
+		SmartLimitAnomalyMinBPS:       1024 * 1024, // 1MB
+		SmartLimitContentionUtil:      80,
+		SmartLimitContentionAwait:     50,
+		SmartLimitContentionShare:     0.3,
 		SmartLimitRemoveThreshold:     0.5,
 		SmartLimitRemoveDelay:         5,
 		SmartLimitRemoveCheckInterval: 1,
+		SmartLimitRelaxFactor:         2,
+		SmartLimitRelaxSteps:          3,
+		SmartLimitFlapWindow:          30,
+		SmartLimitFlapCooldown:        5,
+		SmartLimitFlapCooldownMax:     240,
+		SmartLimitStoreDir:            "/var/lib/kubediskguard/smartlimit",
 		DefaultIOPSLimit:              500,
 		DefaultBPSLimit:               10 * 1024 * 1024, // 10MB
 		MaxIOPSLimit:                  2000,
 		MaxBPSLimit:                   100 * 1024 * 1024, // 100MB
+		PodResyncPeriod:               300,
+		PodSyncWorkers:                2,
+		WorkQueueMaxRetries:           5,
+		KubeletPodPollInterval:        10,
+		ProcessInitContainers:         true,
+		ProcessEphemeralContainers:    true,
+		OnExitPolicy:                  "keep",
+		StateSnapshotFile:             "/var/lib/kubediskguard/state.json",
+		ShutdownTimeout:               30,
+		DryRun:                        false,
+		AuditBufferSize:               1000,
+		FilterRulesFile:               "",
+		LimitClassesFile:              "",
+		LimitProfilesFile:             "",
+		ScheduleCheckInterval:         30,
+		NodeReadIOPSBudget:            0,
+		NodeWriteIOPSBudget:           0,
+		NodeReadBPSBudget:             0,
+		NodeWriteBPSBudget:            0,
+		NodeDeviceProfileFile:         "",
+		NodeBudgetPercent:             90,
+		NodeBudgetInterval:            60,
+		NodeCapacityPatch:             false,
+		WebhookMode:                   false,
+		WebhookAddr:                   ":9443",
+		WebhookCertFile:               "/etc/kubediskguard/webhook/tls.crt",
+		WebhookKeyFile:                "/etc/kubediskguard/webhook/tls.key",
+		WebhookRejectInvalid:          true,
+		StorageClassLimits:            false,
+		StorageClassParameterMap:      "iops=iops,bps=throughput",
+		StorageClassThroughputUnit:    "Mi",
+		KubeletRootDir:                "/var/lib/kubelet",
+		StandaloneMode:                false,
+		StandaloneRulesFile:           "",
 	}
 }
 
@@ -275,75 +456,175 @@ func LoadFromEnv(config *Config) {
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_IO_THRESHOLD_15M"); val != "" {
-		if threshold, err := strconv.ParseFloat(val, 64); err == nil {
-			config.SmartLimitIOThreshold15m = threshold
+	// 各时间窗口的阈值和限速值（含按读写方向区分的配置）
+	for _, env := range []struct {
+		name  string
+		field *float64
+	}{
+		{"SMART_LIMIT_IO_THRESHOLD_15M", &config.SmartLimitIOThreshold15m},
+		{"SMART_LIMIT_BPS_THRESHOLD_15M", &config.SmartLimitBPSThreshold15m},
+		{"SMART_LIMIT_READ_IO_THRESHOLD_15M", &config.SmartLimitReadIOThreshold15m},
+		{"SMART_LIMIT_WRITE_IO_THRESHOLD_15M", &config.SmartLimitWriteIOThreshold15m},
+		{"SMART_LIMIT_READ_BPS_THRESHOLD_15M", &config.SmartLimitReadBPSThreshold15m},
+		{"SMART_LIMIT_WRITE_BPS_THRESHOLD_15M", &config.SmartLimitWriteBPSThreshold15m},
+		{"SMART_LIMIT_IO_THRESHOLD_30M", &config.SmartLimitIOThreshold30m},
+		{"SMART_LIMIT_BPS_THRESHOLD_30M", &config.SmartLimitBPSThreshold30m},
+		{"SMART_LIMIT_READ_IO_THRESHOLD_30M", &config.SmartLimitReadIOThreshold30m},
+		{"SMART_LIMIT_WRITE_IO_THRESHOLD_30M", &config.SmartLimitWriteIOThreshold30m},
+		{"SMART_LIMIT_READ_BPS_THRESHOLD_30M", &config.SmartLimitReadBPSThreshold30m},
+		{"SMART_LIMIT_WRITE_BPS_THRESHOLD_30M", &config.SmartLimitWriteBPSThreshold30m},
+		{"SMART_LIMIT_IO_THRESHOLD_60M", &config.SmartLimitIOThreshold60m},
+		{"SMART_LIMIT_BPS_THRESHOLD_60M", &config.SmartLimitBPSThreshold60m},
+		{"SMART_LIMIT_READ_IO_THRESHOLD_60M", &config.SmartLimitReadIOThreshold60m},
+		{"SMART_LIMIT_WRITE_IO_THRESHOLD_60M", &config.SmartLimitWriteIOThreshold60m},
+		{"SMART_LIMIT_READ_BPS_THRESHOLD_60M", &config.SmartLimitReadBPSThreshold60m},
+		{"SMART_LIMIT_WRITE_BPS_THRESHOLD_60M", &config.SmartLimitWriteBPSThreshold60m},
+	} {
+		setFloatFromEnv(env.name, env.field)
+	}
+	for _, env := range []struct {
+		name  string
+		field *int
+	}{
+		{"SMART_LIMIT_IOPS_LIMIT_15M", &config.SmartLimitIOPSLimit15m},
+		{"SMART_LIMIT_BPS_LIMIT_15M", &config.SmartLimitBPSLimit15m},
+		{"SMART_LIMIT_READ_IOPS_LIMIT_15M", &config.SmartLimitReadIOPSLimit15m},
+		{"SMART_LIMIT_WRITE_IOPS_LIMIT_15M", &config.SmartLimitWriteIOPSLimit15m},
+		{"SMART_LIMIT_READ_BPS_LIMIT_15M", &config.SmartLimitReadBPSLimit15m},
+		{"SMART_LIMIT_WRITE_BPS_LIMIT_15M", &config.SmartLimitWriteBPSLimit15m},
+		{"SMART_LIMIT_IOPS_LIMIT_30M", &config.SmartLimitIOPSLimit30m},
+		{"SMART_LIMIT_BPS_LIMIT_30M", &config.SmartLimitBPSLimit30m},
+		{"SMART_LIMIT_READ_IOPS_LIMIT_30M", &config.SmartLimitReadIOPSLimit30m},
+		{"SMART_LIMIT_WRITE_IOPS_LIMIT_30M", &config.SmartLimitWriteIOPSLimit30m},
+		{"SMART_LIMIT_READ_BPS_LIMIT_30M", &config.SmartLimitReadBPSLimit30m},
+		{"SMART_LIMIT_WRITE_BPS_LIMIT_30M", &config.SmartLimitWriteBPSLimit30m},
+		{"SMART_LIMIT_IOPS_LIMIT_60M", &config.SmartLimitIOPSLimit60m},
+		{"SMART_LIMIT_BPS_LIMIT_60M", &config.SmartLimitBPSLimit60m},
+		{"SMART_LIMIT_READ_IOPS_LIMIT_60M", &config.SmartLimitReadIOPSLimit60m},
+		{"SMART_LIMIT_WRITE_IOPS_LIMIT_60M", &config.SmartLimitWriteIOPSLimit60m},
+		{"SMART_LIMIT_READ_BPS_LIMIT_60M", &config.SmartLimitReadBPSLimit60m},
+		{"SMART_LIMIT_WRITE_BPS_LIMIT_60M", &config.SmartLimitWriteBPSLimit60m},
+	} {
+		setIntFromEnv(env.name, env.field)
+	}
+
+	if val := os.Getenv("SMART_LIMIT_LIMIT_MODE"); val != "" {
+		config.SmartLimitLimitMode = val
+	}
+
+	if val := os.Getenv("SMART_LIMIT_READ_IOPS_FRACTION"); val != "" {
+		if fraction, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitReadIOPSFraction = fraction
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_BPS_THRESHOLD_15M"); val != "" {
-		if threshold, err := strconv.ParseFloat(val, 64); err == nil {
-			config.SmartLimitBPSThreshold15m = threshold
+	if val := os.Getenv("SMART_LIMIT_WRITE_IOPS_FRACTION"); val != "" {
+		if fraction, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitWriteIOPSFraction = fraction
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_READ_BPS_FRACTION"); val != "" {
+		if fraction, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitReadBPSFraction = fraction
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_WRITE_BPS_FRACTION"); val != "" {
+		if fraction, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitWriteBPSFraction = fraction
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_IOPS_LIMIT_15M"); val != "" {
+	if val := os.Getenv("SMART_LIMIT_PROPORTIONAL_MIN_IOPS"); val != "" {
 		if iops, err := strconv.Atoi(val); err == nil {
-			config.SmartLimitIOPSLimit15m = iops
+			config.SmartLimitProportionalMinIOPS = iops
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_BPS_LIMIT_15M"); val != "" {
+	if val := os.Getenv("SMART_LIMIT_PROPORTIONAL_MIN_BPS"); val != "" {
 		if bps, err := strconv.Atoi(val); err == nil {
-			config.SmartLimitBPSLimit15m = bps
+			config.SmartLimitProportionalMinBPS = bps
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_IO_THRESHOLD_30M"); val != "" {
-		if threshold, err := strconv.ParseFloat(val, 64); err == nil {
-			config.SmartLimitIOThreshold30m = threshold
+	if val := os.Getenv("SMART_LIMIT_DETECTOR"); val != "" {
+		config.SmartLimitDetector = val
+	}
+
+	if val := os.Getenv("SMART_LIMIT_ANOMALY_ALPHA"); val != "" {
+		if alpha, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitAnomalyAlpha = alpha
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_BPS_THRESHOLD_30M"); val != "" {
-		if threshold, err := strconv.ParseFloat(val, 64); err == nil {
-			config.SmartLimitBPSThreshold30m = threshold
+	if val := os.Getenv("SMART_LIMIT_ANOMALY_SIGMA"); val != "" {
+		if sigma, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitAnomalySigma = sigma
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_ANOMALY_RELEASE_SIGMA"); val != "" {
+		if sigma, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitAnomalyReleaseSigma = sigma
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_ANOMALY_SUSTAIN"); val != "" {
+		if sustain, err := strconv.Atoi(val); err == nil {
+			config.SmartLimitAnomalySustain = sustain
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_IOPS_LIMIT_30M"); val != "" {
+	if val := os.Getenv("SMART_LIMIT_ANOMALY_WARMUP"); val != "" {
+		if warmup, err := strconv.Atoi(val); err == nil {
+			config.SmartLimitAnomalyWarmup = warmup
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_ANOMALY_MIN_IOPS"); val != "" {
 		if iops, err := strconv.Atoi(val); err == nil {
-			config.SmartLimitIOPSLimit30m = iops
+			config.SmartLimitAnomalyMinIOPS = iops
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_BPS_LIMIT_30M"); val != "" {
+	if val := os.Getenv("SMART_LIMIT_ANOMALY_MIN_BPS"); val != "" {
 		if bps, err := strconv.Atoi(val); err == nil {
-			config.SmartLimitBPSLimit30m = bps
+			config.SmartLimitAnomalyMinBPS = bps
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_IO_THRESHOLD_60M"); val != "" {
-		if threshold, err := strconv.ParseFloat(val, 64); err == nil {
-			config.SmartLimitIOThreshold60m = threshold
+	if val := os.Getenv("SMART_LIMIT_TRIGGERS"); val != "" {
+		config.SmartLimitTriggers = val
+	}
+
+	if val := os.Getenv("SMART_LIMIT_CONTENTION_ENABLED"); val != "" {
+		if enabled, err := strconv.ParseBool(val); err == nil {
+			config.SmartLimitContentionEnabled = enabled
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_BPS_THRESHOLD_60M"); val != "" {
-		if threshold, err := strconv.ParseFloat(val, 64); err == nil {
-			config.SmartLimitBPSThreshold60m = threshold
+	if val := os.Getenv("SMART_LIMIT_CONTENTION_UTIL"); val != "" {
+		if util, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitContentionUtil = util
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_IOPS_LIMIT_60M"); val != "" {
-		if iops, err := strconv.Atoi(val); err == nil {
-			config.SmartLimitIOPSLimit60m = iops
+	if val := os.Getenv("SMART_LIMIT_CONTENTION_AWAIT"); val != "" {
+		if await, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitContentionAwait = await
 		}
 	}
 
-	if val := os.Getenv("SMART_LIMIT_BPS_LIMIT_60M"); val != "" {
-		if bps, err := strconv.Atoi(val); err == nil {
-			config.SmartLimitBPSLimit60m = bps
+	if val := os.Getenv("SMART_LIMIT_CONTENTION_SHARE"); val != "" {
+		if share, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitContentionShare = share
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_CONTENTION_LATENCY"); val != "" {
+		if latency, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitContentionLatency = latency
 		}
 	}
 
@@ -364,6 +645,260 @@ func LoadFromEnv(config *Config) {
 			config.SmartLimitRemoveCheckInterval = interval
 		}
 	}
+
+	if val := os.Getenv("SMART_LIMIT_RELAX_FACTOR"); val != "" {
+		if factor, err := strconv.ParseFloat(val, 64); err == nil {
+			config.SmartLimitRelaxFactor = factor
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_RELAX_STEPS"); val != "" {
+		if steps, err := strconv.Atoi(val); err == nil {
+			config.SmartLimitRelaxSteps = steps
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_FLAP_WINDOW"); val != "" {
+		if window, err := strconv.Atoi(val); err == nil {
+			config.SmartLimitFlapWindow = window
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_FLAP_COOLDOWN"); val != "" {
+		if cooldown, err := strconv.Atoi(val); err == nil {
+			config.SmartLimitFlapCooldown = cooldown
+		}
+	}
+
+	if val := os.Getenv("SMART_LIMIT_FLAP_COOLDOWN_MAX"); val != "" {
+		if cooldown, err := strconv.Atoi(val); err == nil {
+			config.SmartLimitFlapCooldownMax = cooldown
+		}
+	}
+
+	// 显式设置为空时关闭持久化
+	if val, ok := os.LookupEnv("SMART_LIMIT_STORE_DIR"); ok {
+		config.SmartLimitStoreDir = val
+	}
+
+	if val := os.Getenv("POD_RESYNC_PERIOD"); val != "" {
+		if period, err := strconv.Atoi(val); err == nil {
+			config.PodResyncPeriod = period
+		}
+	}
+
+	if val := os.Getenv("POD_SYNC_WORKERS"); val != "" {
+		if workers, err := strconv.Atoi(val); err == nil {
+			config.PodSyncWorkers = workers
+		}
+	}
+
+	if val := os.Getenv("WORKQUEUE_MAX_RETRIES"); val != "" {
+		if retries, err := strconv.Atoi(val); err == nil {
+			config.WorkQueueMaxRetries = retries
+		}
+	}
+
+	if val := os.Getenv("KUBELET_POD_POLL_INTERVAL"); val != "" {
+		// 轮询间隔必须为正数，非法值保留默认值
+		if interval, err := strconv.Atoi(val); err == nil && interval > 0 {
+			config.KubeletPodPollInterval = interval
+		}
+	}
+
+	if val := os.Getenv("PROCESS_INIT_CONTAINERS"); val != "" {
+		if process, err := strconv.ParseBool(val); err == nil {
+			config.ProcessInitContainers = process
+		}
+	}
+
+	if val := os.Getenv("PROCESS_EPHEMERAL_CONTAINERS"); val != "" {
+		if process, err := strconv.ParseBool(val); err == nil {
+			config.ProcessEphemeralContainers = process
+		}
+	}
+
+	if val := os.Getenv("ON_EXIT_POLICY"); val != "" {
+		config.OnExitPolicy = val
+	}
+
+	if val := os.Getenv("STATE_SNAPSHOT_FILE"); val != "" {
+		config.StateSnapshotFile = val
+	}
+
+	if val := os.Getenv("SHUTDOWN_TIMEOUT"); val != "" {
+		if timeout, err := strconv.Atoi(val); err == nil {
+			config.ShutdownTimeout = timeout
+		}
+	}
+
+	if val := os.Getenv("DRY_RUN"); val != "" {
+		if dryRun, err := strconv.ParseBool(val); err == nil {
+			config.DryRun = dryRun
+		}
+	}
+
+	if val := os.Getenv("AUDIT_BUFFER_SIZE"); val != "" {
+		if size, err := strconv.Atoi(val); err == nil {
+			config.AuditBufferSize = size
+		}
+	}
+
+	if val := os.Getenv("FILTER_RULES_FILE"); val != "" {
+		config.FilterRulesFile = val
+	}
+
+	if val := os.Getenv("LIMIT_CLASSES_FILE"); val != "" {
+		config.LimitClassesFile = val
+	}
+
+	if val := os.Getenv("LIMIT_PROFILES_FILE"); val != "" {
+		config.LimitProfilesFile = val
+	}
+
+	if val := os.Getenv("SCHEDULE_CHECK_INTERVAL"); val != "" {
+		if interval, err := strconv.Atoi(val); err == nil {
+			config.ScheduleCheckInterval = interval
+		}
+	}
+
+	if val := os.Getenv("NODE_READ_IOPS_BUDGET"); val != "" {
+		if budget, err := strconv.Atoi(val); err == nil {
+			config.NodeReadIOPSBudget = budget
+		}
+	}
+
+	if val := os.Getenv("NODE_WRITE_IOPS_BUDGET"); val != "" {
+		if budget, err := strconv.Atoi(val); err == nil {
+			config.NodeWriteIOPSBudget = budget
+		}
+	}
+
+	if val := os.Getenv("NODE_READ_BPS_BUDGET"); val != "" {
+		if budget, err := strconv.Atoi(val); err == nil {
+			config.NodeReadBPSBudget = budget
+		}
+	}
+
+	if val := os.Getenv("NODE_WRITE_BPS_BUDGET"); val != "" {
+		if budget, err := strconv.Atoi(val); err == nil {
+			config.NodeWriteBPSBudget = budget
+		}
+	}
+
+	if val := os.Getenv("NODE_DEVICE_PROFILE_FILE"); val != "" {
+		config.NodeDeviceProfileFile = val
+	}
+
+	if val := os.Getenv("NODE_BUDGET_PERCENT"); val != "" {
+		if percent, err := strconv.Atoi(val); err == nil {
+			config.NodeBudgetPercent = percent
+		}
+	}
+
+	if val := os.Getenv("NODE_BUDGET_INTERVAL"); val != "" {
+		if interval, err := strconv.Atoi(val); err == nil {
+			config.NodeBudgetInterval = interval
+		}
+	}
+
+	if val := os.Getenv("NODE_CAPACITY_PATCH"); val != "" {
+		if enabled, err := strconv.ParseBool(val); err == nil {
+			config.NodeCapacityPatch = enabled
+		}
+	}
+
+	if val := os.Getenv("MAX_IOPS_LIMIT"); val != "" {
+		if iops, err := strconv.Atoi(val); err == nil {
+			config.MaxIOPSLimit = iops
+		}
+	}
+
+	if val := os.Getenv("MAX_BPS_LIMIT"); val != "" {
+		if bps, err := strconv.Atoi(val); err == nil {
+			config.MaxBPSLimit = bps
+		}
+	}
+
+	if val := os.Getenv("WEBHOOK_MODE"); val != "" {
+		if enabled, err := strconv.ParseBool(val); err == nil {
+			config.WebhookMode = enabled
+		}
+	}
+
+	if val := os.Getenv("WEBHOOK_ADDR"); val != "" {
+		config.WebhookAddr = val
+	}
+
+	if val := os.Getenv("WEBHOOK_TLS_CERT_FILE"); val != "" {
+		config.WebhookCertFile = val
+	}
+
+	if val := os.Getenv("WEBHOOK_TLS_KEY_FILE"); val != "" {
+		config.WebhookKeyFile = val
+	}
+
+	if val := os.Getenv("WEBHOOK_REJECT_INVALID"); val != "" {
+		if reject, err := strconv.ParseBool(val); err == nil {
+			config.WebhookRejectInvalid = reject
+		}
+	}
+
+	if val := os.Getenv("STORAGE_CLASS_LIMITS"); val != "" {
+		if enabled, err := strconv.ParseBool(val); err == nil {
+			config.StorageClassLimits = enabled
+		}
+	}
+
+	if val := os.Getenv("STORAGE_CLASS_PARAMETER_MAP"); val != "" {
+		config.StorageClassParameterMap = val
+	}
+
+	if val := os.Getenv("STORAGE_CLASS_THROUGHPUT_UNIT"); val != "" {
+		config.StorageClassThroughputUnit = val
+	}
+
+	if val := os.Getenv("KUBELET_ROOT_DIR"); val != "" {
+		config.KubeletRootDir = val
+	}
+
+	if val := os.Getenv("STANDALONE_MODE"); val != "" {
+		if enabled, err := strconv.ParseBool(val); err == nil {
+			config.StandaloneMode = enabled
+		}
+	}
+
+	if val := os.Getenv("STANDALONE_RULES_FILE"); val != "" {
+		config.StandaloneRulesFile = val
+	}
+}
+
+// setFloatFromEnv 环境变量已设置时解析为浮点数写入 field，无法解析时记录日志并保留原值
+func setFloatFromEnv(name string, field *float64) {
+	val := os.Getenv(name)
+	if val == "" {
+		return
+	}
+	v, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q, expected a number: %v", name, val, err)
+		return
+	}
+	*field = v
+}
+
+// setIntFromEnv 环境变量已设置时解析为整数写入 field，无法解析时记录日志并保留原值
+func setIntFromEnv(name string, field *int) {
+	val := os.Getenv(name)
+	if val == "" {
+		return
+	}
+	v, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q, expected an integer: %v", name, val, err)
+		return
+	}
+	*field = v
 }
 
 // ToJSON 将配置转换为JSON字符串
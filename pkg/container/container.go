@@ -1,5 +1,7 @@
 package container
 
+import "context"
+
 // ContainerInfo 容器信息结构体
 type ContainerInfo struct {
 	ID           string
@@ -7,6 +9,19 @@ type ContainerInfo struct {
 	Name         string
 	CgroupParent string
 	Annotations  map[string]string
+	Labels       map[string]string // 运行时原始标签（独立模式下用于读取限速配置）
+}
+
+// 容器事件类型
+const (
+	ContainerEventStart = "start"
+	ContainerEventStop  = "stop"
+)
+
+// ContainerEvent 运行时容器事件
+type ContainerEvent struct {
+	Type        string
+	ContainerID string
 }
 
 // Runtime 容器运行时接口
@@ -24,4 +39,12 @@ type Runtime interface {
 	SetLimits(container *ContainerInfo, riops, wiops, rbps, wbps int) error
 	// 解除所有限速
 	ResetLimits(container *ContainerInfo) error
+	// 对指定设备（major:minor）设置/解除限速，用于数据盘以外的设备
+	SetDeviceLimits(container *ContainerInfo, majMin string, riops, wiops, rbps, wbps int) error
+	ResetDeviceLimits(container *ContainerInfo, majMin string) error
+
+	// ListContainers 列出运行中的容器（独立模式使用）
+	ListContainers() ([]*ContainerInfo, error)
+	// WatchContainerEvents 监听容器启动/停止事件，ctx取消后两个通道均关闭
+	WatchContainerEvents(ctx context.Context) (<-chan ContainerEvent, <-chan error)
 }
@@ -2,8 +2,12 @@ package device
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"golang.org/x/sys/unix"
 )
 
 // GetMajMin 获取设备主次设备号
@@ -53,3 +57,30 @@ func GetMajMin(dataMount string) (string, error) {
 
 	return majMin, nil
 }
+
+// GetPathMajMin 获取路径所在块设备的主次设备号：块设备文件（Block模式的卷）取其自身设备号，
+// 其他路径取所在文件系统的设备号；分区会换算为其所属的整盘（cgroup只接受整盘设备号）
+func GetPathMajMin(path string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return "", fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	dev := st.Dev
+	if st.Mode&unix.S_IFMT == unix.S_IFBLK {
+		dev = st.Rdev
+	}
+	majMin := fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev))
+
+	sysPath, err := filepath.EvalSymlinks(filepath.Join("/sys/dev/block", majMin))
+	if err != nil {
+		return "", fmt.Errorf("device %s of %s is not a block device: %v", majMin, path, err)
+	}
+	if _, err := os.Stat(filepath.Join(sysPath, "partition")); err != nil {
+		return majMin, nil
+	}
+	parent, err := os.ReadFile(filepath.Join(filepath.Dir(sysPath), "dev"))
+	if err != nil {
+		return "", fmt.Errorf("failed to get parent device of partition %s: %v", majMin, err)
+	}
+	return strings.TrimSpace(string(parent)), nil
+}
@@ -0,0 +1,45 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeDiskStats 写入包含 loop0 和 sda 两个设备的 diskstats 文件
+func writeDiskStats(t *testing.T, file string, reads, readSectors, readMs, writes, writeSectors, writeMs, ioMs, weightedMs int) {
+	content := "   7       0 loop0 1 0 8 0 0 0 0 0 0 0 0 0 0 0 0\n" +
+		fmt.Sprintf("   8       0 sda %d 0 %d %d %d 0 %d %d 2 %d %d 0 0 0 0\n", reads, readSectors, readMs, writes, writeSectors, writeMs, ioMs, weightedMs)
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+}
+
+func TestCollectorSample(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "diskstats")
+	writeDiskStats(t, file, 1000, 8000, 500, 2000, 16000, 3000, 10000, 20000)
+
+	c := NewCollector(file, "8:0")
+	now := time.Now()
+	_, ok, err := c.Sample(now)
+	assert.NoError(t, err)
+	assert.False(t, ok, "first sample only records counters")
+
+	// 10秒内读1000次（4MiB）、写3000次（12MiB），设备忙碌9秒，IO耗时共40秒
+	writeDiskStats(t, file, 2000, 16192, 10500, 5000, 40576, 33000, 19000, 60000)
+	u, ok, err := c.Sample(now.Add(10 * time.Second))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "sda", u.Name)
+	assert.InDelta(t, 90, u.Utilization, 0.01)
+	assert.InDelta(t, 4, u.QueueDepth, 0.01)
+	assert.InDelta(t, 10, u.Await, 0.01)
+	assert.InDelta(t, 400, u.IOPS(), 0.01)
+	assert.InDelta(t, 100, u.ReadIOPS, 0.01)
+	assert.InDelta(t, 1.6*1024*1024, u.BPS(), 1)
+
+	_, _, err = NewCollector(file, "253:0").Sample(now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"KubeDiskGuard/pkg/limits"
+)
+
+// Profile 数据盘的实测性能（如 fio 测得的最大IOPS/BPS），BPS 支持单位（如 500M、1Gi）
+type Profile struct {
+	ReadIOPS  int    `json:"read_iops,omitempty"`
+	WriteIOPS int    `json:"write_iops,omitempty"`
+	ReadBPS   string `json:"read_bps,omitempty"`
+	WriteBPS  string `json:"write_bps,omitempty"`
+}
+
+// LoadProfile 从JSON文件加载设备性能，返回换算后的能力值（0表示未测）
+func LoadProfile(file string) (limits.Limits, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return limits.Limits{}, fmt.Errorf("failed to read device profile %s: %v", file, err)
+	}
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return limits.Limits{}, fmt.Errorf("failed to parse device profile %s: %v", file, err)
+	}
+	if p.ReadIOPS < 0 || p.WriteIOPS < 0 {
+		return limits.Limits{}, fmt.Errorf("device profile %s iops must not be negative", file)
+	}
+	capacity := limits.Limits{ReadIOPS: p.ReadIOPS, WriteIOPS: p.WriteIOPS}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *int
+	}{{"read_bps", p.ReadBPS, &capacity.ReadBPS}, {"write_bps", p.WriteBPS, &capacity.WriteBPS}} {
+		if f.value == "" {
+			continue
+		}
+		bps, err := limits.ParseBPS(f.value)
+		if err != nil {
+			return limits.Limits{}, fmt.Errorf("device profile %s %s: %v", file, f.name, err)
+		}
+		*f.dst = bps
+	}
+	return capacity, nil
+}
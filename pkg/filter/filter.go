@@ -0,0 +1,259 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// 规则动作
+const (
+	ActionInclude = "include" // 处理（限速）
+	ActionExclude = "exclude" // 跳过
+)
+
+// DefaultRuleName 未命中任何规则时 Result.Rule 的取值
+const DefaultRuleName = "default"
+
+// Rule 过滤规则，所有设置了的条件需同时满足才算命中
+// 正则均为完整匹配（自动加 ^ 和 $），避免 pause 这类关键字误匹配无关镜像
+type Rule struct {
+	Name               string   `json:"name"`                           // 规则名称，出现在API和日志中
+	Action             string   `json:"action"`                         // include 或 exclude
+	Namespaces         []string `json:"namespaces,omitempty"`           // 命名空间名称列表
+	NamespaceSelector  string   `json:"namespace_selector,omitempty"`   // 命名空间标签选择器，如 env=prod
+	PodSelector        string   `json:"pod_selector,omitempty"`         // Pod标签选择器（独立模式下匹配容器标签）
+	ImageRegex         string   `json:"image_regex,omitempty"`          // 镜像正则，如 (registry\.k8s\.io|k8s\.gcr\.io)/pause:.*
+	ContainerNameRegex string   `json:"container_name_regex,omitempty"` // 容器名正则，如 istio-proxy|linkerd-proxy
+	Keywords           []string `json:"keywords,omitempty"`             // 镜像或容器名包含任一关键字（兼容 EXCLUDE_KEYWORDS）
+
+	nsSelector  labels.Selector
+	podSelector labels.Selector
+	imageRe     *regexp.Regexp
+	nameRe      *regexp.Regexp
+}
+
+// RuleSet 过滤规则集合，按顺序匹配，首个命中的规则决定是否处理
+type RuleSet struct {
+	DefaultAction string `json:"default_action,omitempty"` // 未命中任何规则时的动作，默认 include
+	Rules         []Rule `json:"rules"`
+}
+
+// Target 待判断的对象，Pod级判断时容器字段为空
+type Target struct {
+	Namespace       string
+	NamespaceLabels map[string]string
+	PodLabels       map[string]string
+	ContainerName   string
+	Image           string
+}
+
+// Result 过滤结果
+type Result struct {
+	Action string `json:"action"`
+	Rule   string `json:"rule"` // 命中的规则名，未命中时为 default
+}
+
+// Decision 单个容器的过滤结果，供API展示命中的规则
+type Decision struct {
+	Namespace     string `json:"namespace,omitempty"`
+	PodName       string `json:"pod_name,omitempty"`
+	ContainerName string `json:"container_name"`
+	ContainerID   string `json:"container_id,omitempty"`
+	Image         string `json:"image,omitempty"`
+	Result
+}
+
+// Included 是否需要处理
+func (r Result) Included() bool {
+	return r.Action != ActionExclude
+}
+
+// LoadRules 从JSON文件加载过滤规则
+func LoadRules(file string) (*RuleSet, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filter rules file %s: %v", file, err)
+	}
+	var rs RuleSet
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("failed to parse filter rules file %s: %v", file, err)
+	}
+	if err := rs.compile(); err != nil {
+		return nil, fmt.Errorf("invalid filter rules file %s: %v", file, err)
+	}
+	return &rs, nil
+}
+
+// LegacyRules 将 EXCLUDE_NAMESPACES、EXCLUDE_LABEL_SELECTOR、EXCLUDE_KEYWORDS 转换为等价的排除规则
+// 标签选择器无法解析时忽略该条件，与旧版本行为一致
+func LegacyRules(keywords, namespaces []string, labelSelector string) *RuleSet {
+	rs := &RuleSet{}
+	if len(namespaces) > 0 {
+		rs.Rules = append(rs.Rules, Rule{Name: "exclude-namespaces", Action: ActionExclude, Namespaces: namespaces})
+	}
+	if labelSelector != "" {
+		if _, err := labels.Parse(labelSelector); err == nil {
+			rs.Rules = append(rs.Rules, Rule{Name: "exclude-label-selector", Action: ActionExclude, PodSelector: labelSelector})
+		}
+	}
+	if len(keywords) > 0 {
+		rs.Rules = append(rs.Rules, Rule{Name: "exclude-keywords", Action: ActionExclude, Keywords: keywords})
+	}
+	// 以上条件均已校验，compile 不会失败
+	_ = rs.compile()
+	return rs
+}
+
+// compile 校验规则并预编译选择器和正则
+func (rs *RuleSet) compile() error {
+	switch rs.DefaultAction {
+	case "":
+		rs.DefaultAction = ActionInclude
+	case ActionInclude, ActionExclude:
+	default:
+		return fmt.Errorf("invalid default_action %q", rs.DefaultAction)
+	}
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if r.Action != ActionInclude && r.Action != ActionExclude {
+			return fmt.Errorf("rule %d (%s) has invalid action %q", i, r.Name, r.Action)
+		}
+		if !r.hasPodConditions() && !r.hasContainerConditions() {
+			return fmt.Errorf("rule %d (%s) must set at least one condition", i, r.Name)
+		}
+		var err error
+		if r.nsSelector, err = parseSelector(r.NamespaceSelector); err != nil {
+			return fmt.Errorf("rule %d (%s) namespace_selector: %v", i, r.Name, err)
+		}
+		if r.podSelector, err = parseSelector(r.PodSelector); err != nil {
+			return fmt.Errorf("rule %d (%s) pod_selector: %v", i, r.Name, err)
+		}
+		if r.imageRe, err = compileAnchored(r.ImageRegex); err != nil {
+			return fmt.Errorf("rule %d (%s) image_regex: %v", i, r.Name, err)
+		}
+		if r.nameRe, err = compileAnchored(r.ContainerNameRegex); err != nil {
+			return fmt.Errorf("rule %d (%s) container_name_regex: %v", i, r.Name, err)
+		}
+	}
+	return nil
+}
+
+func parseSelector(selector string) (labels.Selector, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	return labels.Parse(selector)
+}
+
+// compileAnchored 编译完整匹配的正则
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// UsesNamespaceSelector 是否有规则依赖命名空间标签
+func (rs *RuleSet) UsesNamespaceSelector() bool {
+	if rs == nil {
+		return false
+	}
+	for i := range rs.Rules {
+		if rs.Rules[i].nsSelector != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) hasPodConditions() bool {
+	return len(r.Namespaces) > 0 || r.NamespaceSelector != "" || r.PodSelector != ""
+}
+
+func (r *Rule) hasContainerConditions() bool {
+	return r.ImageRegex != "" || r.ContainerNameRegex != "" || len(r.Keywords) > 0
+}
+
+// matchPod 判断Pod级条件（未设置的条件视为满足）
+func (r *Rule) matchPod(t Target) bool {
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, t.Namespace) {
+		return false
+	}
+	if r.nsSelector != nil && !r.nsSelector.Matches(labels.Set(t.NamespaceLabels)) {
+		return false
+	}
+	if r.podSelector != nil && !r.podSelector.Matches(labels.Set(t.PodLabels)) {
+		return false
+	}
+	return true
+}
+
+// matchContainer 判断容器级条件（未设置的条件视为满足）
+func (r *Rule) matchContainer(t Target) bool {
+	if r.imageRe != nil && !r.imageRe.MatchString(t.Image) {
+		return false
+	}
+	if r.nameRe != nil && !r.nameRe.MatchString(t.ContainerName) {
+		return false
+	}
+	if len(r.Keywords) > 0 {
+		matched := false
+		for _, keyword := range r.Keywords {
+			if strings.Contains(t.Image, keyword) || strings.Contains(t.ContainerName, keyword) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// EvaluatePod Pod级判断：返回首个Pod级条件满足的规则的结果
+// 若该规则还带有容器级条件，无法在Pod级判定，返回 decided=false，由 EvaluateContainer 逐个容器判断；nil 规则集不过滤
+func (rs *RuleSet) EvaluatePod(t Target) (result Result, decided bool) {
+	if rs == nil {
+		return Result{Action: ActionInclude, Rule: DefaultRuleName}, true
+	}
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if !r.matchPod(t) {
+			continue
+		}
+		if r.hasContainerConditions() {
+			return Result{}, false
+		}
+		return Result{Action: r.Action, Rule: r.Name}, true
+	}
+	return Result{Action: rs.DefaultAction, Rule: DefaultRuleName}, true
+}
+
+// EvaluateContainer 容器级判断：返回首个全部条件满足的规则的结果，未命中时使用默认动作；nil 规则集不过滤
+func (rs *RuleSet) EvaluateContainer(t Target) Result {
+	if rs == nil {
+		return Result{Action: ActionInclude, Rule: DefaultRuleName}
+	}
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if r.matchPod(t) && r.matchContainer(t) {
+			return Result{Action: r.Action, Rule: r.Name}
+		}
+	}
+	return Result{Action: rs.DefaultAction, Rule: DefaultRuleName}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAndEvaluateRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "filters.json")
+	content := `{"default_action": "exclude", "rules": [
+		{"name": "sidecars", "action": "exclude", "container_name_regex": "istio-proxy|linkerd-proxy"},
+		{"name": "pause", "action": "exclude", "image_regex": "registry\\.k8s\\.io/pause:.*"},
+		{"name": "debug-pods", "action": "exclude", "pod_selector": "debug=true"},
+		{"name": "prod", "action": "include", "namespace_selector": "env=prod"},
+		{"name": "batch", "action": "include", "namespaces": ["batch"]}
+	]}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := LoadRules(file)
+	assert.NoError(t, err)
+
+	prod := map[string]string{"env": "prod"}
+	cases := []struct {
+		name     string
+		target   Target
+		expected Result
+	}{
+		{"include by namespace selector", Target{Namespace: "web", NamespaceLabels: prod, ContainerName: "app", Image: "nginx:1.25"}, Result{ActionInclude, "prod"}},
+		{"include by namespace name", Target{Namespace: "batch", ContainerName: "job", Image: "busybox"}, Result{ActionInclude, "batch"}},
+		{"sidecar excluded first", Target{Namespace: "batch", ContainerName: "istio-proxy", Image: "istio/proxyv2"}, Result{ActionExclude, "sidecars"}},
+		// 正则完整匹配，名字中只是包含关键字的容器不会被误排除
+		{"regex is anchored", Target{Namespace: "batch", ContainerName: "my-istio-proxy-tool", Image: "my-pause-image:latest"}, Result{ActionInclude, "batch"}},
+		{"exclude by pod selector", Target{Namespace: "batch", PodLabels: map[string]string{"debug": "true"}, ContainerName: "app"}, Result{ActionExclude, "debug-pods"}},
+		{"default action", Target{Namespace: "dev", ContainerName: "app"}, Result{ActionExclude, DefaultRuleName}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, rs.EvaluateContainer(tc.target))
+		})
+	}
+
+	// 首个Pod级匹配的规则带容器级条件时，Pod级无法判定
+	_, decided := rs.EvaluatePod(Target{Namespace: "batch"})
+	assert.False(t, decided)
+	assert.True(t, rs.UsesNamespaceSelector())
+}
+
+func TestEvaluatePod(t *testing.T) {
+	rs := LegacyRules([]string{"pause"}, []string{"kube-system"}, "app=system")
+
+	res, decided := rs.EvaluatePod(Target{Namespace: "kube-system"})
+	assert.True(t, decided)
+	assert.Equal(t, Result{ActionExclude, "exclude-namespaces"}, res)
+
+	res, decided = rs.EvaluatePod(Target{Namespace: "default", PodLabels: map[string]string{"app": "system"}})
+	assert.True(t, decided)
+	assert.Equal(t, Result{ActionExclude, "exclude-label-selector"}, res)
+
+	// 关键字规则只有容器级条件，Pod级无法判定
+	_, decided = rs.EvaluatePod(Target{Namespace: "default"})
+	assert.False(t, decided)
+	assert.Equal(t, Result{ActionExclude, "exclude-keywords"}, rs.EvaluateContainer(Target{Namespace: "default", Image: "my-pause-image"}))
+	assert.True(t, rs.EvaluateContainer(Target{Namespace: "default", Image: "nginx"}).Included())
+
+	// 无法解析的标签选择器被忽略
+	assert.Empty(t, LegacyRules(nil, nil, "app in (").Rules)
+
+	// nil 规则集不过滤
+	var none *RuleSet
+	result, decided := none.EvaluatePod(Target{Namespace: "kube-system"})
+	assert.True(t, decided)
+	assert.True(t, result.Included())
+	assert.True(t, none.EvaluateContainer(Target{Image: "pause"}).Included())
+	assert.False(t, none.UsesNamespaceSelector())
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"action":    `{"rules": [{"name": "x", "action": "skip", "namespaces": ["a"]}]}`,
+		"condition": `{"rules": [{"name": "x", "action": "exclude"}]}`,
+		"regex":     `{"rules": [{"name": "x", "action": "exclude", "image_regex": "("}]}`,
+		"selector":  `{"rules": [{"name": "x", "action": "exclude", "pod_selector": "app in ("}]}`,
+		"default":   `{"default_action": "skip", "rules": []}`,
+	} {
+		file := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadRules(file)
+		assert.Error(t, err, name)
+	}
+}
@@ -2,19 +2,25 @@ package kubeclient
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"KubeDiskGuard/pkg/cadvisor"
 	"KubeDiskGuard/pkg/config"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -33,14 +39,18 @@ type KubeClient struct {
 	KubeletTokenPath  string
 	KubeletServerName string
 	SATokenPath       string
-	RestConfig        *rest.Config // 保存 kubeconfig 配置，用于提取认证信息
+	RestConfig        *rest.Config  // 保存 kubeconfig 配置，用于提取认证信息
+	PodPollInterval   time.Duration // kubelet API 模式下轮询 /pods 的间隔
 	cadvisorCalc      *cadvisor.Calculator
 }
 
 // IKubeClient 接口，便于mock
 type IKubeClient interface {
 	ListNodePodsWithKubeletFirst() ([]corev1.Pod, error)
-	WatchNodePods() (watch.Interface, error)
+	NodePodListWatcher() (cache.ListerWatcher, error)
+	NamespaceListWatcher() (cache.ListerWatcher, error)
+	GetClaimStorage(namespace, claimName string) (*ClaimStorage, error)
+	PatchNodeCapacity(capacity corev1.ResourceList) error
 	GetPod(namespace, name string) (*corev1.Pod, error)
 	UpdatePod(pod *corev1.Pod) (*corev1.Pod, error)
 	GetNodeSummary() (*NodeSummary, error)
@@ -167,6 +177,7 @@ func NewKubeClientWithConfig(nodeName, kubeconfigPath string, cfg *config.Config
 		KubeletServerName: cfg.KubeletServerName,
 		SATokenPath:       saTokenPath,
 		RestConfig:        restConfig,
+		PodPollInterval:   time.Duration(cfg.KubeletPodPollInterval) * time.Second,
 		cadvisorCalc:      cadvisor.NewCalculator(),
 	}, nil
 }
@@ -305,16 +316,132 @@ func (k *KubeClient) ListNodePodsWithKubeletFirst() ([]corev1.Pod, error) {
 	return k.ListNodePods()
 }
 
-// WatchNodePods 监听本节点Pod事件
-func (k *KubeClient) WatchNodePods() (watch.Interface, error) {
+// NodePodListWatcher 返回按 spec.nodeName 过滤的Pod ListerWatcher，供SharedInformer使用
+// kubelet API 模式下（无clientset）返回基于kubelet /pods 轮询的 ListerWatcher
+func (k *KubeClient) NodePodListWatcher() (cache.ListerWatcher, error) {
 	if k.Clientset == nil {
-		return nil, fmt.Errorf("kubernetes clientset is nil, cannot watch pods")
+		log.Printf("[KubeClient] Kubernetes clientset is nil, tracking pods by polling kubelet /pods every %v", k.PodPollInterval)
+		return newKubeletPodListWatch(k.GetNodePodsFromKubelet, k.PodPollInterval), nil
 	}
-	fieldSelector := fields.OneTermEqualSelector("spec.nodeName", k.NodeName).String()
-	return k.Clientset.CoreV1().Pods("").Watch(context.TODO(), metav1.ListOptions{
-		FieldSelector: fieldSelector,
-		Watch:         true,
-	})
+	fieldSelector := fields.OneTermEqualSelector("spec.nodeName", k.NodeName)
+	return cache.NewListWatchFromClient(k.Clientset.CoreV1().RESTClient(), "pods", metav1.NamespaceAll, fieldSelector), nil
+}
+
+// NamespaceListWatcher 返回Namespace的ListerWatcher，用于读取命名空间级默认限速注解
+// kubelet API 模式下无法访问API Server，返回错误
+func (k *KubeClient) NamespaceListWatcher() (cache.ListerWatcher, error) {
+	if k.Clientset == nil {
+		return nil, fmt.Errorf("kubernetes clientset is nil, cannot watch namespaces")
+	}
+	return cache.NewListWatchFromClient(k.Clientset.CoreV1().RESTClient(), "namespaces", metav1.NamespaceAll, fields.Everything()), nil
+}
+
+// ClaimStorage PVC绑定的卷及其存储类、卷属性类参数
+type ClaimStorage struct {
+	VolumeName                      string
+	StorageClassName                string
+	StorageClassParameters          map[string]string
+	VolumeAttributesClassName       string
+	VolumeAttributesClassParameters map[string]string
+}
+
+// volumeAttributesClassVersions 依次尝试的 VolumeAttributesClass API 版本（v1.34 GA，之前为 beta/alpha）
+var volumeAttributesClassVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+// GetClaimStorage 获取PVC绑定的卷、存储类参数和卷属性类参数，PVC未绑定时返回错误
+// 卷属性类优先使用 status 中已生效的类，字段以原始JSON读取，兼容不支持该字段的client-go版本
+func (k *KubeClient) GetClaimStorage(namespace, claimName string) (*ClaimStorage, error) {
+	if k.Clientset == nil {
+		return nil, fmt.Errorf("kubernetes clientset is nil, cannot get persistent volume claim")
+	}
+	ctx := context.TODO()
+	raw, err := k.Clientset.CoreV1().RESTClient().Get().Namespace(namespace).Resource("persistentvolumeclaims").Name(claimName).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get persistent volume claim %s/%s: %v", namespace, claimName, err)
+	}
+	var claim struct {
+		Spec struct {
+			VolumeName                string  `json:"volumeName"`
+			StorageClassName          *string `json:"storageClassName"`
+			VolumeAttributesClassName *string `json:"volumeAttributesClassName"`
+		} `json:"spec"`
+		Status struct {
+			Phase                            string  `json:"phase"`
+			CurrentVolumeAttributesClassName *string `json:"currentVolumeAttributesClassName"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &claim); err != nil {
+		return nil, fmt.Errorf("failed to parse persistent volume claim %s/%s: %v", namespace, claimName, err)
+	}
+	if claim.Status.Phase != string(corev1.ClaimBound) || claim.Spec.VolumeName == "" {
+		return nil, fmt.Errorf("persistent volume claim %s/%s is not bound", namespace, claimName)
+	}
+
+	result := &ClaimStorage{VolumeName: claim.Spec.VolumeName}
+	if claim.Spec.StorageClassName != nil && *claim.Spec.StorageClassName != "" {
+		result.StorageClassName = *claim.Spec.StorageClassName
+		sc, err := k.Clientset.StorageV1().StorageClasses().Get(ctx, result.StorageClassName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get storage class %s: %v", result.StorageClassName, err)
+		}
+		result.StorageClassParameters = sc.Parameters
+	}
+
+	vacName := claim.Status.CurrentVolumeAttributesClassName
+	if vacName == nil {
+		vacName = claim.Spec.VolumeAttributesClassName
+	}
+	if vacName == nil || *vacName == "" {
+		return result, nil
+	}
+	result.VolumeAttributesClassName = *vacName
+	for _, version := range volumeAttributesClassVersions {
+		raw, err := k.Clientset.Discovery().RESTClient().Get().AbsPath("/apis/storage.k8s.io", version, "volumeattributesclasses", *vacName).DoRaw(ctx)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get volume attributes class %s: %v", *vacName, err)
+		}
+		var vac struct {
+			Parameters map[string]string `json:"parameters"`
+		}
+		if err := json.Unmarshal(raw, &vac); err != nil {
+			return nil, fmt.Errorf("failed to parse volume attributes class %s: %v", *vacName, err)
+		}
+		result.VolumeAttributesClassParameters = vac.Parameters
+		return result, nil
+	}
+	return nil, fmt.Errorf("volume attributes class %s not found", *vacName)
+}
+
+// PatchNodeCapacity 在本节点 status.capacity 中发布扩展资源（kubelet 会同步到 allocatable），已存在的同名资源被覆盖
+func (k *KubeClient) PatchNodeCapacity(capacity corev1.ResourceList) error {
+	if k.Clientset == nil {
+		return fmt.Errorf("kubernetes clientset is nil, cannot patch node status")
+	}
+	names := make([]string, 0, len(capacity))
+	for name := range capacity {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	patch := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		q := capacity[corev1.ResourceName(name)]
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  "/status/capacity/" + strings.ReplaceAll(name, "/", "~1"),
+			"value": q.String(),
+		})
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to encode node capacity patch: %v", err)
+	}
+	if _, err := k.Clientset.CoreV1().Nodes().Patch(context.TODO(), k.NodeName, types.JSONPatchType, data, metav1.PatchOptions{}, "status"); err != nil {
+		return fmt.Errorf("failed to patch node %s status: %v", k.NodeName, err)
+	}
+	return nil
 }
 
 // GetPod 获取指定命名空间和名称的Pod
@@ -24,8 +24,9 @@ type IOStats struct {
 	WriteIOPS    int64
 	ReadBPS      int64
 	WriteBPS     int64
-	ReadLatency  int64 // 平均读取延迟（微秒）
-	WriteLatency int64 // 平均写入延迟（微秒）
+	ReadLatency  int64   // 平均读取延迟（微秒）
+	WriteLatency int64   // 平均写入延迟（微秒）
+	IOTime       float64 // 累积加权IO时间（秒），来自cAdvisor，用于与上次采集的差值计算平均延迟
 }
 
 // ContainerStats 容器统计信息（来自kubelet API）
@@ -390,5 +391,8 @@ func (k *KubeClient) ConvertCadvisorToIOStats(metrics *cadvisor.CadvisorMetrics,
 		WriteIOPS:   int64(rate.WriteIOPS),
 		ReadBPS:     int64(rate.ReadBPS),
 		WriteBPS:    int64(rate.WriteBPS),
+		// cAdvisor的加权IO时间不区分读写，读写延迟取同一值
+		ReadLatency:  int64(rate.Latency),
+		WriteLatency: int64(rate.Latency),
 	}
 }
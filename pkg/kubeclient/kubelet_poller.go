@@ -0,0 +1,179 @@
+package kubeclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podSnapshotEntry 轮询快照中的单个Pod及其内容指纹
+type podSnapshotEntry struct {
+	pod  *corev1.Pod
+	hash uint64
+}
+
+// podSnapshot 以UID为key的Pod快照
+type podSnapshot map[types.UID]podSnapshotEntry
+
+// newPodSnapshot 根据Pod列表生成快照
+func newPodSnapshot(pods []corev1.Pod) podSnapshot {
+	snapshot := make(podSnapshot, len(pods))
+	for i := range pods {
+		pod := pods[i].DeepCopy()
+		snapshot[pod.UID] = podSnapshotEntry{pod: pod, hash: podHash(pod)}
+	}
+	return snapshot
+}
+
+// podHash 计算Pod注解、标签与状态的指纹
+// kubelet 本地状态（如容器重启、Started）可能先于API Server更新，仅比较resourceVersion会遗漏这些变化
+func podHash(pod *corev1.Pod) uint64 {
+	h := fnv.New64a()
+	data, err := json.Marshal(struct {
+		Annotations map[string]string
+		Labels      map[string]string
+		Status      corev1.PodStatus
+	}{pod.Annotations, pod.Labels, pod.Status})
+	if err == nil {
+		h.Write(data)
+	}
+	return h.Sum64()
+}
+
+// diffPodSnapshots 比较前后两次快照，生成 Added/Modified/Deleted 事件
+func diffPodSnapshots(prev, curr podSnapshot) []watch.Event {
+	var events []watch.Event
+	for uid, entry := range curr {
+		old, ok := prev[uid]
+		if !ok {
+			events = append(events, watch.Event{Type: watch.Added, Object: entry.pod})
+			continue
+		}
+		if old.pod.ResourceVersion != entry.pod.ResourceVersion || old.hash != entry.hash {
+			events = append(events, watch.Event{Type: watch.Modified, Object: entry.pod})
+		}
+	}
+	for uid, entry := range prev {
+		if _, ok := curr[uid]; !ok {
+			events = append(events, watch.Event{Type: watch.Deleted, Object: entry.pod})
+		}
+	}
+	return events
+}
+
+// kubeletPodWatcher 基于轮询kubelet /pods实现的 watch.Interface
+type kubeletPodWatcher struct {
+	list     func() ([]corev1.Pod, error)
+	interval time.Duration
+	result   chan watch.Event
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// onSnapshot 每次成功轮询后回调，用于同步ListWatch保存的最新快照
+	onSnapshot func(podSnapshot)
+}
+
+// newKubeletPodWatcher 从给定快照开始轮询，仅上报之后的变化；interval 取自 KUBELET_POD_POLL_INTERVAL，必须为正数
+func newKubeletPodWatcher(list func() ([]corev1.Pod, error), interval time.Duration, initial podSnapshot, onSnapshot func(podSnapshot)) *kubeletPodWatcher {
+	w := &kubeletPodWatcher{
+		list:       list,
+		interval:   interval,
+		result:     make(chan watch.Event),
+		stopCh:     make(chan struct{}),
+		onSnapshot: onSnapshot,
+	}
+	go w.run(initial)
+	return w
+}
+
+func (w *kubeletPodWatcher) run(prev podSnapshot) {
+	defer close(w.result)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		pods, err := w.list()
+		if err != nil {
+			// 单次轮询失败不结束watch，保留上次快照等待下次轮询
+			log.Printf("[KubeClient] Failed to poll pods from kubelet: %v", err)
+			continue
+		}
+		curr := newPodSnapshot(pods)
+		for _, event := range diffPodSnapshots(prev, curr) {
+			select {
+			case w.result <- event:
+			case <-w.stopCh:
+				return
+			}
+		}
+		prev = curr
+		if w.onSnapshot != nil {
+			w.onSnapshot(curr)
+		}
+	}
+}
+
+// Stop 停止轮询并关闭事件通道
+func (w *kubeletPodWatcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+}
+
+// ResultChan 返回事件通道
+func (w *kubeletPodWatcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// kubeletPodListWatch 基于kubelet /pods的 ListerWatcher，供SharedInformer在无API Server权限时使用
+// List 记录快照，随后的 Watch 从该快照开始对比，避免重复上报已有Pod
+type kubeletPodListWatch struct {
+	list     func() ([]corev1.Pod, error)
+	interval time.Duration
+
+	mu       sync.Mutex
+	snapshot podSnapshot
+}
+
+// newKubeletPodListWatch 创建kubelet轮询ListerWatcher
+func newKubeletPodListWatch(list func() ([]corev1.Pod, error), interval time.Duration) *kubeletPodListWatch {
+	return &kubeletPodListWatch{list: list, interval: interval}
+}
+
+// List 从kubelet获取本节点Pod列表
+func (lw *kubeletPodListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	pods, err := lw.list()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods from kubelet: %v", err)
+	}
+	lw.setSnapshot(newPodSnapshot(pods))
+	return &corev1.PodList{Items: pods}, nil
+}
+
+// Watch 从最近一次List的快照开始轮询
+func (lw *kubeletPodListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	lw.mu.Lock()
+	initial := lw.snapshot
+	lw.mu.Unlock()
+	return newKubeletPodWatcher(lw.list, lw.interval, initial, lw.setSnapshot), nil
+}
+
+func (lw *kubeletPodListWatch) setSnapshot(snapshot podSnapshot) {
+	lw.mu.Lock()
+	lw.snapshot = snapshot
+	lw.mu.Unlock()
+}
+
+var _ cache.ListerWatcher = (*kubeletPodListWatch)(nil)
@@ -0,0 +1,80 @@
+package kubeclient
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func testPod(uid, name, rv string, annotations map[string]string) corev1.Pod {
+	return corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		UID: types.UID(uid), Name: name, Namespace: "default", ResourceVersion: rv, Annotations: annotations,
+	}}
+}
+
+func TestDiffPodSnapshots(t *testing.T) {
+	prev := newPodSnapshot([]corev1.Pod{
+		testPod("1", "keep", "1", nil),
+		testPod("2", "annotated", "1", nil),
+		testPod("3", "gone", "1", nil),
+	})
+	curr := newPodSnapshot([]corev1.Pod{
+		testPod("1", "keep", "1", nil),
+		// resourceVersion 不变但注解变化（kubelet本地视图）也应识别为修改
+		testPod("2", "annotated", "1", map[string]string{"kubediskguard.io/write-iops": "100"}),
+		testPod("4", "new", "1", nil),
+	})
+
+	got := map[string]watch.EventType{}
+	for _, ev := range diffPodSnapshots(prev, curr) {
+		got[ev.Object.(*corev1.Pod).Name] = ev.Type
+	}
+	assert.Equal(t, map[string]watch.EventType{
+		"annotated": watch.Modified,
+		"new":       watch.Added,
+		"gone":      watch.Deleted,
+	}, got)
+}
+
+func TestKubeletPodListWatch(t *testing.T) {
+	var mu sync.Mutex
+	pods := []corev1.Pod{testPod("1", "app", "1", nil)}
+	list := func() ([]corev1.Pod, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]corev1.Pod(nil), pods...), nil
+	}
+
+	lw := newKubeletPodListWatch(list, 10*time.Millisecond)
+	obj, err := lw.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, obj.(*corev1.PodList).Items, 1)
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	assert.NoError(t, err)
+	defer w.Stop()
+
+	mu.Lock()
+	pods[0] = testPod("1", "app", "2", map[string]string{"kubediskguard.io/iops": "500"})
+	mu.Unlock()
+
+	select {
+	case ev := <-w.ResultChan():
+		assert.Equal(t, watch.Modified, ev.Type)
+		assert.Equal(t, "500", ev.Object.(*corev1.Pod).Annotations["kubediskguard.io/iops"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a Modified event from kubelet poller")
+	}
+
+	w.Stop()
+	assert.Eventually(t, func() bool {
+		_, ok := <-w.ResultChan()
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,145 @@
+package limits
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Class 按Pod的QoS等级、PriorityClass或优先级数值映射的限速等级
+// 所有设置了的条件需同时满足，Limits 的key与Pod注解保持一致（如 read-iops、write-bps）
+type Class struct {
+	Name               string            `json:"name"`                           // 等级名称，出现在优先级链和日志中
+	QOSClasses         []string          `json:"qos_classes,omitempty"`          // QoS等级，如 BestEffort、Burstable、Guaranteed
+	PriorityClassNames []string          `json:"priority_class_names,omitempty"` // spec.priorityClassName
+	MinPriority        *int32            `json:"min_priority,omitempty"`         // spec.priority 下限（含）
+	MaxPriority        *int32            `json:"max_priority,omitempty"`         // spec.priority 上限（含）
+	Limits             map[string]string `json:"limits,omitempty"`               // 限速配置，如 {"write-iops": "100"}
+	SmartLimit         SmartLimitPolicy  `json:"smart_limit,omitempty"`          // 智能限速策略
+}
+
+// ClassSet 限速等级集合，按顺序匹配，首个命中的等级生效
+type ClassSet struct {
+	Classes []Class `json:"classes"`
+}
+
+// LoadClasses 从JSON文件加载限速等级，路径为空时返回空集合
+func LoadClasses(file string) (*ClassSet, error) {
+	if file == "" {
+		return &ClassSet{}, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read limit classes file %s: %v", file, err)
+	}
+	var cs ClassSet
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, fmt.Errorf("failed to parse limit classes file %s: %v", file, err)
+	}
+	for i := range cs.Classes {
+		c := &cs.Classes[i]
+		if len(c.QOSClasses) == 0 && len(c.PriorityClassNames) == 0 && c.MinPriority == nil && c.MaxPriority == nil {
+			return nil, fmt.Errorf("class %d (%s) must set qos_classes, priority_class_names or a priority range", i, c.Name)
+		}
+		if c.SmartLimit.ThresholdScale < 0 {
+			return nil, fmt.Errorf("class %d (%s) threshold_scale must not be negative", i, c.Name)
+		}
+		if !ValidDetector(c.SmartLimit.Detector) {
+			return nil, fmt.Errorf("class %d (%s) unknown smart limit detector %q", i, c.Name, c.SmartLimit.Detector)
+		}
+		if err := validateTriggers(c.SmartLimit.Triggers); err != nil {
+			return nil, fmt.Errorf("class %d (%s) %v", i, c.Name, err)
+		}
+	}
+	return &cs, nil
+}
+
+// Match 返回首个匹配Pod的限速等级，未命中返回nil
+func (cs *ClassSet) Match(pod *corev1.Pod) *Class {
+	if cs == nil || pod == nil {
+		return nil
+	}
+	for i := range cs.Classes {
+		if cs.Classes[i].matches(pod) {
+			return &cs.Classes[i]
+		}
+	}
+	return nil
+}
+
+func (c *Class) matches(pod *corev1.Pod) bool {
+	if len(c.QOSClasses) > 0 && !contains(c.QOSClasses, string(PodQOSClass(pod))) {
+		return false
+	}
+	if len(c.PriorityClassNames) > 0 && !contains(c.PriorityClassNames, pod.Spec.PriorityClassName) {
+		return false
+	}
+	var priority int32
+	if pod.Spec.Priority != nil {
+		priority = *pod.Spec.Priority
+	}
+	if c.MinPriority != nil && priority < *c.MinPriority {
+		return false
+	}
+	if c.MaxPriority != nil && priority > *c.MaxPriority {
+		return false
+	}
+	return true
+}
+
+// Annotations 将等级限速配置转换为带前缀的注解形式，便于复用注解解析逻辑
+func (c *Class) Annotations(prefix string) map[string]string {
+	annotations := make(map[string]string, len(c.Limits))
+	for k, v := range c.Limits {
+		annotations[prefix+"/"+k] = v
+	}
+	return annotations
+}
+
+// Policy 返回等级的智能限速策略，c 为nil（未命中任何等级）时返回nil
+func (c *Class) Policy() *SmartLimitPolicy {
+	if c == nil {
+		return nil
+	}
+	return &c.SmartLimit
+}
+
+// PodQOSClass 返回Pod的QoS等级，status 中未填写时（如刚创建的Pod）按资源配置推算
+func PodQOSClass(pod *corev1.Pod) corev1.PodQOSClass {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass
+	}
+	hasResources, guaranteed := false, true
+	for _, c := range pod.Spec.Containers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, hasRequest := c.Resources.Requests[name]
+			limit, hasLimit := c.Resources.Limits[name]
+			if hasRequest || hasLimit {
+				hasResources = true
+			}
+			// requests 未设置时默认等于 limits
+			if !hasLimit || (hasRequest && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case !hasResources:
+		return corev1.PodQOSBestEffort
+	case guaranteed:
+		return corev1.PodQOSGuaranteed
+	default:
+		return corev1.PodQOSBurstable
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
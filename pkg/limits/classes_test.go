@@ -0,0 +1,82 @@
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestLoadAndMatchClasses(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "classes.json")
+	content := `{"classes": [
+		{"name": "critical", "min_priority": 1000000, "smart_limit": {"exempt": true}},
+		{"name": "system", "priority_class_names": ["system-node-critical"], "smart_limit": {"exempt": true}},
+		{"name": "best-effort", "qos_classes": ["BestEffort"], "limits": {"iops": "100"}, "smart_limit": {"threshold_scale": 0.5}},
+		{"name": "burstable", "qos_classes": ["Burstable"], "limits": {"write-iops": "300"}}
+	]}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := LoadClasses(file)
+	assert.NoError(t, err)
+	assert.Len(t, cs.Classes, 4)
+
+	high := int32(2000000000)
+	burstable := corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}}
+	cases := []struct {
+		name     string
+		pod      corev1.Pod
+		expected string
+	}{
+		{"priority range", corev1.Pod{Spec: corev1.PodSpec{Priority: &high}}, "critical"},
+		{"priority class name", corev1.Pod{Spec: corev1.PodSpec{PriorityClassName: "system-node-critical"}}, "system"},
+		{"qos from status", corev1.Pod{Status: corev1.PodStatus{QOSClass: corev1.PodQOSBestEffort}}, "best-effort"},
+		{"qos from resources", corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Resources: burstable}}}}, "burstable"},
+		{"no match", corev1.Pod{Status: corev1.PodStatus{QOSClass: corev1.PodQOSGuaranteed}}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := cs.Match(&tc.pod)
+			if tc.expected == "" {
+				assert.Nil(t, c)
+				assert.False(t, c.Policy().IsExempt())
+				assert.Equal(t, 1.0, c.Policy().Scale())
+				return
+			}
+			assert.NotNil(t, c)
+			assert.Equal(t, tc.expected, c.Name)
+		})
+	}
+
+	c := cs.Match(&corev1.Pod{Status: corev1.PodStatus{QOSClass: corev1.PodQOSBestEffort}})
+	assert.Equal(t, map[string]string{"kubediskguard.io/iops": "100"}, c.Annotations("kubediskguard.io"))
+	assert.Equal(t, 0.5, c.Policy().Scale())
+}
+
+func TestPodQOSClass(t *testing.T) {
+	guaranteed := corev1.ResourceRequirements{Limits: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("1"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Resources: guaranteed}}}}
+	assert.Equal(t, corev1.PodQOSGuaranteed, PodQOSClass(pod))
+
+	assert.Equal(t, corev1.PodQOSBestEffort, PodQOSClass(&corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{}}}}))
+}
+
+func TestLoadClassesInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "classes.json")
+	if err := os.WriteFile(file, []byte(`{"classes": [{"name": "empty"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadClasses(file)
+	assert.Error(t, err)
+
+	cs, err := LoadClasses("")
+	assert.NoError(t, err)
+	assert.Nil(t, cs.Match(&corev1.Pod{}))
+}
@@ -0,0 +1,138 @@
+package limits
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+)
+
+// 限速配置来源层级，按优先级从低到高排列
+const (
+	LayerGlobal    = "global"    // 全局默认值（环境变量）
+	LayerClass     = "class"     // 按QoS/PriorityClass映射的限速等级
+	LayerNamespace = "namespace" // 命名空间注解
+	LayerPod       = "pod"       // Pod注解
+	LayerSchedule  = "schedule"  // Pod（或其引用的命名配置）时间表中当前生效的窗口
+	LayerContainer = "container" // 容器级注解
+	LayerBudget    = "budget"    // 节点IO预算分配给该容器的份额，与前面的结果逐项取更严格的值
+	LayerResource  = "resource"  // 容器 resources.limits 中声明的IO扩展资源，声明了的项为最终值
+	LayerRule      = "rule"      // 独立模式静态规则
+	LayerLabel     = "label"     // 独立模式容器标签
+)
+
+// Limits 一组读写IOPS/BPS限速值，0表示不限速
+type Limits struct {
+	ReadIOPS  int `json:"read_iops"`
+	WriteIOPS int `json:"write_iops"`
+	ReadBPS   int `json:"read_bps"`
+	WriteBPS  int `json:"write_bps"`
+}
+
+// IsZero 四项均为0时表示解除限速
+func (l Limits) IsZero() bool {
+	return l.ReadIOPS == 0 && l.WriteIOPS == 0 && l.ReadBPS == 0 && l.WriteBPS == 0
+}
+
+// ParseIOPS 解析注解中的IOPS值（非负整数），0表示不限速
+func ParseIOPS(value string) (int, error) {
+	iops, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid iops %q, expected a non-negative integer", value)
+	}
+	if iops < 0 {
+		return 0, fmt.Errorf("invalid iops %q, must not be negative", value)
+	}
+	return iops, nil
+}
+
+// ParseBPS 解析注解中的BPS值，支持单位（如 10M、1Gi，按1024进制），0表示不限速
+func ParseBPS(value string) (int, error) {
+	size := value
+	if strings.HasSuffix(size, "i") {
+		// Kubernetes 风格的单位（Ki、Mi、Gi）补全为 KiB、MiB、GiB
+		size += "B"
+	}
+	bps, err := units.RAMInBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bps %q, expected bytes or a size like 10M", value)
+	}
+	if bps < 0 {
+		return 0, fmt.Errorf("invalid bps %q, must not be negative", value)
+	}
+	return int(bps), nil
+}
+
+// Layer 优先级链中某一层解析后的限速值
+type Layer struct {
+	Source string `json:"source"`
+	Name   string `json:"name,omitempty"` // 命中的对象名，如命名空间名、规则名
+	Limits
+}
+
+// Resolution 单个容器的限速解析结果，Chain 按优先级从低到高记录每一层覆盖后的值
+type Resolution struct {
+	ContainerID   string  `json:"container_id,omitempty"`
+	ContainerName string  `json:"container_name"`
+	PodName       string  `json:"pod_name,omitempty"`
+	Namespace     string  `json:"namespace,omitempty"`
+	Chain         []Layer `json:"chain"`
+	Effective     Limits  `json:"effective"`
+
+	Volumes []VolumeLimit `json:"volumes,omitempty"` // 容器挂载的PVC所在设备的限速，与 Effective（数据盘）分别下发
+}
+
+// Push 追加一层解析结果，并将其作为当前生效值
+func (r *Resolution) Push(source, name string, l Limits) {
+	r.Chain = append(r.Chain, Layer{Source: source, Name: name, Limits: l})
+	r.Effective = l
+}
+
+// 智能限速检测方式
+const (
+	DetectorThreshold = "threshold" // 分级阈值：各时间窗口平均速率超过固定阈值时限速
+	DetectorAnomaly   = "anomaly"   // 异常检测：速率持续超出容器自身EWMA基线N倍标准差时限速
+)
+
+// ValidDetector 检测方式是否合法，空表示使用全局默认
+func ValidDetector(detector string) bool {
+	return detector == "" || detector == DetectorThreshold || detector == DetectorAnomaly
+}
+
+// SmartLimitPolicy 限速等级或命名配置携带的智能限速策略
+type SmartLimitPolicy struct {
+	Exempt         bool      `json:"exempt,omitempty"`          // 为true时永不自动限速
+	ThresholdScale float64   `json:"threshold_scale,omitempty"` // 触发阈值倍数，如 0.5 表示更早触发，默认1；异常检测时为标准差倍数的倍数
+	Detector       string    `json:"detector,omitempty"`        // 检测方式（threshold/anomaly），为空时使用全局默认
+	Triggers       []Trigger `json:"triggers,omitempty"`        // 按窗口统计量（均值、分位数、最大值）的触发条件，设置后替换全局触发条件
+}
+
+// IsExempt 是否禁止智能限速，nil 表示未配置策略
+func (p *SmartLimitPolicy) IsExempt() bool {
+	return p != nil && p.Exempt
+}
+
+// Scale 智能限速阈值倍数，未配置时为1
+func (p *SmartLimitPolicy) Scale() float64 {
+	if p == nil || p.ThresholdScale <= 0 {
+		return 1
+	}
+	return p.ThresholdScale
+}
+
+// TriggersOr 策略设置了触发条件时返回这些条件，否则返回全局触发条件
+func (p *SmartLimitPolicy) TriggersOr(fallback []Trigger) []Trigger {
+	if p == nil || len(p.Triggers) == 0 {
+		return fallback
+	}
+	return p.Triggers
+}
+
+// DetectorOr 策略设置了检测方式时返回该方式，否则返回全局默认值
+func (p *SmartLimitPolicy) DetectorOr(fallback string) string {
+	if p == nil || p.Detector == "" {
+		return fallback
+	}
+	return p.Detector
+}
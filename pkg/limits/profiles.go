@@ -0,0 +1,137 @@
+package limits
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/schedule"
+)
+
+// Profile 命名限速配置，Pod 通过 <prefix>/profile: <name> 注解引用，显式注解覆盖配置中的值
+// 未设置的项不参与覆盖；BPS 支持单位（如 10M、1Gi），加载时校验，避免各处手写数值出错
+type Profile struct {
+	Name       string             `json:"name"`
+	ReadIOPS   *int               `json:"read_iops,omitempty"`
+	WriteIOPS  *int               `json:"write_iops,omitempty"`
+	ReadBPS    string             `json:"read_bps,omitempty"`
+	WriteBPS   string             `json:"write_bps,omitempty"`
+	SmartLimit *SmartLimitPolicy  `json:"smart_limit,omitempty"` // 引用该配置的Pod的智能限速策略，优先于限速等级
+	Schedule   *schedule.Schedule `json:"schedule,omitempty"`    // 引用该配置的Pod的限速时间表，Pod自身的 schedule 注解优先
+
+	readBps, writeBps int
+}
+
+// ProfileSet 命名限速配置集合
+type ProfileSet struct {
+	Profiles []Profile `json:"profiles"`
+
+	byName map[string]*Profile
+}
+
+// LoadProfiles 从JSON文件（如挂载的ConfigMap）加载命名限速配置，路径为空时返回空集合
+func LoadProfiles(file string) (*ProfileSet, error) {
+	if file == "" {
+		return &ProfileSet{}, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read limit profiles file %s: %v", file, err)
+	}
+	var ps ProfileSet
+	if err := json.Unmarshal(data, &ps); err != nil {
+		return nil, fmt.Errorf("failed to parse limit profiles file %s: %v", file, err)
+	}
+	ps.byName = make(map[string]*Profile, len(ps.Profiles))
+	for i := range ps.Profiles {
+		p := &ps.Profiles[i]
+		if p.Name == "" {
+			return nil, fmt.Errorf("profile %d must set name", i)
+		}
+		if _, exists := ps.byName[p.Name]; exists {
+			return nil, fmt.Errorf("duplicate profile %s", p.Name)
+		}
+		if (p.ReadIOPS != nil && *p.ReadIOPS < 0) || (p.WriteIOPS != nil && *p.WriteIOPS < 0) {
+			return nil, fmt.Errorf("profile %s iops must not be negative", p.Name)
+		}
+		if p.readBps, err = parseOptionalBPS(p.ReadBPS); err != nil {
+			return nil, fmt.Errorf("profile %s read_bps: %v", p.Name, err)
+		}
+		if p.writeBps, err = parseOptionalBPS(p.WriteBPS); err != nil {
+			return nil, fmt.Errorf("profile %s write_bps: %v", p.Name, err)
+		}
+		if p.SmartLimit != nil && p.SmartLimit.ThresholdScale < 0 {
+			return nil, fmt.Errorf("profile %s threshold_scale must not be negative", p.Name)
+		}
+		if p.SmartLimit != nil && !ValidDetector(p.SmartLimit.Detector) {
+			return nil, fmt.Errorf("profile %s unknown smart limit detector %q", p.Name, p.SmartLimit.Detector)
+		}
+		if p.SmartLimit != nil {
+			if err := validateTriggers(p.SmartLimit.Triggers); err != nil {
+				return nil, fmt.Errorf("profile %s %v", p.Name, err)
+			}
+		}
+		if p.Schedule != nil {
+			if err := p.Schedule.Compile(); err != nil {
+				return nil, fmt.Errorf("profile %s: %v", p.Name, err)
+			}
+		}
+		ps.byName[p.Name] = p
+	}
+	return &ps, nil
+}
+
+func parseOptionalBPS(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return ParseBPS(value)
+}
+
+// Get 按名称查找配置，未找到或集合为nil时返回nil
+func (ps *ProfileSet) Get(name string) *Profile {
+	if ps == nil {
+		return nil
+	}
+	return ps.byName[name]
+}
+
+// Expand 注解引用了命名配置（<prefix>/profile）时，以配置中的值为底、显式注解覆盖后返回新的注解集合；
+// 未引用或配置不存在时原样返回
+func (ps *ProfileSet) Expand(annotations map[string]string, prefix string) map[string]string {
+	name, ok := annotations[prefix+"/"+annotationkeys.ProfileAnnotationKey]
+	if !ok {
+		return annotations
+	}
+	profile := ps.Get(name)
+	if profile == nil {
+		log.Printf("Unknown limit profile %q, ignoring", name)
+		return annotations
+	}
+	merged := profile.Annotations(prefix)
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Annotations 将配置中设置了的项转换为带前缀的注解形式，便于复用注解解析逻辑
+func (p *Profile) Annotations(prefix string) map[string]string {
+	annotations := make(map[string]string, 4)
+	if p.ReadIOPS != nil {
+		annotations[prefix+"/"+annotationkeys.ReadIopsAnnotationKey] = strconv.Itoa(*p.ReadIOPS)
+	}
+	if p.WriteIOPS != nil {
+		annotations[prefix+"/"+annotationkeys.WriteIopsAnnotationKey] = strconv.Itoa(*p.WriteIOPS)
+	}
+	if p.ReadBPS != "" {
+		annotations[prefix+"/"+annotationkeys.ReadBpsAnnotationKey] = strconv.Itoa(p.readBps)
+	}
+	if p.WriteBPS != "" {
+		annotations[prefix+"/"+annotationkeys.WriteBpsAnnotationKey] = strconv.Itoa(p.writeBps)
+	}
+	return annotations
+}
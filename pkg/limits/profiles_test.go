@@ -0,0 +1,66 @@
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "profiles.json")
+	content := `{"profiles": [
+		{"name": "batch", "write_iops": 300, "write_bps": "50M", "smart_limit": {"threshold_scale": 0.5, "detector": "anomaly"}},
+		{"name": "database", "read_iops": 0, "write_iops": 0, "smart_limit": {"exempt": true}}
+	]}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ps, err := LoadProfiles(file)
+	assert.NoError(t, err)
+
+	batch := ps.Get("batch")
+	assert.NotNil(t, batch)
+	assert.Equal(t, map[string]string{
+		"kubediskguard.io/write-iops": "300",
+		"kubediskguard.io/write-bps":  "52428800",
+	}, batch.Annotations("kubediskguard.io"))
+	assert.Equal(t, 0.5, batch.SmartLimit.Scale())
+	assert.Equal(t, DetectorAnomaly, batch.SmartLimit.DetectorOr(DetectorThreshold))
+	assert.Equal(t, DetectorThreshold, ps.Get("database").SmartLimit.DetectorOr(DetectorThreshold))
+
+	// 显式设置为0表示不限速，与未设置不同
+	assert.Equal(t, map[string]string{
+		"kubediskguard.io/read-iops":  "0",
+		"kubediskguard.io/write-iops": "0",
+	}, ps.Get("database").Annotations("kubediskguard.io"))
+	assert.True(t, ps.Get("database").SmartLimit.IsExempt())
+
+	assert.Nil(t, ps.Get("unknown"))
+	var empty *ProfileSet
+	assert.Nil(t, empty.Get("batch"))
+}
+
+func TestLoadProfilesInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"name":      `{"profiles": [{"write_iops": 100}]}`,
+		"duplicate": `{"profiles": [{"name": "a"}, {"name": "a"}]}`,
+		"units":     `{"profiles": [{"name": "a", "read_bps": "ten megs"}]}`,
+		"negative":  `{"profiles": [{"name": "a", "read_iops": -1}]}`,
+		"detector":  `{"profiles": [{"name": "a", "smart_limit": {"detector": "ewma"}}]}`,
+		"trigger":   `{"profiles": [{"name": "a", "smart_limit": {"triggers": [{"window": "15m", "statistic": "p90", "metric": "write_iops"}]}}]}`,
+	} {
+		file := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadProfiles(file)
+		assert.Error(t, err, name)
+	}
+
+	ps, err := LoadProfiles("")
+	assert.NoError(t, err)
+	assert.Nil(t, ps.Get("batch"))
+}
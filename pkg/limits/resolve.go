@@ -0,0 +1,153 @@
+package limits
+
+import (
+	"KubeDiskGuard/pkg/annotationkeys"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ParseIOPSAnnotations 解析注解中的iops限制（分别支持读写），未设置的项使用默认值；有智能限速注解时忽略旧版 nvme-* 注解
+func ParseIOPSAnnotations(annotations map[string]string, defaultReadIops, defaultWriteIops int, prefix string) (int, int) {
+	readIops, writeIops := defaultReadIops, defaultWriteIops
+	annotationPrefix := prefix + "/"
+
+	if val, ok := annotations[annotationPrefix+annotationkeys.RemovedAnnotationKey]; ok && val == "true" {
+		return 0, 0
+	}
+
+	useSmart := hasSmartLimitAnnotation(annotations, prefix)
+
+	if useSmart {
+		if iops, ok := annotations[annotationPrefix+annotationkeys.IopsAnnotationKey]; ok {
+			if value, err := ParseIOPS(iops); err == nil {
+				return value, value
+			}
+		}
+		if riops, ok := annotations[annotationPrefix+annotationkeys.ReadIopsAnnotationKey]; ok {
+			if value, err := ParseIOPS(riops); err == nil {
+				readIops = value
+			}
+		}
+		if wiops, ok := annotations[annotationPrefix+annotationkeys.WriteIopsAnnotationKey]; ok {
+			if value, err := ParseIOPS(wiops); err == nil {
+				writeIops = value
+			}
+		}
+		return readIops, writeIops
+	}
+
+	// Fallback to legacy only if no smart limit annotations are present
+	if iops, ok := annotations[annotationkeys.LegacyIopsAnnotationKey]; ok {
+		if value, err := ParseIOPS(iops); err == nil {
+			return value, value
+		}
+	}
+	if riops, ok := annotations[annotationkeys.LegacyReadIopsAnnotationKey]; ok {
+		if value, err := ParseIOPS(riops); err == nil {
+			readIops = value
+		}
+	}
+	if wiops, ok := annotations[annotationkeys.LegacyWriteIopsAnnotationKey]; ok {
+		if value, err := ParseIOPS(wiops); err == nil {
+			writeIops = value
+		}
+	}
+
+	return readIops, writeIops
+}
+
+// hasSmartLimitAnnotation 是否设置了任一智能限速注解（iops、bps 及分读写的项）
+func hasSmartLimitAnnotation(annotations map[string]string, prefix string) bool {
+	annotationPrefix := prefix + "/"
+	smartKeys := []string{
+		annotationkeys.IopsAnnotationKey, annotationkeys.ReadIopsAnnotationKey, annotationkeys.WriteIopsAnnotationKey,
+		annotationkeys.BpsAnnotationKey, annotationkeys.ReadBpsAnnotationKey, annotationkeys.WriteBpsAnnotationKey,
+	}
+	for _, key := range smartKeys {
+		if _, ok := annotations[annotationPrefix+key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBPSAnnotations 解析注解中的bps限制（分别支持读写），未设置的项使用默认值；有智能限速注解时忽略旧版 nvme-* 注解
+func ParseBPSAnnotations(annotations map[string]string, defaultReadBps, defaultWriteBps int, prefix string) (int, int) {
+	readBps, writeBps := defaultReadBps, defaultWriteBps
+	annotationPrefix := prefix + "/"
+
+	if val, ok := annotations[annotationPrefix+annotationkeys.RemovedAnnotationKey]; ok && val == "true" {
+		return 0, 0
+	}
+
+	useSmart := hasSmartLimitAnnotation(annotations, prefix)
+
+	if useSmart {
+		if bps, ok := annotations[annotationPrefix+annotationkeys.BpsAnnotationKey]; ok {
+			if value, err := ParseBPS(bps); err == nil {
+				return value, value
+			}
+		}
+		if rbps, ok := annotations[annotationPrefix+annotationkeys.ReadBpsAnnotationKey]; ok {
+			if value, err := ParseBPS(rbps); err == nil {
+				readBps = value
+			}
+		}
+		if wbps, ok := annotations[annotationPrefix+annotationkeys.WriteBpsAnnotationKey]; ok {
+			if value, err := ParseBPS(wbps); err == nil {
+				writeBps = value
+			}
+		}
+		return readBps, writeBps
+	}
+
+	// Fallback to legacy only if no smart limit annotations are present
+	if bps, ok := annotations[annotationkeys.LegacyBpsAnnotationKey]; ok {
+		if value, err := ParseBPS(bps); err == nil {
+			return value, value
+		}
+	}
+	if rbps, ok := annotations[annotationkeys.LegacyReadBpsAnnotationKey]; ok {
+		if value, err := ParseBPS(rbps); err == nil {
+			readBps = value
+		}
+	}
+	if wbps, ok := annotations[annotationkeys.LegacyWriteBpsAnnotationKey]; ok {
+		if value, err := ParseBPS(wbps); err == nil {
+			writeBps = value
+		}
+	}
+
+	return readBps, writeBps
+}
+
+// ParseLayer 在 base 的基础上应用一组注解（或容器标签），未设置的项保持 base 的值；
+// 引用了命名配置时先展开配置，显式注解覆盖配置中的值
+func ParseLayer(annotations map[string]string, base Limits, prefix string, profiles *ProfileSet) Limits {
+	annotations = profiles.Expand(annotations, prefix)
+	readIops, writeIops := ParseIOPSAnnotations(annotations, base.ReadIOPS, base.WriteIOPS, prefix)
+	readBps, writeBps := ParseBPSAnnotations(annotations, base.ReadBPS, base.WriteBPS, prefix)
+	return Limits{ReadIOPS: readIops, WriteIOPS: writeIops, ReadBPS: readBps, WriteBPS: writeBps}
+}
+
+// ResolvePod 按 全局 → 限速等级 → 命名空间 → Pod 的优先级解析Pod级限速（不含时间窗口和容器级注解），
+// 注解引用的命名配置从 profiles 中查找；限速下发和准入时注入注解共用
+func ResolvePod(global Limits, classes *ClassSet, profiles *ProfileSet, namespaceAnnotations map[string]string, pod corev1.Pod, prefix string) Resolution {
+	res := Resolution{PodName: pod.Name, Namespace: pod.Namespace}
+
+	current := global
+	res.Push(LayerGlobal, "", current)
+
+	// 限速等级在所有注解之前生效，注解仍可覆盖
+	if class := classes.Match(&pod); class != nil {
+		current = ParseLayer(class.Annotations(prefix), current, prefix, profiles)
+		res.Push(LayerClass, class.Name, current)
+	}
+
+	current = ParseLayer(namespaceAnnotations, current, prefix, profiles)
+	res.Push(LayerNamespace, pod.Namespace, current)
+
+	current = ParseLayer(pod.Annotations, current, prefix, profiles)
+	res.Push(LayerPod, pod.Name, current)
+	return res
+}
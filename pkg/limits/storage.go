@@ -0,0 +1,81 @@
+package limits
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// storageLimitKeys 存储类参数可映射的限速注解key
+var storageLimitKeys = []string{"iops", "read-iops", "write-iops", "bps", "read-bps", "write-bps"}
+
+// ParameterMap 限速注解key到存储类（或卷属性类）参数名的映射，同一key可配置多个参数名，按顺序取首个存在的参数
+type ParameterMap map[string][]string
+
+// ParseParameterMap 解析参数映射，格式为 key=参数名[|参数名...]，多项用逗号分隔，
+// 如 "iops=iops|provisioned-iops,bps=throughput"
+func ParseParameterMap(spec string) (ParameterMap, error) {
+	m := ParameterMap{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, params, found := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !found {
+			return nil, fmt.Errorf("invalid parameter mapping %q, expected key=parameter", item)
+		}
+		if !contains(storageLimitKeys, key) {
+			return nil, fmt.Errorf("invalid parameter mapping %q, key must be one of %s", item, strings.Join(storageLimitKeys, ", "))
+		}
+		for _, param := range strings.Split(params, "|") {
+			if param = strings.TrimSpace(param); param != "" {
+				m[key] = append(m[key], param)
+			}
+		}
+		if len(m[key]) == 0 {
+			return nil, fmt.Errorf("invalid parameter mapping %q, parameter name is empty", item)
+		}
+	}
+	return m, nil
+}
+
+// Annotations 按映射把存储类参数转换为带前缀的限速注解，供注解解析逻辑复用；
+// BPS参数为纯数字时按 throughputUnit 补全单位（如 gp3 的 throughput 以 MiB/s 为单位，对应 "Mi"）
+func (m ParameterMap) Annotations(parameters map[string]string, throughputUnit, prefix string) map[string]string {
+	annotations := make(map[string]string)
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, param := range m[key] {
+			value, ok := parameters[param]
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if strings.HasSuffix(key, "bps") {
+				if _, err := strconv.ParseFloat(value, 64); err == nil {
+					value += throughputUnit
+				}
+			}
+			annotations[prefix+"/"+key] = value
+			break
+		}
+	}
+	return annotations
+}
+
+// VolumeLimit 从PVC存储类（或卷属性类）参数得到的卷所在设备的限速
+type VolumeLimit struct {
+	Volume                string `json:"volume"`                            // Pod中的卷名
+	Claim                 string `json:"claim"`                             // PVC名称
+	StorageClass          string `json:"storage_class,omitempty"`           // 存储类
+	VolumeAttributesClass string `json:"volume_attributes_class,omitempty"` // 卷属性类，参数覆盖存储类参数
+	Device                string `json:"device"`                            // 卷所在块设备的 major:minor
+	Limits
+}
@@ -0,0 +1,36 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParameterMap(t *testing.T) {
+	m, err := ParseParameterMap("iops=iops, write-iops=provisioned-write-iops|iops, bps=throughput")
+	assert.NoError(t, err)
+	assert.Equal(t, ParameterMap{
+		"iops":       {"iops"},
+		"write-iops": {"provisioned-write-iops", "iops"},
+		"bps":        {"throughput"},
+	}, m)
+
+	// 纯数字的吞吐量按单位补全，带单位的保持不变；同一key按顺序取首个存在的参数
+	assert.Equal(t, map[string]string{
+		"kubediskguard.io/iops":       "3000",
+		"kubediskguard.io/write-iops": "3000",
+		"kubediskguard.io/bps":        "125Mi",
+	}, m.Annotations(map[string]string{"type": "gp3", "iops": "3000", "throughput": "125"}, "Mi", "kubediskguard.io"))
+	bps, err := ParseBPS("125Mi")
+	assert.NoError(t, err)
+	assert.Equal(t, 125*1024*1024, bps)
+	assert.Equal(t, map[string]string{
+		"kubediskguard.io/bps": "200M",
+	}, m.Annotations(map[string]string{"throughput": "200M"}, "Mi", "kubediskguard.io"))
+	assert.Empty(t, m.Annotations(nil, "Mi", "kubediskguard.io"))
+
+	for _, spec := range []string{"iops", "latency=iops", "bps="} {
+		_, err := ParseParameterMap(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,110 @@
+package limits
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 触发条件可用的时间窗口、统计量和指标
+const (
+	StatisticMean = "mean"
+	StatisticP50  = "p50"
+	StatisticP95  = "p95"
+	StatisticP99  = "p99"
+	StatisticMax  = "max"
+
+	MetricReadIOPS  = "read_iops"
+	MetricWriteIOPS = "write_iops"
+	MetricReadBPS   = "read_bps"
+	MetricWriteBPS  = "write_bps"
+
+	MetricReadLatency  = "read_latency"
+	MetricWriteLatency = "write_latency"
+)
+
+var (
+	triggerWindows    = []string{"15m", "30m", "60m"}
+	triggerStatistics = []string{StatisticMean, StatisticP50, StatisticP95, StatisticP99, StatisticMax}
+	triggerMetrics    = []string{MetricReadIOPS, MetricWriteIOPS, MetricReadBPS, MetricWriteBPS, MetricReadLatency, MetricWriteLatency}
+)
+
+// Trigger 智能限速触发条件：时间窗口内某项速率的统计量超过阈值时限速该方向，如15分钟写IOPS的p95超过1000
+type Trigger struct {
+	Window    string  `json:"window"`    // 15m、30m 或 60m
+	Statistic string  `json:"statistic"` // mean、p50、p95、p99 或 max
+	Metric    string  `json:"metric"`    // read_iops、write_iops、read_bps、write_bps、read_latency 或 write_latency
+	Threshold float64 `json:"threshold"` // 阈值，BPS为字节/秒，延迟为毫秒
+}
+
+// Validate 校验触发条件的窗口、统计量和指标
+func (t Trigger) Validate() error {
+	if !contains(triggerWindows, t.Window) {
+		return fmt.Errorf("invalid trigger window %q, must be one of %s", t.Window, strings.Join(triggerWindows, ", "))
+	}
+	if !contains(triggerStatistics, t.Statistic) {
+		return fmt.Errorf("invalid trigger statistic %q, must be one of %s", t.Statistic, strings.Join(triggerStatistics, ", "))
+	}
+	if !contains(triggerMetrics, t.Metric) {
+		return fmt.Errorf("invalid trigger metric %q, must be one of %s", t.Metric, strings.Join(triggerMetrics, ", "))
+	}
+	if t.Threshold < 0 {
+		return fmt.Errorf("invalid trigger threshold %v, must not be negative", t.Threshold)
+	}
+	return nil
+}
+
+// Read 触发条件是否针对读方向
+func (t Trigger) Read() bool {
+	return t.Metric == MetricReadIOPS || t.Metric == MetricReadBPS || t.Metric == MetricReadLatency
+}
+
+// String 触发条件的描述，如 15m:p95:write_iops>1000
+func (t Trigger) String() string {
+	return fmt.Sprintf("%s:%s:%s>%g", t.Window, t.Statistic, t.Metric, t.Threshold)
+}
+
+// ParseTriggers 解析逗号分隔的触发条件，每项为 window:statistic:metric:threshold，
+// 如 15m:p95:write_iops:1000,60m:max:read_bps:200M，BPS阈值支持单位，延迟阈值单位为毫秒
+func ParseTriggers(spec string) ([]Trigger, error) {
+	var triggers []Trigger
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid trigger %q, expected window:statistic:metric:threshold", item)
+		}
+		t := Trigger{Window: parts[0], Statistic: parts[1], Metric: parts[2]}
+		if t.Metric == MetricReadBPS || t.Metric == MetricWriteBPS {
+			bps, err := ParseBPS(parts[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid trigger %q: %v", item, err)
+			}
+			t.Threshold = float64(bps)
+		} else {
+			threshold, err := strconv.ParseFloat(parts[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trigger %q, threshold must be a number", item)
+			}
+			t.Threshold = threshold
+		}
+		if err := t.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid trigger %q: %v", item, err)
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, nil
+}
+
+// validateTriggers 校验策略中的全部触发条件
+func validateTriggers(triggers []Trigger) error {
+	for i, t := range triggers {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("trigger %d: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTriggers(t *testing.T) {
+	triggers, err := ParseTriggers("15m:p95:write_iops:1000, 60m:max:read_bps:200M")
+	assert.NoError(t, err)
+	assert.Equal(t, []Trigger{
+		{Window: "15m", Statistic: StatisticP95, Metric: MetricWriteIOPS, Threshold: 1000},
+		{Window: "60m", Statistic: StatisticMax, Metric: MetricReadBPS, Threshold: 200 * 1024 * 1024},
+	}, triggers)
+	assert.False(t, triggers[0].Read())
+	assert.True(t, triggers[1].Read())
+	assert.Equal(t, "15m:p95:write_iops>1000", triggers[0].String())
+
+	triggers, err = ParseTriggers("30m:p99:read_latency:20")
+	assert.NoError(t, err)
+	assert.Equal(t, []Trigger{{Window: "30m", Statistic: StatisticP99, Metric: MetricReadLatency, Threshold: 20}}, triggers)
+	assert.True(t, triggers[0].Read())
+
+	triggers, err = ParseTriggers("")
+	assert.NoError(t, err)
+	assert.Empty(t, triggers)
+
+	for _, spec := range []string{"15m:p95:write_iops", "5m:p95:write_iops:1", "15m:p90:write_iops:1", "15m:p95:iops:1", "15m:p95:write_iops:many", "15m:max:read_bps:ten megs"} {
+		_, err := ParseTriggers(spec)
+		assert.Error(t, err, spec)
+	}
+}
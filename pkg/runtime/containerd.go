@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/containerd/containerd"
+	apievents "github.com/containerd/containerd/api/events"
 	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/typeurl/v2"
 
 	"KubeDiskGuard/pkg/cgroup"
 	"KubeDiskGuard/pkg/config"
@@ -71,8 +73,20 @@ func (c *ContainerdRuntime) getContainerInfo(ctx context.Context, cont container
 		ID:           cont.ID(),
 		Name:         info.Labels["io.kubernetes.container.name"],
 		Annotations:  map[string]string{},
+		Labels:       map[string]string{},
 		CgroupParent: spec.Linux.CgroupsPath,
 	}
+	// 非Kubernetes容器（如nerdctl创建）没有容器名标签
+	if containerInfo.Name == "" {
+		if name, ok := info.Labels["nerdctl/name"]; ok {
+			containerInfo.Name = name
+		} else {
+			containerInfo.Name = cont.ID()
+		}
+	}
+	for k, v := range info.Labels {
+		containerInfo.Labels[k] = v
+	}
 
 	// 获取镜像信息
 	if info.Image != "" {
@@ -94,6 +108,84 @@ func (c *ContainerdRuntime) getContainerInfo(ctx context.Context, cont container
 	return containerInfo, nil
 }
 
+// ListContainers 列出所有任务处于运行状态的容器
+func (c *ContainerdRuntime) ListContainers() ([]*container.ContainerInfo, error) {
+	ctx := namespaces.WithNamespace(context.Background(), c.config.ContainerdNamespace)
+	conts, err := c.client.Containers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containerd containers: %v", err)
+	}
+	result := make([]*container.ContainerInfo, 0, len(conts))
+	for _, cont := range conts {
+		task, err := cont.Task(ctx, nil)
+		if err != nil {
+			continue
+		}
+		status, err := task.Status(ctx)
+		if err != nil || status.Status != containerd.Running {
+			continue
+		}
+		ci, err := c.getContainerInfo(ctx, cont)
+		if err != nil {
+			continue
+		}
+		result = append(result, ci)
+	}
+	return result, nil
+}
+
+// WatchContainerEvents 订阅containerd任务启动/退出事件
+func (c *ContainerdRuntime) WatchContainerEvents(ctx context.Context) (<-chan container.ContainerEvent, <-chan error) {
+	envelopes, errs := c.client.EventService().Subscribe(ctx,
+		fmt.Sprintf(`namespace==%q,topic=="/tasks/start"`, c.config.ContainerdNamespace),
+		fmt.Sprintf(`namespace==%q,topic=="/tasks/exit"`, c.config.ContainerdNamespace),
+	)
+	out := make(chan container.ContainerEvent)
+	outErr := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(outErr)
+		for {
+			select {
+			case env := <-envelopes:
+				if env == nil || env.Event == nil {
+					continue
+				}
+				decoded, err := typeurl.UnmarshalAny(env.Event)
+				if err != nil {
+					continue
+				}
+				var ev container.ContainerEvent
+				switch e := decoded.(type) {
+				case *apievents.TaskStart:
+					ev = container.ContainerEvent{Type: container.ContainerEventStart, ContainerID: e.ContainerID}
+				case *apievents.TaskExit:
+					// exec进程退出也会产生TaskExit，只关心容器主进程
+					if e.ID != "" && e.ID != e.ContainerID {
+						continue
+					}
+					ev = container.ContainerEvent{Type: container.ContainerEventStop, ContainerID: e.ContainerID}
+				default:
+					continue
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				if err != nil && ctx.Err() == nil {
+					outErr <- err
+				}
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, outErr
+}
+
 // SetLimits 统一设置IOPS和BPS限制
 func (c *ContainerdRuntime) SetLimits(container *container.ContainerInfo, riops, wiops, rbps, wbps int) error {
 	majMin, err := device.GetMajMin(c.config.DataMount)
@@ -120,6 +212,24 @@ func (c *ContainerdRuntime) ResetLimits(container *container.ContainerInfo) erro
 	return c.cgroup.ResetLimits(cgroupPath, majMin)
 }
 
+// SetDeviceLimits 对指定设备（major:minor）设置IOPS和BPS限制，用于数据盘以外的设备（如PVC所在的盘）
+func (c *ContainerdRuntime) SetDeviceLimits(container *container.ContainerInfo, majMin string, riops, wiops, rbps, wbps int) error {
+	cgroupPath, err := c.getCgroupPath(container.CgroupParent)
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup path for container %s: %v", container.ID, err)
+	}
+	return c.cgroup.SetLimits(cgroupPath, majMin, riops, wiops, rbps, wbps)
+}
+
+// ResetDeviceLimits 解除指定设备（major:minor）的所有限速
+func (c *ContainerdRuntime) ResetDeviceLimits(container *container.ContainerInfo, majMin string) error {
+	cgroupPath, err := c.getCgroupPath(container.CgroupParent)
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup path for container %s: %v", container.ID, err)
+	}
+	return c.cgroup.ResetLimits(cgroupPath, majMin)
+}
+
 // getCgroupPath 通过containerd API获取容器的cgroup路径
 func (c *ContainerdRuntime) getCgroupPath(cgroupsPath string) (string, error) {
 	// 根据cgroup版本和systemd管理模式构建完整路径
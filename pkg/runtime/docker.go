@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 
 	"KubeDiskGuard/pkg/cgroup"
@@ -50,13 +52,77 @@ func (d *DockerRuntime) GetContainerByID(containerID string) (*container.Contain
 		Name:         strings.TrimPrefix(info.Name, "/"),
 		CgroupParent: info.HostConfig.CgroupParent,
 		Annotations:  map[string]string{},
+		Labels:       map[string]string{},
 	}
 	for k, v := range info.Config.Labels {
 		ci.Annotations[k] = v
+		ci.Labels[k] = v
 	}
 	return ci, nil
 }
 
+// ListContainers 列出所有运行中的容器
+func (d *DockerRuntime) ListContainers() ([]*container.ContainerInfo, error) {
+	list, err := d.client.ContainerList(context.Background(), types.ContainerListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list docker containers: %v", err)
+	}
+	result := make([]*container.ContainerInfo, 0, len(list))
+	for _, c := range list {
+		ci, err := d.GetContainerByID(c.ID)
+		if err != nil {
+			// 容器可能在列出后立即退出，忽略即可
+			continue
+		}
+		result = append(result, ci)
+	}
+	return result, nil
+}
+
+// WatchContainerEvents 监听Docker容器启动/退出事件
+func (d *DockerRuntime) WatchContainerEvents(ctx context.Context) (<-chan container.ContainerEvent, <-chan error) {
+	msgs, errs := d.client.Events(ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("event", "start"),
+			filters.Arg("event", "die"),
+		),
+	})
+	out := make(chan container.ContainerEvent)
+	outErr := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(outErr)
+		for {
+			select {
+			case msg := <-msgs:
+				ev := container.ContainerEvent{ContainerID: msg.Actor.ID}
+				switch msg.Action {
+				case "start":
+					ev.Type = container.ContainerEventStart
+				case "die":
+					ev.Type = container.ContainerEventStop
+				default:
+					continue
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				if err != nil && ctx.Err() == nil {
+					outErr <- err
+				}
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, outErr
+}
+
 // Close 关闭Docker客户端连接
 func (d *DockerRuntime) Close() error {
 	if d.client != nil {
@@ -116,3 +182,21 @@ func (d *DockerRuntime) ResetLimits(container *container.ContainerInfo) error {
 	}
 	return d.cgroup.ResetLimits(cgroupPath, majMin)
 }
+
+// SetDeviceLimits 对指定设备（major:minor）设置IOPS和BPS限制，用于数据盘以外的设备（如PVC所在的盘）
+func (d *DockerRuntime) SetDeviceLimits(container *container.ContainerInfo, majMin string, riops, wiops, rbps, wbps int) error {
+	cgroupPath, err := d.getCgroupPath(container.ID, container.CgroupParent)
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup path for container %s: %v", container.ID, err)
+	}
+	return d.cgroup.SetLimits(cgroupPath, majMin, riops, wiops, rbps, wbps)
+}
+
+// ResetDeviceLimits 解除指定设备（major:minor）的所有限速
+func (d *DockerRuntime) ResetDeviceLimits(container *container.ContainerInfo, majMin string) error {
+	cgroupPath, err := d.getCgroupPath(container.ID, container.CgroupParent)
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup path for container %s: %v", container.ID, err)
+	}
+	return d.cgroup.ResetLimits(cgroupPath, majMin)
+}
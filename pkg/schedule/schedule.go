@@ -0,0 +1,203 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // 内置时区数据，精简镜像中没有 /usr/share/zoneinfo 时仍可解析 timezone
+
+	"KubeDiskGuard/pkg/annotationkeys"
+)
+
+// Window 按星期和时段生效的限速窗口
+// start > end 表示跨越午夜（如 22:00-06:00，days 指开始的那一天），start == end 表示全天
+type Window struct {
+	Name    string            `json:"name"`
+	Days    string            `json:"days,omitempty"`    // 星期，如 Mon-Fri、Sat,Sun，为空或 * 表示每天
+	Start   string            `json:"start"`             // 开始时间 HH:MM
+	End     string            `json:"end"`               // 结束时间 HH:MM
+	Limits  map[string]string `json:"limits,omitempty"`  // 限速配置，key与Pod注解一致，如 {"write-iops": "300"}
+	Profile string            `json:"profile,omitempty"` // 引用命名限速配置，Limits 覆盖其中的值
+
+	days       [7]bool
+	start, end int // 一天中的分钟数
+}
+
+// Schedule 限速时间表，窗口按顺序匹配，首个生效的窗口决定限速；没有生效的窗口时不改变限速
+type Schedule struct {
+	Timezone string   `json:"timezone,omitempty"` // IANA 时区，如 Asia/Shanghai，默认 UTC
+	Windows  []Window `json:"windows"`
+
+	loc *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse 解析注解中的JSON时间表
+func Parse(value string) (*Schedule, error) {
+	var s Schedule
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return nil, fmt.Errorf("failed to parse schedule: %v", err)
+	}
+	if err := s.Compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Compile 校验时区和窗口定义，嵌入其他配置（如命名限速配置）中反序列化后需调用
+func (s *Schedule) Compile() error {
+	var err error
+	if s.loc, err = time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid schedule timezone %q: %v", s.Timezone, err)
+	}
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("schedule must define at least one window")
+	}
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		if w.Name == "" {
+			return fmt.Errorf("schedule window %d must set name", i)
+		}
+		if w.days, err = parseDays(w.Days); err != nil {
+			return fmt.Errorf("schedule window %s: %v", w.Name, err)
+		}
+		if w.start, err = parseClock(w.Start); err != nil {
+			return fmt.Errorf("schedule window %s start: %v", w.Name, err)
+		}
+		if w.end, err = parseClock(w.End); err != nil {
+			return fmt.Errorf("schedule window %s end: %v", w.Name, err)
+		}
+	}
+	return nil
+}
+
+// parseDays 解析星期表达式，支持逗号分隔的单日和区间（如 Mon-Fri、Fri-Mon）
+func parseDays(expr string) ([7]bool, error) {
+	var days [7]bool
+	expr = strings.TrimSpace(expr)
+	if expr == "" || expr == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+	for _, part := range strings.Split(expr, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		from, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return days, fmt.Errorf("invalid day %q", bounds[0])
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+				return days, fmt.Errorf("invalid day %q", bounds[1])
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock 解析 HH:MM，返回一天中的分钟数
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Active 返回 now 时刻生效的首个窗口，没有生效的窗口时返回nil
+func (s *Schedule) Active(now time.Time) *Window {
+	if s == nil {
+		return nil
+	}
+	local := now.In(s.loc)
+	minute := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		switch {
+		case w.start == w.end:
+			if w.days[today] {
+				return w
+			}
+		case w.start < w.end:
+			if w.days[today] && minute >= w.start && minute < w.end {
+				return w
+			}
+		default:
+			if (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+				return w
+			}
+		}
+	}
+	return nil
+}
+
+// NextSwitch 返回 now 之后最近的一个窗口边界（任一窗口的开始或结束时刻），用于展示下次切换时间
+func (s *Schedule) NextSwitch(now time.Time) time.Time {
+	var next time.Time
+	if s == nil {
+		return next
+	}
+	local := now.In(s.loc)
+	consider := func(t time.Time) {
+		if t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	// 每个窗口每周至少有一个边界，检查前后8天即可覆盖跨午夜的窗口
+	for d := -1; d <= 7; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, s.loc)
+		for i := range s.Windows {
+			w := &s.Windows[i]
+			if !w.days[day.Weekday()] {
+				continue
+			}
+			startAt := time.Date(day.Year(), day.Month(), day.Day(), w.start/60, w.start%60, 0, 0, s.loc)
+			endDay := day.Day()
+			if w.end <= w.start {
+				endDay++
+			}
+			endAt := time.Date(day.Year(), day.Month(), endDay, w.end/60, w.end%60, 0, 0, s.loc)
+			consider(startAt)
+			consider(endAt)
+		}
+	}
+	return next
+}
+
+// Annotations 将窗口限速配置转换为带前缀的注解形式，便于复用注解解析逻辑
+func (w *Window) Annotations(prefix string) map[string]string {
+	annotations := make(map[string]string, len(w.Limits)+1)
+	if w.Profile != "" {
+		annotations[prefix+"/"+annotationkeys.ProfileAnnotationKey] = w.Profile
+	}
+	for k, v := range w.Limits {
+		annotations[prefix+"/"+k] = v
+	}
+	return annotations
+}
+
+// Status 按时间表限速的容器当前所处窗口，供API展示
+type Status struct {
+	ContainerID   string     `json:"container_id"`
+	ContainerName string     `json:"container_name,omitempty"`
+	PodName       string     `json:"pod_name"`
+	Namespace     string     `json:"namespace"`
+	Window        string     `json:"window,omitempty"` // 当前生效的窗口，为空表示不在任何窗口内
+	Timezone      string     `json:"timezone,omitempty"`
+	NextSwitchAt  *time.Time `json:"next_switch_at,omitempty"`
+}
@@ -0,0 +1,74 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveWindow(t *testing.T) {
+	sched, err := Parse(`{"timezone": "Asia/Shanghai", "windows": [
+		{"name": "business", "days": "Mon-Fri", "start": "09:00", "end": "18:00", "limits": {"write-iops": "300"}},
+		{"name": "night", "start": "22:00", "end": "06:00", "profile": "batch"}
+	]}`)
+	assert.NoError(t, err)
+
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	cases := []struct {
+		name     string
+		at       time.Time
+		expected string
+	}{
+		// 2024-06-03 为周一
+		{"business hours", time.Date(2024, 6, 3, 10, 0, 0, 0, loc), "business"},
+		{"end is exclusive", time.Date(2024, 6, 3, 18, 0, 0, 0, loc), ""},
+		{"weekend daytime", time.Date(2024, 6, 8, 10, 0, 0, 0, loc), ""},
+		{"night before midnight", time.Date(2024, 6, 3, 23, 0, 0, 0, loc), "night"},
+		{"night after midnight", time.Date(2024, 6, 4, 5, 59, 0, 0, loc), "night"},
+		// 时区换算：UTC 02:00 为上海 10:00
+		{"timezone", time.Date(2024, 6, 3, 2, 0, 0, 0, time.UTC), "business"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := sched.Active(tc.at)
+			if tc.expected == "" {
+				assert.Nil(t, w)
+				return
+			}
+			assert.NotNil(t, w)
+			assert.Equal(t, tc.expected, w.Name)
+		})
+	}
+
+	night := sched.Active(time.Date(2024, 6, 3, 23, 0, 0, 0, loc))
+	assert.Equal(t, map[string]string{"kubediskguard.io/profile": "batch"}, night.Annotations("kubediskguard.io"))
+}
+
+func TestNextSwitch(t *testing.T) {
+	sched, err := Parse(`{"windows": [{"name": "business", "days": "Mon-Fri", "start": "09:00", "end": "18:00"}]}`)
+	assert.NoError(t, err)
+
+	// 周一 10:00 → 当天 18:00 结束
+	assert.Equal(t, time.Date(2024, 6, 3, 18, 0, 0, 0, time.UTC), sched.NextSwitch(time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)))
+	// 周五 19:00 → 下周一 09:00 开始
+	assert.Equal(t, time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC), sched.NextSwitch(time.Date(2024, 6, 7, 19, 0, 0, 0, time.UTC)))
+
+	var empty *Schedule
+	assert.Nil(t, empty.Active(time.Now()))
+	assert.True(t, empty.NextSwitch(time.Now()).IsZero())
+}
+
+func TestParseInvalid(t *testing.T) {
+	for name, value := range map[string]string{
+		"json":     `{"windows": [`,
+		"empty":    `{"windows": []}`,
+		"timezone": `{"timezone": "Mars/Olympus", "windows": [{"name": "a", "start": "09:00", "end": "18:00"}]}`,
+		"day":      `{"windows": [{"name": "a", "days": "Mon-Funday", "start": "09:00", "end": "18:00"}]}`,
+		"clock":    `{"windows": [{"name": "a", "start": "9am", "end": "18:00"}]}`,
+		"name":     `{"windows": [{"start": "09:00", "end": "18:00"}]}`,
+	} {
+		_, err := Parse(value)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/budget"
+	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/device"
+	"KubeDiskGuard/pkg/limits"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodeBudget 计算节点IO预算：先按设备实测性能的百分比，再由绝对值配置覆盖，各项为0表示不做预算
+func nodeBudget(cfg *config.Config) (limits.Limits, error) {
+	var total limits.Limits
+	if cfg.NodeDeviceProfileFile != "" {
+		capacity, err := device.LoadProfile(cfg.NodeDeviceProfileFile)
+		if err != nil {
+			return total, err
+		}
+		percent := func(v int) int { return int(int64(v) * int64(cfg.NodeBudgetPercent) / 100) }
+		total = limits.Limits{
+			ReadIOPS: percent(capacity.ReadIOPS), WriteIOPS: percent(capacity.WriteIOPS),
+			ReadBPS: percent(capacity.ReadBPS), WriteBPS: percent(capacity.WriteBPS),
+		}
+	}
+	for _, item := range []struct{ value, dst *int }{
+		{&cfg.NodeReadIOPSBudget, &total.ReadIOPS}, {&cfg.NodeWriteIOPSBudget, &total.WriteIOPS},
+		{&cfg.NodeReadBPSBudget, &total.ReadBPS}, {&cfg.NodeWriteBPSBudget, &total.WriteBPS},
+	} {
+		if *item.value > 0 {
+			*item.dst = *item.value
+		}
+	}
+	return total, nil
+}
+
+// budgetShare 返回节点预算分配给容器的份额，未启用预算或尚未参与分配时返回false
+func (s *KubeDiskGuardService) budgetShare(pod corev1.Pod, containerName string) (limits.Limits, bool) {
+	s.budgetMu.Lock()
+	defer s.budgetMu.Unlock()
+	share, ok := s.budgetShares[pod.Namespace+"/"+pod.Name+"/"+containerName]
+	return share, ok
+}
+
+// podBudgetWeight 解析Pod的预算权重注解，未设置或无效时为1
+func (s *KubeDiskGuardService) podBudgetWeight(pod *corev1.Pod) float64 {
+	value, ok := pod.Annotations[s.Config.SmartLimitAnnotationPrefix+"/"+annotationkeys.BudgetWeightAnnotationKey]
+	if !ok {
+		return 1
+	}
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil || weight <= 0 {
+		log.Printf("Ignoring invalid budget weight %q on pod %s/%s", value, pod.Namespace, pod.Name)
+		return 1
+	}
+	return weight
+}
+
+// containerUsage 返回容器最近15分钟的平均IO，来自智能限速采集的历史数据
+func (s *KubeDiskGuardService) containerUsage(containerID string) budget.Usage {
+	if s.smartLimit == nil {
+		return budget.Usage{}
+	}
+	history, ok := s.smartLimit.GetContainerHistory(containerID)
+	if !ok {
+		return budget.Usage{}
+	}
+	trend := s.smartLimit.AnalyzeContainerTrend(history.Stats)
+	return budget.Usage{ReadIOPS: trend.ReadIOPS15m, WriteIOPS: trend.WriteIOPS15m, ReadBPS: trend.ReadBPS15m, WriteBPS: trend.WriteBPS15m}
+}
+
+// sumCaps 累加Pod内各容器的上限，任一容器某项不限时Pod该项也不限
+func sumCaps(a, b limits.Limits) limits.Limits {
+	add := func(x, y int) int {
+		if x == 0 || y == 0 {
+			return 0
+		}
+		return x + y
+	}
+	return limits.Limits{
+		ReadIOPS: add(a.ReadIOPS, b.ReadIOPS), WriteIOPS: add(a.WriteIOPS, b.WriteIOPS),
+		ReadBPS: add(a.ReadBPS, b.ReadBPS), WriteBPS: add(a.WriteBPS, b.WriteBPS),
+	}
+}
+
+// runBudget 定期按各Pod的近期用量重新分配节点预算，份额变化的Pod重新下发限速，ctx 取消后返回
+func (s *KubeDiskGuardService) runBudget(ctx context.Context, controller *podController) {
+	if s.budgetTotal.IsZero() {
+		return
+	}
+	interval := time.Duration(s.Config.NodeBudgetInterval) * time.Second
+	if interval <= 0 {
+		log.Println("Node IO budget rebalance disabled, NODE_BUDGET_INTERVAL must be positive")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var pods []*corev1.Pod
+			for _, obj := range controller.informer.GetStore().List() {
+				if pod, ok := obj.(*corev1.Pod); ok {
+					pods = append(pods, pod)
+				}
+			}
+			for _, key := range s.rebalanceBudget(pods, s.containerUsage, time.Now()) {
+				controller.resyncPod(key)
+			}
+		}
+	}
+}
+
+// rebalanceBudget 先按权重在Pod间、再在Pod内各容器间做 max-min 公平分配，返回份额发生变化的Pod key
+// 每个Pod的上限为其容器自身限速之和，需求为容器近期用量之和；声明了IO资源的容器只扣除预算，不参与分配
+func (s *KubeDiskGuardService) rebalanceBudget(pods []*corev1.Pod, usage func(containerID string) budget.Usage, now time.Time) []string {
+	prefix := s.Config.SmartLimitAnnotationPrefix
+	type podClaim struct {
+		pod        *corev1.Pod
+		member     budget.Member
+		containers []budget.Member
+	}
+	var claims []podClaim
+	var reserved limits.Limits
+	for _, pod := range pods {
+		if !s.ShouldProcessPod(*pod) {
+			continue
+		}
+		c := podClaim{pod: pod, member: budget.Member{Key: pod.Namespace + "/" + pod.Name, Weight: s.podBudgetWeight(pod)}}
+		for _, cs := range s.podContainerStatuses(*pod) {
+			containerID := parseRuntimeID(cs.ContainerID)
+			if containerID == "" || IsContainerExcludedByAnnotation(pod.Annotations, cs.Name, prefix) || !s.filterPodContainer(*pod, cs.Name, cs.Image).Included() {
+				continue
+			}
+			if declared, ok := containerResourceLimits(*pod, cs.Name, prefix, limits.Limits{}); ok {
+				// 声明了IO资源的容器已从预算中扣除，不再参与分配，也不计入Pod的需求和上限
+				reserved = addLimits(reserved, declared)
+				continue
+			}
+			m := budget.Member{Key: cs.Name, Demand: usage(containerID), Cap: s.resolvePodContainerCap(*pod, cs.Name).Effective}
+			if len(c.containers) == 0 {
+				c.member.Cap = m.Cap
+			} else {
+				c.member.Cap = sumCaps(c.member.Cap, m.Cap)
+			}
+			c.member.Demand = c.member.Demand.Add(m.Demand)
+			c.containers = append(c.containers, m)
+		}
+		if len(c.containers) > 0 {
+			claims = append(claims, c)
+		}
+	}
+
+	members := make([]budget.Member, len(claims))
+	for i, c := range claims {
+		members[i] = c.member
+	}
+	// 容器声明的IO资源为最终值，先从预算中扣除，其余额度在各Pod间分配
+	podShares := budget.Allocate(reserveBudget(s.budgetTotal, reserved), members)
+
+	shares := make(map[string]limits.Limits)
+	status := budget.Status{Total: s.budgetTotal, Reserved: reserved, Pods: make([]budget.PodAllocation, 0, len(claims)), UpdatedAt: &now}
+	for _, c := range claims {
+		share := podShares[c.member.Key]
+		for name, containerShare := range budget.Allocate(share, c.containers) {
+			shares[c.member.Key+"/"+name] = containerShare
+		}
+		status.Pods = append(status.Pods, budget.PodAllocation{
+			Namespace: c.pod.Namespace, PodName: c.pod.Name, Weight: c.member.Weight,
+			Demand: c.member.Demand, Cap: c.member.Cap, Allocation: share,
+		})
+	}
+	sort.Slice(status.Pods, func(i, j int) bool {
+		if status.Pods[i].Namespace != status.Pods[j].Namespace {
+			return status.Pods[i].Namespace < status.Pods[j].Namespace
+		}
+		return status.Pods[i].PodName < status.Pods[j].PodName
+	})
+
+	s.budgetMu.Lock()
+	previous := s.budgetShares
+	s.budgetShares, s.budgetStatus = shares, status
+	s.budgetMu.Unlock()
+
+	var changed []string
+	for _, c := range claims {
+		key := c.member.Key
+		for _, m := range c.containers {
+			if old, ok := previous[key+"/"+m.Key]; !ok || !reflect.DeepEqual(old, shares[key+"/"+m.Key]) {
+				changed = append(changed, key)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// NodeBudget 返回节点IO预算及最近一次的分配结果
+func (s *KubeDiskGuardService) NodeBudget() budget.Status {
+	s.budgetMu.Lock()
+	defer s.budgetMu.Unlock()
+	if s.budgetStatus.UpdatedAt == nil {
+		return budget.Status{Total: s.budgetTotal, Pods: []budget.PodAllocation{}}
+	}
+	return s.budgetStatus
+}
+
+// addLimits 逐项求和
+func addLimits(a, b limits.Limits) limits.Limits {
+	return limits.Limits{ReadIOPS: a.ReadIOPS + b.ReadIOPS, WriteIOPS: a.WriteIOPS + b.WriteIOPS, ReadBPS: a.ReadBPS + b.ReadBPS, WriteBPS: a.WriteBPS + b.WriteBPS}
+}
+
+// reserveBudget 从预算中扣除已声明的资源，未做预算的项保持0；扣除后至少保留1，避免变为0（不做预算）
+func reserveBudget(total, reserved limits.Limits) limits.Limits {
+	result := total
+	for _, key := range resourceLimitKeys {
+		t, r := limitField(&result, key), *limitField(&reserved, key)
+		if *t == 0 {
+			continue
+		}
+		*t -= r
+		if *t < 1 {
+			*t = 1
+		}
+	}
+	return result
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// podController 基于SharedInformer + 限速工作队列的Pod同步控制器
+// informer 负责断线重连与周期性全量resync，工作队列按 namespace/name 去重，失败时按指数退避重试
+type podController struct {
+	informer   cache.SharedIndexInformer
+	queue      workqueue.RateLimitingInterface
+	maxRetries int
+
+	// syncHandler 对单个Pod下发限速，返回错误时重新入队
+	syncHandler func(pod corev1.Pod) error
+	// shouldProcess 判断Pod是否需要处理
+	shouldProcess func(pod corev1.Pod) bool
+
+	mu        sync.Mutex
+	podStates map[string]PodAnnotationState // 已成功同步的Pod状态，用于跳过无变化的更新
+}
+
+// newPodController 创建Pod同步控制器，resyncPeriod 为0时不做周期性全量同步
+func newPodController(lw cache.ListerWatcher, resyncPeriod time.Duration, maxRetries int, shouldProcess func(corev1.Pod) bool, syncHandler func(corev1.Pod) error) *podController {
+	c := &podController{
+		informer:      cache.NewSharedIndexInformer(lw, &corev1.Pod{}, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+		queue:         workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		maxRetries:    maxRetries,
+		syncHandler:   syncHandler,
+		shouldProcess: shouldProcess,
+		podStates:     make(map[string]PodAnnotationState),
+	}
+
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok1 := oldObj.(*corev1.Pod)
+			newPod, ok2 := newObj.(*corev1.Pod)
+			// resourceVersion 未变化说明是周期性resync，清除缓存状态以强制重新下发
+			if ok1 && ok2 && oldPod.ResourceVersion == newPod.ResourceVersion {
+				if key, err := cache.MetaNamespaceKeyFunc(newPod); err == nil {
+					c.forgetState(key)
+				}
+			}
+			c.enqueue(newObj)
+		},
+		DeleteFunc: c.enqueue,
+	})
+	return c
+}
+
+// enqueue 将对象的 namespace/name 加入工作队列，兼容删除时的 DeletedFinalStateUnknown
+func (c *podController) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run 启动informer与worker，阻塞直到 stopCh 关闭
+// 关闭后不再接收新事件，等待队列中已有及进行中的任务处理完再返回
+func (c *podController) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+
+	go c.informer.Run(stopCh)
+
+	log.Println("Waiting for pod informer cache to sync...")
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		c.queue.ShutDown()
+		select {
+		case <-stopCh:
+			return nil
+		default:
+		}
+		return fmt.Errorf("failed to wait for pod informer cache to sync")
+	}
+	log.Printf("Pod informer cache synced, starting %d workers", workers)
+
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runWorker()
+		}()
+	}
+
+	<-stopCh
+	log.Println("Stopping pod controller, draining work queue...")
+	c.queue.ShutDownWithDrain()
+	wg.Wait()
+	log.Println("Pod controller stopped")
+	return nil
+}
+
+func (c *podController) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+// processNextItem 处理队列中的下一个key，队列关闭时返回false
+func (c *podController) processNextItem() bool {
+	obj, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(obj)
+
+	key := obj.(string)
+	err := c.syncPod(key)
+	c.handleErr(err, key)
+	return true
+}
+
+// handleErr 失败时按限速器退避重新入队，超过最大重试次数后放弃
+func (c *podController) handleErr(err error, key string) {
+	if err == nil {
+		c.queue.Forget(key)
+		return
+	}
+	if c.queue.NumRequeues(key) < c.maxRetries {
+		log.Printf("Failed to sync pod %s, will retry: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return
+	}
+	c.queue.Forget(key)
+	utilruntime.HandleError(fmt.Errorf("dropping pod %s out of the queue after %d retries: %v", key, c.maxRetries, err))
+}
+
+// syncPod 从informer缓存读取Pod并下发限速，注解与容器均未变化时跳过
+func (c *podController) syncPod(key string) error {
+	obj, exists, err := c.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to get pod %s from cache: %v", key, err)
+	}
+	if !exists {
+		c.forgetState(key)
+		return nil
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T for key %s", obj, key)
+	}
+	if !c.shouldProcess(*pod) {
+		return nil
+	}
+
+	state := PodAnnotationState{
+		Annotations:  pod.Annotations,
+		ContainerIDs: podContainerIDs(*pod),
+	}
+	c.mu.Lock()
+	old, synced := c.podStates[key]
+	c.mu.Unlock()
+	if synced && reflect.DeepEqual(old, state) {
+		return nil
+	}
+
+	if err := c.syncHandler(*pod); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.podStates[key] = state
+	c.mu.Unlock()
+	return nil
+}
+
+// resyncNamespace 清除命名空间下所有Pod的已同步状态并重新入队，用于命名空间默认限速变化
+func (c *podController) resyncNamespace(namespace string) {
+	objs, err := c.informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, obj := range objs {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+		c.forgetState(key)
+		c.queue.Add(key)
+	}
+}
+
+// resyncPod 清除Pod的已同步状态并重新入队，用于注解未变化但生效限速变化的情况（如时间窗口切换）
+func (c *podController) resyncPod(key string) {
+	c.forgetState(key)
+	c.queue.Add(key)
+}
+
+// forgetState 清除Pod的已同步状态
+func (c *podController) forgetState(key string) {
+	c.mu.Lock()
+	delete(c.podStates, key)
+	c.mu.Unlock()
+}
+
+// podContainerIDs 返回Pod当前所有容器（init、普通、临时容器）的运行时ID
+// 容器重启或新容器启动后ID变化，会触发重新下发
+func podContainerIDs(pod corev1.Pod) []string {
+	ids := make([]string, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses)+len(pod.Status.EphemeralContainerStatuses))
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses, pod.Status.EphemeralContainerStatuses} {
+		for _, cs := range statuses {
+			ids = append(ids, cs.ContainerID)
+		}
+	}
+	return ids
+}
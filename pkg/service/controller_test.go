@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodControllerRetryAndDedupe(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Annotations: map[string]string{"io-limit/write-iops": "100"}},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", ContainerID: "containerd://abc"}},
+		},
+	}
+	client := fake.NewSimpleClientset(pod)
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Pods("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Pods("").Watch(context.TODO(), options)
+		},
+	}
+
+	var mu sync.Mutex
+	calls := 0
+	handler := func(p corev1.Pod) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		// 第一次模拟运行时瞬时失败，应被重新入队重试
+		if calls == 1 {
+			return fmt.Errorf("transient error")
+		}
+		return nil
+	}
+	getCalls := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	c := newPodController(lw, 0, 3, func(corev1.Pod) bool { return true }, handler)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go c.Run(1, stopCh)
+
+	assert.Eventually(t, func() bool { return getCalls() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// 注解与容器未变化的更新应被跳过
+	c.queue.Add("default/app")
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, getCalls())
+
+	// 容器重建（ID变化）应触发重新下发
+	updated := pod.DeepCopy()
+	updated.Status.ContainerStatuses[0].ContainerID = "containerd://def"
+	_, err := client.CoreV1().Pods("default").UpdateStatus(context.TODO(), updated, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return getCalls() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	// 删除后清理已同步状态
+	assert.NoError(t, client.CoreV1().Pods("default").Delete(context.TODO(), "app", metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.podStates) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
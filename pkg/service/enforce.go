@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"KubeDiskGuard/pkg/audit"
+	"KubeDiskGuard/pkg/container"
+	"KubeDiskGuard/pkg/limits"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// enforceContainerLimits 下发容器在数据盘及PVC所在设备上的限速
+// PVC设备先下发：数据盘下发成功后会覆盖该容器的下发记录，需先读取上次下发的设备
+func (s *KubeDiskGuardService) enforceContainerLimits(containerInfo *container.ContainerInfo, limit AppliedLimit) error {
+	return utilerrors.NewAggregate([]error{
+		s.enforceDeviceLimits(containerInfo, limit),
+		s.enforceDataDiskLimits(containerInfo, limit),
+	})
+}
+
+// enforceDataDiskLimits 下发容器在数据盘上的限速，四项均为0时解除限速
+// dry-run 模式下计算结果相同，但只记录审计，不写cgroup
+func (s *KubeDiskGuardService) enforceDataDiskLimits(containerInfo *container.ContainerInfo, limit AppliedLimit) error {
+	reset := limit.ReadIops == 0 && limit.WriteIops == 0 && limit.ReadBps == 0 && limit.WriteBps == 0
+	rec := audit.Record{
+		Source:        audit.SourceService,
+		Action:        audit.ActionSetLimits,
+		DryRun:        s.Config.DryRun,
+		Namespace:     limit.Namespace,
+		PodName:       limit.PodName,
+		ContainerName: limit.ContainerName,
+		ContainerID:   containerInfo.ID,
+		ReadIOPS:      limit.ReadIops,
+		WriteIOPS:     limit.WriteIops,
+		ReadBPS:       limit.ReadBps,
+		WriteBPS:      limit.WriteBps,
+	}
+	if reset {
+		rec.Action = audit.ActionResetLimits
+	}
+
+	if s.Config.DryRun {
+		log.Printf("[DRY-RUN] Would %s for container %s (pod: %s/%s, container: %s): riops=%d wiops=%d rbps=%d wbps=%d",
+			rec.Action, containerInfo.ID, limit.Namespace, limit.PodName, limit.ContainerName, limit.ReadIops, limit.WriteIops, limit.ReadBps, limit.WriteBps)
+		s.audit.Record(rec)
+		return nil
+	}
+
+	var err error
+	if reset {
+		err = s.runtime.ResetLimits(containerInfo)
+	} else {
+		err = s.runtime.SetLimits(containerInfo, limit.ReadIops, limit.WriteIops, limit.ReadBps, limit.WriteBps)
+	}
+	if err != nil {
+		rec.Error = err.Error()
+		s.audit.Record(rec)
+		containerFail.Inc()
+		log.Printf("Failed to %s for container %s: %v", rec.Action, containerInfo.ID, err)
+		return fmt.Errorf("failed to %s for container %s: %v", rec.Action, containerInfo.ID, err)
+	}
+	s.audit.Record(rec)
+
+	limit.ContainerID = containerInfo.ID
+	if reset {
+		log.Printf("Reset all limits for container %s (pod: %s/%s, container: %s)", containerInfo.ID, limit.Namespace, limit.PodName, limit.ContainerName)
+		containerReset.Inc()
+		if len(limit.Devices) == 0 {
+			s.forgetApplied(containerInfo.ID)
+		} else {
+			s.recordApplied(limit)
+		}
+		return nil
+	}
+	log.Printf("Applied limits for container %s (pod: %s/%s, container: %s): riops=%d wiops=%d rbps=%d wbps=%d",
+		containerInfo.ID, limit.Namespace, limit.PodName, limit.ContainerName, limit.ReadIops, limit.WriteIops, limit.ReadBps, limit.WriteBps)
+	containerSuccess.Inc()
+	s.recordApplied(limit)
+	return nil
+}
+
+// enforceDeviceLimits 下发容器在PVC所在设备上的限速，上次下发过、本次不再需要的设备解除限速
+func (s *KubeDiskGuardService) enforceDeviceLimits(containerInfo *container.ContainerInfo, limit AppliedLimit) error {
+	var errs []error
+	apply := func(d limits.VolumeLimit, reset bool) {
+		rec := audit.Record{
+			Source:        audit.SourceService,
+			Action:        audit.ActionSetLimits,
+			DryRun:        s.Config.DryRun,
+			Namespace:     limit.Namespace,
+			PodName:       limit.PodName,
+			ContainerName: limit.ContainerName,
+			ContainerID:   containerInfo.ID,
+			Device:        d.Device,
+			ReadIOPS:      d.ReadIOPS,
+			WriteIOPS:     d.WriteIOPS,
+			ReadBPS:       d.ReadBPS,
+			WriteBPS:      d.WriteBPS,
+			Reason:        "volume " + d.Volume,
+		}
+		if reset {
+			rec.Action = audit.ActionResetLimits
+		}
+		if s.Config.DryRun {
+			log.Printf("[DRY-RUN] Would %s on device %s for container %s (pod: %s/%s, volume: %s): riops=%d wiops=%d rbps=%d wbps=%d",
+				rec.Action, d.Device, containerInfo.ID, limit.Namespace, limit.PodName, d.Volume, d.ReadIOPS, d.WriteIOPS, d.ReadBPS, d.WriteBPS)
+			s.audit.Record(rec)
+			return
+		}
+
+		var err error
+		if reset {
+			err = s.runtime.ResetDeviceLimits(containerInfo, d.Device)
+		} else {
+			err = s.runtime.SetDeviceLimits(containerInfo, d.Device, d.ReadIOPS, d.WriteIOPS, d.ReadBPS, d.WriteBPS)
+		}
+		if err != nil {
+			rec.Error = err.Error()
+			s.audit.Record(rec)
+			log.Printf("Failed to %s on device %s for container %s: %v", rec.Action, d.Device, containerInfo.ID, err)
+			errs = append(errs, fmt.Errorf("failed to %s on device %s for container %s: %v", rec.Action, d.Device, containerInfo.ID, err))
+			return
+		}
+		s.audit.Record(rec)
+		log.Printf("Applied %s on device %s for container %s (pod: %s/%s, volume: %s): riops=%d wiops=%d rbps=%d wbps=%d",
+			rec.Action, d.Device, containerInfo.ID, limit.Namespace, limit.PodName, d.Volume, d.ReadIOPS, d.WriteIOPS, d.ReadBPS, d.WriteBPS)
+	}
+
+	current := make(map[string]bool, len(limit.Devices))
+	for _, d := range limit.Devices {
+		current[d.Device] = true
+		apply(d, false)
+	}
+	for _, d := range s.appliedDevices(containerInfo.ID) {
+		if !current[d.Device] {
+			apply(limits.VolumeLimit{Volume: d.Volume, Device: d.Device}, true)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// GetAuditRecorder 返回限速动作审计记录器
+func (s *KubeDiskGuardService) GetAuditRecorder() *audit.Recorder {
+	return s.audit
+}
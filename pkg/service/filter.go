@@ -0,0 +1,74 @@
+package service
+
+import (
+	"KubeDiskGuard/pkg/container"
+	"KubeDiskGuard/pkg/filter"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podFilterTarget 构造Pod级过滤对象，命名空间标签来自命名空间监听器（kubelet API 模式下为空）
+func (s *KubeDiskGuardService) podFilterTarget(pod corev1.Pod) filter.Target {
+	return filter.Target{
+		Namespace:       pod.Namespace,
+		NamespaceLabels: s.namespaces.Load().Labels(pod.Namespace),
+		PodLabels:       pod.Labels,
+	}
+}
+
+// filterPodContainer 判断Pod内某个容器是否需要处理，name 为Pod spec中的容器名
+// image 取自Pod status（containerStatuses[].image），在查询运行时之前即可判断，与过滤结果API展示的镜像一致
+func (s *KubeDiskGuardService) filterPodContainer(pod corev1.Pod, name, image string) filter.Result {
+	target := s.podFilterTarget(pod)
+	target.ContainerName, target.Image = name, image
+	return s.filters.EvaluateContainer(target)
+}
+
+// filterStandaloneContainer 独立模式下判断容器是否需要处理，pod_selector 匹配容器标签
+func (s *KubeDiskGuardService) filterStandaloneContainer(containerInfo *container.ContainerInfo) filter.Result {
+	return s.filters.EvaluateContainer(filter.Target{
+		PodLabels:     containerInfo.Labels,
+		ContainerName: containerInfo.Name,
+		Image:         containerInfo.Image,
+	})
+}
+
+// FilterDecisions 返回本节点各容器的过滤结果及命中的规则，namespace/podName 为空时不过滤
+func (s *KubeDiskGuardService) FilterDecisions(namespace, podName string) []filter.Decision {
+	var result []filter.Decision
+	if s.Config.StandaloneMode {
+		containers, err := s.runtime.ListContainers()
+		if err != nil {
+			return nil
+		}
+		for _, c := range containers {
+			result = append(result, filter.Decision{
+				ContainerName: c.Name, ContainerID: c.ID, Image: c.Image,
+				Result: s.filterStandaloneContainer(c),
+			})
+		}
+		return result
+	}
+
+	controller := s.pods.Load()
+	if controller == nil {
+		return nil
+	}
+	for _, obj := range controller.informer.GetStore().List() {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		if (namespace != "" && pod.Namespace != namespace) || (podName != "" && pod.Name != podName) {
+			continue
+		}
+		for _, cs := range s.podContainerStatuses(*pod) {
+			result = append(result, filter.Decision{
+				Namespace: pod.Namespace, PodName: pod.Name,
+				ContainerName: cs.Name, ContainerID: parseRuntimeID(cs.ContainerID), Image: cs.Image,
+				Result: s.filterPodContainer(*pod, cs.Name, cs.Image),
+			})
+		}
+	}
+	return result
+}
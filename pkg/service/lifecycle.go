@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"KubeDiskGuard/pkg/limits"
+	"KubeDiskGuard/pkg/smartlimit"
+)
+
+// 退出策略
+const (
+	OnExitKeep    = "keep"    // 保留已下发的限速（默认）
+	OnExitRelease = "release" // 解除本实例下发的限速
+	OnExitHandoff = "handoff" // 保留限速并写入状态快照，供下一个实例接管
+)
+
+// stateSnapshotVersion 状态快照格式版本
+const stateSnapshotVersion = 1
+
+// AppliedLimit 本实例下发到容器cgroup的限速记录
+type AppliedLimit struct {
+	ContainerID   string    `json:"container_id"`
+	ContainerName string    `json:"container_name,omitempty"`
+	PodName       string    `json:"pod_name,omitempty"`
+	Namespace     string    `json:"namespace,omitempty"`
+	ReadIops      int       `json:"read_iops"`
+	WriteIops     int       `json:"write_iops"`
+	ReadBps       int       `json:"read_bps"`
+	WriteBps      int       `json:"write_bps"`
+	AppliedAt     time.Time `json:"applied_at"`
+
+	Devices []limits.VolumeLimit `json:"devices,omitempty"` // PVC所在设备的限速
+}
+
+// StateSnapshot 退出时写入的状态快照，下一个实例启动时读取
+type StateSnapshot struct {
+	Version     int                       `json:"version"`
+	NodeName    string                    `json:"node_name,omitempty"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	Applied     []AppliedLimit            `json:"applied"`
+	SmartLimits []*smartlimit.LimitStatus `json:"smart_limits,omitempty"`
+}
+
+// recordApplied 记录成功下发的限速
+func (s *KubeDiskGuardService) recordApplied(limit AppliedLimit) {
+	s.appliedMu.Lock()
+	defer s.appliedMu.Unlock()
+	if s.applied == nil {
+		s.applied = make(map[string]AppliedLimit)
+	}
+	if limit.AppliedAt.IsZero() {
+		limit.AppliedAt = time.Now()
+	}
+	s.applied[limit.ContainerID] = limit
+}
+
+// forgetApplied 容器限速被解除后移除记录
+func (s *KubeDiskGuardService) forgetApplied(containerID string) {
+	s.appliedMu.Lock()
+	defer s.appliedMu.Unlock()
+	delete(s.applied, containerID)
+}
+
+// appliedDevices 返回容器上次下发的PVC设备限速
+func (s *KubeDiskGuardService) appliedDevices(containerID string) []limits.VolumeLimit {
+	s.appliedMu.Lock()
+	defer s.appliedMu.Unlock()
+	return s.applied[containerID].Devices
+}
+
+// appliedLimits 返回当前所有下发记录的副本
+func (s *KubeDiskGuardService) appliedLimits() []AppliedLimit {
+	s.appliedMu.Lock()
+	defer s.appliedMu.Unlock()
+	limits := make([]AppliedLimit, 0, len(s.applied))
+	for _, limit := range s.applied {
+		limits = append(limits, limit)
+	}
+	return limits
+}
+
+// Shutdown 优雅退出：等待智能限速管理器完成进行中的工作，按退出策略处理已下发的限速，最后关闭运行时连接
+// 调用前应先取消传给 Run 的 ctx，使Pod同步队列排空
+func (s *KubeDiskGuardService) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if s.smartLimit != nil {
+			s.smartLimit.Stop()
+		}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Timed out waiting for smart limit manager to stop: %v", ctx.Err())
+	}
+
+	var err error
+	switch s.Config.OnExitPolicy {
+	case OnExitRelease:
+		err = s.releaseAppliedLimits()
+	case OnExitHandoff:
+		err = s.writeStateSnapshot()
+	default:
+		log.Printf("On-exit policy %q, keeping applied limits", s.Config.OnExitPolicy)
+	}
+
+	if closeErr := s.runtime.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// releaseAppliedLimits 解除本实例下发过的限速，不影响其他来源设置的限速
+func (s *KubeDiskGuardService) releaseAppliedLimits() error {
+	limits := s.appliedLimits()
+	log.Printf("Releasing %d limits applied by this instance", len(limits))
+	failed := 0
+	for _, limit := range limits {
+		containerInfo, err := s.runtime.GetContainerByID(limit.ContainerID)
+		if err != nil {
+			// 容器已退出，无需解除
+			log.Printf("Skip releasing limit for container %s: %v", limit.ContainerID, err)
+			continue
+		}
+		if err := s.runtime.ResetLimits(containerInfo); err != nil {
+			log.Printf("Failed to release limit for container %s: %v", limit.ContainerID, err)
+			failed++
+			continue
+		}
+		released := true
+		for _, d := range limit.Devices {
+			if err := s.runtime.ResetDeviceLimits(containerInfo, d.Device); err != nil {
+				log.Printf("Failed to release limit on device %s for container %s: %v", d.Device, limit.ContainerID, err)
+				released = false
+			}
+		}
+		if !released {
+			failed++
+			continue
+		}
+		s.forgetApplied(limit.ContainerID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to release limits for %d containers", failed)
+	}
+	return nil
+}
+
+// writeStateSnapshot 写入状态快照，先写临时文件再重命名，避免被杀时留下不完整的文件
+func (s *KubeDiskGuardService) writeStateSnapshot() error {
+	snapshot := StateSnapshot{
+		Version:   stateSnapshotVersion,
+		NodeName:  os.Getenv("NODE_NAME"),
+		CreatedAt: time.Now(),
+		Applied:   s.appliedLimits(),
+	}
+	if s.smartLimit != nil {
+		for _, status := range s.smartLimit.GetAllLimitStatus() {
+			snapshot.SmartLimits = append(snapshot.SmartLimits, status)
+		}
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state snapshot: %v", err)
+	}
+	file := s.Config.StateSnapshotFile
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create state snapshot directory: %v", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state snapshot: %v", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to rename state snapshot: %v", err)
+	}
+	log.Printf("Wrote state snapshot to %s (%d applied limits, %d smart limits)", file, len(snapshot.Applied), len(snapshot.SmartLimits))
+	return nil
+}
+
+// loadStateSnapshot 读取上一个实例交接的状态快照，读取后删除文件，避免陈旧快照被重复加载
+func (s *KubeDiskGuardService) loadStateSnapshot() error {
+	file := s.Config.StateSnapshotFile
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state snapshot %s: %v", file, err)
+	}
+	defer os.Remove(file)
+
+	var snapshot StateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse state snapshot %s: %v", file, err)
+	}
+	if snapshot.Version != stateSnapshotVersion {
+		return fmt.Errorf("unsupported state snapshot version %d", snapshot.Version)
+	}
+
+	for _, limit := range snapshot.Applied {
+		s.recordApplied(limit)
+	}
+	restored := 0
+	if s.smartLimit != nil {
+		restored = s.smartLimit.RestoreLimitStatusSnapshot(snapshot.SmartLimits)
+	}
+	log.Printf("Loaded state snapshot from %s created at %s: %d applied limits, %d smart limits", file, snapshot.CreatedAt.Format(time.RFC3339), len(snapshot.Applied), restored)
+	return nil
+}
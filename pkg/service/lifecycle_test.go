@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/container"
+	"KubeDiskGuard/pkg/limits"
+	"KubeDiskGuard/pkg/smartlimit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateSnapshotHandoff(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.StateSnapshotFile = filepath.Join(t.TempDir(), "state", "state.json")
+
+	old := &KubeDiskGuardService{Config: cfg}
+	old.recordApplied(AppliedLimit{ContainerID: "c1", PodName: "app", Namespace: "default", WriteIops: 300})
+	old.recordApplied(AppliedLimit{ContainerID: "c2", ReadBps: 1024})
+	old.forgetApplied("c2")
+	assert.NoError(t, old.writeStateSnapshot())
+
+	// 新实例启动时加载快照，并接管本实例下发记录与智能限速状态
+	next := &KubeDiskGuardService{Config: cfg, smartLimit: smartlimit.NewSmartLimitManager(cfg, nil, nil)}
+	assert.NoError(t, next.loadStateSnapshot())
+	applied := next.appliedLimits()
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "c1", applied[0].ContainerID)
+	assert.Equal(t, 300, applied[0].WriteIops)
+	assert.False(t, applied[0].AppliedAt.IsZero())
+
+	// 快照读取后删除，避免下次启动重复加载
+	_, err := os.Stat(cfg.StateSnapshotFile)
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, next.loadStateSnapshot())
+}
+
+// resetRecordingRuntime 记录解除限速的调用，只有 running 中的容器可查询
+type resetRecordingRuntime struct {
+	container.Runtime
+	running      map[string]bool
+	reset        []string
+	resetDevices []string
+	closed       bool
+}
+
+func (r *resetRecordingRuntime) GetContainerByID(containerID string) (*container.ContainerInfo, error) {
+	if !r.running[containerID] {
+		return nil, fmt.Errorf("container %s not found", containerID)
+	}
+	return &container.ContainerInfo{ID: containerID}, nil
+}
+
+func (r *resetRecordingRuntime) ResetLimits(c *container.ContainerInfo) error {
+	r.reset = append(r.reset, c.ID)
+	return nil
+}
+
+func (r *resetRecordingRuntime) ResetDeviceLimits(c *container.ContainerInfo, majMin string) error {
+	r.resetDevices = append(r.resetDevices, c.ID+"@"+majMin)
+	return nil
+}
+
+func (r *resetRecordingRuntime) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestShutdownOnExitPolicy(t *testing.T) {
+	for _, policy := range []string{OnExitKeep, OnExitRelease, OnExitHandoff} {
+		t.Run(policy, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.OnExitPolicy = policy
+			cfg.StateSnapshotFile = filepath.Join(t.TempDir(), "state.json")
+			rt := &resetRecordingRuntime{running: map[string]bool{"c1": true}}
+			svc := &KubeDiskGuardService{Config: cfg, runtime: rt}
+			svc.recordApplied(AppliedLimit{ContainerID: "c1", WriteIops: 300, Devices: []limits.VolumeLimit{{Device: "8:16"}}})
+			svc.recordApplied(AppliedLimit{ContainerID: "c2", ReadBps: 1024}) // 容器已退出
+
+			assert.NoError(t, svc.Shutdown(context.Background()))
+			assert.True(t, rt.closed)
+			_, err := os.Stat(cfg.StateSnapshotFile)
+			switch policy {
+			case OnExitKeep:
+				// 保留限速，不解除也不写快照
+				assert.Empty(t, rt.reset)
+				assert.Len(t, svc.appliedLimits(), 2)
+				assert.True(t, os.IsNotExist(err))
+			case OnExitRelease:
+				// 只解除仍在运行的容器（含PVC设备），已退出的容器跳过
+				assert.Equal(t, []string{"c1"}, rt.reset)
+				assert.Equal(t, []string{"c1@8:16"}, rt.resetDevices)
+				applied := svc.appliedLimits()
+				assert.Len(t, applied, 1)
+				assert.Equal(t, "c2", applied[0].ContainerID)
+				assert.True(t, os.IsNotExist(err))
+			case OnExitHandoff:
+				// 保留限速并写入快照，供下一个实例接管
+				assert.Empty(t, rt.reset)
+				assert.NoError(t, err)
+				next := &KubeDiskGuardService{Config: cfg}
+				assert.NoError(t, next.loadStateSnapshot())
+				assert.Len(t, next.appliedLimits(), 2)
+			}
+		})
+	}
+}
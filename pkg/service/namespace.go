@@ -0,0 +1,82 @@
+package service
+
+import (
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// namespaceSyncTimeout 启动时等待命名空间缓存同步的最长时间，超时后不阻塞Pod同步
+const namespaceSyncTimeout = 30 * time.Second
+
+// namespaceWatcher 监听Namespace对象，提供命名空间级默认限速注解
+type namespaceWatcher struct {
+	informer cache.SharedIndexInformer
+}
+
+// newNamespaceWatcher 创建命名空间监听器，命名空间注解或标签（过滤规则的命名空间选择器）变化时回调 onChange
+func newNamespaceWatcher(lw cache.ListerWatcher, resyncPeriod time.Duration, onChange func(namespace string)) *namespaceWatcher {
+	w := &namespaceWatcher{
+		informer: cache.NewSharedIndexInformer(lw, &corev1.Namespace{}, resyncPeriod, cache.Indexers{}),
+	}
+	w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok && len(ns.Annotations) > 0 {
+				onChange(ns.Name)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNs, ok1 := oldObj.(*corev1.Namespace)
+			newNs, ok2 := newObj.(*corev1.Namespace)
+			if ok1 && ok2 && (!reflect.DeepEqual(oldNs.Annotations, newNs.Annotations) || !reflect.DeepEqual(oldNs.Labels, newNs.Labels)) {
+				onChange(newNs.Name)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				onChange(key)
+			}
+		},
+	})
+	return w
+}
+
+// Run 启动informer，阻塞直到 stopCh 关闭
+func (w *namespaceWatcher) Run(stopCh <-chan struct{}) {
+	w.informer.Run(stopCh)
+}
+
+// HasSynced 命名空间缓存是否已完成首次同步
+func (w *namespaceWatcher) HasSynced() bool {
+	return w.informer.HasSynced()
+}
+
+// Annotations 返回命名空间注解，未找到或监听器未启用时返回nil
+func (w *namespaceWatcher) Annotations(namespace string) map[string]string {
+	if ns := w.get(namespace); ns != nil {
+		return ns.Annotations
+	}
+	return nil
+}
+
+// Labels 返回命名空间标签，未找到或监听器未启用时返回nil
+func (w *namespaceWatcher) Labels(namespace string) map[string]string {
+	if ns := w.get(namespace); ns != nil {
+		return ns.Labels
+	}
+	return nil
+}
+
+func (w *namespaceWatcher) get(namespace string) *corev1.Namespace {
+	if w == nil {
+		return nil
+	}
+	obj, exists, err := w.informer.GetStore().GetByKey(namespace)
+	if err != nil || !exists {
+		return nil
+	}
+	ns, _ := obj.(*corev1.Namespace)
+	return ns
+}
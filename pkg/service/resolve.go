@@ -0,0 +1,115 @@
+package service
+
+import (
+	"time"
+
+	"KubeDiskGuard/pkg/budget"
+	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/container"
+	"KubeDiskGuard/pkg/limits"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// globalLimits 返回环境变量配置的全局默认限速
+func globalLimits(cfg *config.Config) limits.Limits {
+	return limits.Limits{
+		ReadIOPS:  cfg.ContainerReadIOPSLimit,
+		WriteIOPS: cfg.ContainerWriteIOPSLimit,
+		ReadBPS:   cfg.ContainerReadBPSLimit,
+		WriteBPS:  cfg.ContainerWriteBPSLimit,
+	}
+}
+
+// resolvePodContainerLimits 按 全局 → 限速等级 → 命名空间 → Pod → 时间窗口 → 容器 的优先级解析容器限速，高优先级覆盖低优先级，
+// 再与节点IO预算分配的份额逐项取更严格的值；容器 resources.limits 中声明的IO扩展资源最后覆盖，调度器已按节点容量保证其总和
+func (s *KubeDiskGuardService) resolvePodContainerLimits(pod corev1.Pod, containerName string) limits.Resolution {
+	res := s.resolvePodContainerCap(pod, containerName)
+	if share, ok := s.budgetShare(pod, containerName); ok {
+		res.Push(limits.LayerBudget, "node", budget.Min(res.Effective, share))
+	}
+	if declared, ok := containerResourceLimits(pod, containerName, s.Config.SmartLimitAnnotationPrefix, res.Effective); ok {
+		res.Push(limits.LayerResource, containerName, declared)
+	}
+	return res
+}
+
+// resolvePodContainerCap 解析容器自身配置的限速（不含节点预算），同时作为预算分配时该容器的上限
+func (s *KubeDiskGuardService) resolvePodContainerCap(pod corev1.Pod, containerName string) limits.Resolution {
+	prefix := s.Config.SmartLimitAnnotationPrefix
+	res := limits.ResolvePod(globalLimits(s.Config), s.limitClasses, s.limitProfiles, s.namespaces.Load().Annotations(pod.Namespace), pod, prefix)
+	res.ContainerName = containerName
+	current := res.Effective
+
+	// 时间表中当前生效的窗口覆盖Pod级静态配置，容器级注解仍优先
+	if window := s.podSchedule(pod).Active(time.Now()); window != nil {
+		current = limits.ParseLayer(window.Annotations(prefix), current, prefix, s.limitProfiles)
+		res.Push(limits.LayerSchedule, window.Name, current)
+	}
+
+	readIops, writeIops, readBps, writeBps := ParseContainerLimitsFromAnnotations(pod.Annotations, containerName, current.ReadIOPS, current.WriteIOPS, current.ReadBPS, current.WriteBPS, prefix, s.limitProfiles)
+	res.Push(limits.LayerContainer, containerName, limits.Limits{ReadIOPS: readIops, WriteIOPS: writeIops, ReadBPS: readBps, WriteBPS: writeBps})
+	return res
+}
+
+// resolveStandaloneLimits 独立模式下按 全局 → 静态规则 → 容器标签 的优先级解析容器限速
+func (s *KubeDiskGuardService) resolveStandaloneLimits(containerInfo *container.ContainerInfo) limits.Resolution {
+	prefix := s.Config.SmartLimitAnnotationPrefix
+	res := limits.Resolution{ContainerID: containerInfo.ID, ContainerName: containerInfo.Name}
+
+	current := globalLimits(s.Config)
+	res.Push(limits.LayerGlobal, "", current)
+
+	if rule := s.standaloneRules.Match(containerInfo.Name, containerInfo.Image); rule != nil {
+		current = limits.ParseLayer(rule.Annotations(prefix), current, prefix, s.limitProfiles)
+		res.Push(limits.LayerRule, rule.Name, current)
+	}
+
+	current = limits.ParseLayer(containerInfo.Labels, current, prefix, s.limitProfiles)
+	res.Push(limits.LayerLabel, containerInfo.Name, current)
+	return res
+}
+
+// EffectiveLimits 返回本节点各容器的限速解析结果（含优先级链），namespace/podName 为空时不过滤
+func (s *KubeDiskGuardService) EffectiveLimits(namespace, podName string) []limits.Resolution {
+	var result []limits.Resolution
+	if s.Config.StandaloneMode {
+		containers, err := s.runtime.ListContainers()
+		if err != nil {
+			return nil
+		}
+		for _, c := range containers {
+			if !s.filterStandaloneContainer(c).Included() {
+				continue
+			}
+			result = append(result, s.resolveStandaloneLimits(c))
+		}
+		return result
+	}
+
+	controller := s.pods.Load()
+	if controller == nil {
+		return nil
+	}
+	prefix := s.Config.SmartLimitAnnotationPrefix
+	for _, obj := range controller.informer.GetStore().List() {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || !s.ShouldProcessPod(*pod) {
+			continue
+		}
+		if (namespace != "" && pod.Namespace != namespace) || (podName != "" && pod.Name != podName) {
+			continue
+		}
+		volumes := s.podVolumeLimits(*pod)
+		for _, cs := range s.podContainerStatuses(*pod) {
+			if IsContainerExcludedByAnnotation(pod.Annotations, cs.Name, prefix) || !s.filterPodContainer(*pod, cs.Name, cs.Image).Included() {
+				continue
+			}
+			res := s.resolvePodContainerLimits(*pod, cs.Name)
+			res.ContainerID = parseRuntimeID(cs.ContainerID)
+			res.Volumes = containerVolumeLimits(*pod, cs.Name, volumes)
+			result = append(result, res)
+		}
+	}
+	return result
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"KubeDiskGuard/pkg/budget"
+	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/device"
+	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResolvePodContainerLimitsPrecedence(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.ContainerReadIOPSLimit = 500
+	cfg.ContainerWriteIOPSLimit = 500
+	cfg.ContainerReadBPSLimit = 0
+	cfg.ContainerWriteBPSLimit = 0
+	prefix := cfg.SmartLimitAnnotationPrefix
+	svc := &KubeDiskGuardService{Config: cfg}
+
+	namespaces := newNamespaceWatcher(&cache.ListWatch{}, 0, func(string) {})
+	assert.NoError(t, namespaces.informer.GetStore().Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "batch",
+		Annotations: map[string]string{prefix + "/write-iops": "200", prefix + "/read-bps": "10M"},
+	}}))
+	svc.namespaces.Store(namespaces)
+
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "job",
+		Namespace: "batch",
+		Annotations: map[string]string{
+			prefix + "/read-iops":                   "300",
+			prefix + "/container.loader.write-iops": "50",
+		},
+	}}
+
+	res := svc.resolvePodContainerLimits(pod, "loader")
+	sources := []string{}
+	for _, layer := range res.Chain {
+		sources = append(sources, layer.Source)
+	}
+	assert.Equal(t, []string{limits.LayerGlobal, limits.LayerNamespace, limits.LayerPod, limits.LayerContainer}, sources)
+	assert.Equal(t, limits.Limits{ReadIOPS: 500, WriteIOPS: 200, ReadBPS: 10 * 1024 * 1024}, res.Chain[1].Limits)
+	assert.Equal(t, limits.Limits{ReadIOPS: 300, WriteIOPS: 50, ReadBPS: 10 * 1024 * 1024}, res.Effective)
+
+	// 其他命名空间不受影响，回退到全局默认值
+	pod.Namespace = "default"
+	res = svc.resolvePodContainerLimits(pod, "app")
+	assert.Equal(t, limits.Limits{ReadIOPS: 300, WriteIOPS: 500}, res.Effective)
+}
+
+func TestResolvePodContainerLimitsWithClass(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	prefix := cfg.SmartLimitAnnotationPrefix
+	svc := &KubeDiskGuardService{Config: cfg, limitClasses: &limits.ClassSet{Classes: []limits.Class{
+		{Name: "best-effort", QOSClasses: []string{"BestEffort"}, Limits: map[string]string{"iops": "100"}},
+	}}}
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{prefix + "/write-iops": "50"}},
+		Status:     corev1.PodStatus{QOSClass: corev1.PodQOSBestEffort},
+	}
+	res := svc.resolvePodContainerLimits(pod, "app")
+	assert.Equal(t, limits.LayerClass, res.Chain[1].Source)
+	assert.Equal(t, "best-effort", res.Chain[1].Name)
+	// 注解在限速等级之后生效，可覆盖等级中的值
+	assert.Equal(t, 100, res.Effective.ReadIOPS)
+	assert.Equal(t, 50, res.Effective.WriteIOPS)
+
+	pod.Status.QOSClass = corev1.PodQOSGuaranteed
+	res = svc.resolvePodContainerLimits(pod, "app")
+	assert.Equal(t, limits.LayerNamespace, res.Chain[1].Source)
+}
+
+func TestParseLimitsWithProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(file, []byte(`{"profiles": [{"name": "batch", "read_iops": 200, "write_iops": 300, "write_bps": "50M"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := limits.LoadProfiles(file)
+	assert.NoError(t, err)
+
+	prefix := "kubediskguard.io"
+	annotations := map[string]string{prefix + "/profile": "batch", prefix + "/write-iops": "100"}
+	// 显式注解覆盖配置中的值
+	l := limits.ParseLayer(annotations, limits.Limits{ReadIOPS: 500, WriteIOPS: 500}, prefix, profiles)
+	assert.Equal(t, limits.Limits{ReadIOPS: 200, WriteIOPS: 100, WriteBPS: 50 * 1024 * 1024}, l)
+
+	// 容器级注解也可引用配置
+	readIops, writeIops, _, _ := ParseContainerLimitsFromAnnotations(map[string]string{prefix + "/container.app.profile": "batch"}, "app", 500, 500, 0, 0, prefix, profiles)
+	assert.Equal(t, 200, readIops)
+	assert.Equal(t, 300, writeIops)
+
+	// 未知配置或未加载配置时被忽略，回退到默认值
+	l = limits.ParseLayer(map[string]string{prefix + "/profile": "unknown"}, limits.Limits{ReadIOPS: 500, WriteIOPS: 500}, prefix, profiles)
+	assert.Equal(t, limits.Limits{ReadIOPS: 500, WriteIOPS: 500}, l)
+	l = limits.ParseLayer(annotations, limits.Limits{ReadIOPS: 500, WriteIOPS: 500}, prefix, nil)
+	assert.Equal(t, limits.Limits{ReadIOPS: 500, WriteIOPS: 100}, l)
+}
+
+func TestScheduleWindowSwitch(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	prefix := cfg.SmartLimitAnnotationPrefix
+	svc := &KubeDiskGuardService{Config: cfg}
+
+	// 全天窗口，保证测试结果与当前时间无关
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "batch", Annotations: map[string]string{
+			prefix + "/write-iops": "500",
+			prefix + "/schedule":   `{"windows": [{"name": "all-day", "start": "00:00", "end": "00:00", "limits": {"write-iops": "100"}}]}`,
+		}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", ContainerID: "containerd://c1"}}},
+	}
+	res := svc.resolvePodContainerLimits(pod, "app")
+	assert.Equal(t, limits.LayerSchedule, res.Chain[len(res.Chain)-2].Source)
+	assert.Equal(t, "all-day", res.Chain[len(res.Chain)-2].Name)
+	assert.Equal(t, 100, res.Effective.WriteIOPS)
+	// 同一注解值复用编译后的时间表
+	assert.Same(t, svc.podSchedule(pod), svc.podSchedule(pod))
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(&pod))
+	var resynced []string
+	resync := func(key string) { resynced = append(resynced, key) }
+
+	// 下发时不在窗口内，检查时已进入窗口，触发重新同步且只触发一次
+	svc.recordSchedule(pod, svc.podSchedule(pod), "")
+	svc.checkScheduleWindows(indexer, resync, time.Now())
+	svc.checkScheduleWindows(indexer, resync, time.Now())
+	assert.Equal(t, []string{"batch/job"}, resynced)
+
+	statuses := svc.ScheduleStatuses()
+	assert.Empty(t, statuses) // 控制器未启动
+
+	// Pod删除后清除记录
+	assert.NoError(t, indexer.Delete(&pod))
+	svc.checkScheduleWindows(indexer, resync, time.Now())
+	assert.Empty(t, svc.scheduledPods())
+}
+
+func TestRebalanceNodeBudget(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.ContainerReadIOPSLimit = 0
+	cfg.ContainerWriteIOPSLimit = 0
+	prefix := cfg.SmartLimitAnnotationPrefix
+	svc := &KubeDiskGuardService{Config: cfg, budgetTotal: limits.Limits{WriteIOPS: 900}}
+
+	started := true
+	newPod := func(name string, annotations map[string]string, containers ...string) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations}}
+		pod.Status.Phase = corev1.PodRunning
+		for _, c := range containers {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				Name: c, Image: "app:v1", ContainerID: "containerd://" + name + "-" + c, Started: &started,
+			})
+		}
+		return pod
+	}
+	busy := newPod("busy", map[string]string{prefix + "/budget-weight": "2"}, "app", "sidecar")
+	idle := newPod("idle", nil, "app")
+	capped := newPod("capped", map[string]string{prefix + "/write-iops": "50"}, "app")
+	demand := map[string]float64{"busy-app": 2000, "busy-sidecar": 10, "idle-app": 0, "capped-app": 500}
+	usage := func(id string) budget.Usage { return budget.Usage{WriteIOPS: demand[id]} }
+
+	changed := svc.rebalanceBudget([]*corev1.Pod{busy, idle, capped}, usage, time.Now())
+	assert.ElementsMatch(t, []string{"default/busy", "default/idle", "default/capped"}, changed)
+
+	// capped 以自身上限50为准，idle 没有需求只保留保底份额，其余分给 busy
+	status := svc.NodeBudget()
+	assert.Len(t, status.Pods, 3)
+	assert.Equal(t, "busy", status.Pods[0].PodName)
+	assert.Equal(t, 827, status.Pods[0].Allocation.WriteIOPS)
+	assert.Equal(t, 50, status.Pods[1].Allocation.WriteIOPS)
+	assert.Equal(t, 22, status.Pods[2].Allocation.WriteIOPS)
+
+	// Pod内再按容器需求分配，预算份额与容器自身限速取更严格的值
+	res := svc.resolvePodContainerLimits(*busy, "sidecar")
+	assert.Equal(t, limits.LayerBudget, res.Chain[len(res.Chain)-1].Source)
+	assert.Equal(t, 41, res.Effective.WriteIOPS)
+	assert.Equal(t, 50, svc.resolvePodContainerLimits(*capped, "app").Effective.WriteIOPS)
+
+	// 需求不变时份额不变，不触发重新下发；Pod退出后其余Pod重新分配
+	assert.Empty(t, svc.rebalanceBudget([]*corev1.Pod{busy, idle, capped}, usage, time.Now()))
+	changed = svc.rebalanceBudget([]*corev1.Pod{busy, idle}, usage, time.Now())
+	assert.ElementsMatch(t, []string{"default/busy", "default/idle"}, changed)
+}
+
+func TestContainerResourceLimits(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.ContainerReadIOPSLimit = 0
+	cfg.ContainerWriteIOPSLimit = 0
+	prefix := cfg.SmartLimitAnnotationPrefix
+	svc := &KubeDiskGuardService{Config: cfg, budgetTotal: limits.Limits{WriteIOPS: 900}}
+
+	started := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", Annotations: map[string]string{
+			prefix + "/read-iops": "800", prefix + "/write-iops": "1000",
+		}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "mysql", Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+				corev1.ResourceName(prefix + "/write-iops"): resource.MustParse("300"),
+				corev1.ResourceName(prefix + "/write-bps"):  resource.MustParse("50Mi"),
+			}}},
+			{Name: "exporter"},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "mysql", ContainerID: "containerd://mysql", Started: &started},
+			{Name: "exporter", ContainerID: "containerd://exporter", Started: &started},
+		}},
+	}
+
+	// 声明的IO资源从预算中扣除，并作为最终值覆盖预算份额，未声明的项保持注解解析结果
+	demand := map[string]float64{"mysql": 280, "exporter": 5}
+	svc.rebalanceBudget([]*corev1.Pod{pod}, func(id string) budget.Usage { return budget.Usage{WriteIOPS: demand[id]} }, time.Now())
+	status := svc.NodeBudget()
+	assert.Equal(t, limits.Limits{WriteIOPS: 300, WriteBPS: 50 * 1024 * 1024}, status.Reserved)
+	// 声明资源的容器不参与分配，Pod的需求和上限只包含其余容器
+	assert.Len(t, status.Pods, 1)
+	assert.Equal(t, 5.0, status.Pods[0].Demand.WriteIOPS)
+	assert.Equal(t, 1000, status.Pods[0].Cap.WriteIOPS)
+	res := svc.resolvePodContainerLimits(*pod, "mysql")
+	assert.Equal(t, limits.LayerResource, res.Chain[len(res.Chain)-1].Source)
+	for _, layer := range res.Chain {
+		assert.NotEqual(t, limits.LayerBudget, layer.Source)
+	}
+	assert.Equal(t, limits.Limits{ReadIOPS: 800, WriteIOPS: 300, WriteBPS: 50 * 1024 * 1024}, res.Effective)
+	exporter := svc.resolvePodContainerLimits(*pod, "exporter")
+	assert.NotEqual(t, limits.LayerResource, exporter.Chain[len(exporter.Chain)-1].Source)
+
+	capacity := nodeCapacity(limits.Limits{WriteIOPS: 900, ReadBPS: 500 * 1024 * 1024}, prefix)
+	assert.Len(t, capacity, 2)
+	assert.Equal(t, int64(900), capacity.Name(corev1.ResourceName(prefix+"/write-iops"), resource.DecimalSI).Value())
+	assert.Equal(t, int64(500*1024*1024), capacity.Name(corev1.ResourceName(prefix+"/read-bps"), resource.DecimalSI).Value())
+}
+
+type claimStorageClient struct {
+	kubeclient.IKubeClient
+	claims map[string]*kubeclient.ClaimStorage
+}
+
+func (c *claimStorageClient) GetClaimStorage(namespace, claimName string) (*kubeclient.ClaimStorage, error) {
+	if storage, ok := c.claims[namespace+"/"+claimName]; ok {
+		return storage, nil
+	}
+	return nil, fmt.Errorf("persistent volume claim %s/%s is not bound", namespace, claimName)
+}
+
+func TestPodVolumeLimits(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.KubeletRootDir = t.TempDir()
+	prefix := cfg.SmartLimitAnnotationPrefix
+	params, err := limits.ParseParameterMap(cfg.StorageClassParameterMap)
+	assert.NoError(t, err)
+	svc := &KubeDiskGuardService{Config: cfg, storageParams: params, kubeClient: &claimStorageClient{claims: map[string]*kubeclient.ClaimStorage{
+		"db/data-mysql-0": {
+			VolumeName: "pv-data", StorageClassName: "gp3", StorageClassParameters: map[string]string{"iops": "3000", "throughput": "125"},
+			VolumeAttributesClassName: "fast", VolumeAttributesClassParameters: map[string]string{"iops": "6000"},
+		},
+		"db/logs-mysql-0": {VolumeName: "pv-logs", StorageClassName: "gp3", StorageClassParameters: map[string]string{"throughput": "250"}},
+		"db/local":        {VolumeName: "pv-local", StorageClassName: "local-path", StorageClassParameters: map[string]string{"iops": "100"}},
+		"db/standard":     {VolumeName: "pv-standard", StorageClassName: "standard"},
+	}}}
+
+	devices := map[string]string{"pv-data": "259:1", "pv-logs": "259:2", "pv-local": "8:0"}
+	pathMajMin = func(path string) (string, error) {
+		for pv, dev := range devices {
+			if strings.Contains(path, pv) {
+				return dev, nil
+			}
+		}
+		return "", fmt.Errorf("unexpected path %s", path)
+	}
+	dataDiskMajMin = func(string) (string, error) { return "8:0", nil }
+	defer func() { pathMajMin, dataDiskMajMin = device.GetPathMajMin, device.GetMajMin }()
+	podDir := filepath.Join(cfg.KubeletRootDir, "pods", "uid-1")
+	for _, dir := range []string{"volumes/kubernetes.io~csi/pv-data/mount", "volumes/kubernetes.io~csi/pv-logs/mount", "volumes/kubernetes.io~local-volume/pv-local"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(podDir, dir), 0755))
+	}
+
+	claim := func(name string) corev1.VolumeSource {
+		return corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name}}
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql-0", Namespace: "db", UID: "uid-1", Annotations: map[string]string{
+			prefix + "/volume.logs.write-bps": "100M",
+		}},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "data", VolumeSource: claim("data-mysql-0")},
+				{Name: "logs", VolumeSource: claim("logs-mysql-0")},
+				{Name: "local", VolumeSource: claim("local")},
+				{Name: "standard", VolumeSource: claim("standard")},
+				{Name: "pending", VolumeSource: claim("pending")},
+				{Name: "config", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+			Containers: []corev1.Container{
+				{Name: "mysql", VolumeMounts: []corev1.VolumeMount{{Name: "data"}, {Name: "logs"}, {Name: "local"}}},
+				{Name: "exporter", VolumeMounts: []corev1.VolumeMount{{Name: "config"}}},
+			},
+		},
+	}
+
+	// 卷属性类覆盖存储类参数，卷级注解再覆盖；数据盘上的卷和没有限速参数的卷跳过
+	volumes := svc.podVolumeLimits(pod)
+	assert.Equal(t, []limits.VolumeLimit{
+		{Volume: "data", Claim: "data-mysql-0", StorageClass: "gp3", VolumeAttributesClass: "fast", Device: "259:1",
+			Limits: limits.Limits{ReadIOPS: 6000, WriteIOPS: 6000, ReadBPS: 125 * 1024 * 1024, WriteBPS: 125 * 1024 * 1024}},
+		{Volume: "logs", Claim: "logs-mysql-0", StorageClass: "gp3", Device: "259:2",
+			Limits: limits.Limits{ReadBPS: 250 * 1024 * 1024, WriteBPS: 100 * 1024 * 1024}},
+	}, volumes)
+	assert.Len(t, containerVolumeLimits(pod, "mysql", volumes), 2)
+	assert.Empty(t, containerVolumeLimits(pod, "exporter", volumes))
+
+	// 同一设备上的多个卷逐项取更严格的值
+	devices["pv-logs"] = "259:1"
+	svc.claims = nil
+	merged := containerVolumeLimits(pod, "mysql", svc.podVolumeLimits(pod))
+	assert.Len(t, merged, 1)
+	assert.Equal(t, limits.Limits{ReadIOPS: 6000, WriteIOPS: 6000, ReadBPS: 125 * 1024 * 1024, WriteBPS: 100 * 1024 * 1024}, merged[0].Limits)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"log"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/limits"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// resourceLimitKeys 可在容器 resources.limits 中声明的IO扩展资源，资源名为 <prefix>/<key>，如 kubediskguard.io/write-iops
+var resourceLimitKeys = []string{
+	annotationkeys.ReadIopsAnnotationKey, annotationkeys.WriteIopsAnnotationKey,
+	annotationkeys.ReadBpsAnnotationKey, annotationkeys.WriteBpsAnnotationKey,
+}
+
+// limitField 返回限速key对应的字段
+func limitField(l *limits.Limits, key string) *int {
+	switch key {
+	case annotationkeys.ReadIopsAnnotationKey:
+		return &l.ReadIOPS
+	case annotationkeys.WriteIopsAnnotationKey:
+		return &l.WriteIOPS
+	case annotationkeys.ReadBpsAnnotationKey:
+		return &l.ReadBPS
+	case annotationkeys.WriteBpsAnnotationKey:
+		return &l.WriteBPS
+	}
+	return nil
+}
+
+// containerResourceLimits 读取容器 resources.limits 中声明的IO扩展资源，声明了的项覆盖 base，
+// 容器未声明任何IO资源时返回false
+func containerResourceLimits(pod corev1.Pod, containerName, prefix string, base limits.Limits) (limits.Limits, bool) {
+	var resources corev1.ResourceList
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			if c.Name == containerName {
+				resources = c.Resources.Limits
+			}
+		}
+	}
+	result, found := base, false
+	for _, key := range resourceLimitKeys {
+		q, ok := resources[corev1.ResourceName(prefix+"/"+key)]
+		if !ok {
+			continue
+		}
+		*limitField(&result, key) = int(q.Value())
+		found = true
+	}
+	return result, found
+}
+
+// nodeCapacity 将节点IO预算转换为扩展资源容量，为0的项不发布
+func nodeCapacity(total limits.Limits, prefix string) corev1.ResourceList {
+	capacity := corev1.ResourceList{}
+	for _, key := range resourceLimitKeys {
+		if v := *limitField(&total, key); v > 0 {
+			capacity[corev1.ResourceName(prefix+"/"+key)] = *resource.NewQuantity(int64(v), resource.DecimalSI)
+		}
+	}
+	return capacity
+}
+
+// advertiseNodeCapacity 将节点IO预算作为扩展资源写入 Node status，kubelet 会同步到 allocatable，
+// 调度器据此保证节点上容器声明的IO资源之和不超过预算
+func (s *KubeDiskGuardService) advertiseNodeCapacity() {
+	capacity := nodeCapacity(s.budgetTotal, s.Config.SmartLimitAnnotationPrefix)
+	if len(capacity) == 0 {
+		log.Printf("Skip advertising node IO capacity: node budget is not configured")
+		return
+	}
+	if err := s.kubeClient.PatchNodeCapacity(capacity); err != nil {
+		log.Printf("Failed to advertise node IO capacity: %v", err)
+		return
+	}
+	for name, q := range capacity {
+		log.Printf("Advertised node IO capacity %s=%s", name, q.String())
+	}
+}
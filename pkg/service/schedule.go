@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/schedule"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// scheduledPod 按时间表限速的Pod最近一次下发时的状态
+type scheduledPod struct {
+	window     string            // 下发时生效的窗口，为空表示不在任何窗口内
+	containers map[string]string // containerID -> 容器名
+}
+
+// scheduleCacheSize 编译后时间表缓存的最大条目数，超过后清空重建
+const scheduleCacheSize = 1024
+
+// podSchedule 返回Pod的限速时间表：Pod自身的 schedule 注解优先，其次为引用的命名配置中的时间表
+func (s *KubeDiskGuardService) podSchedule(pod corev1.Pod) *schedule.Schedule {
+	prefix := s.Config.SmartLimitAnnotationPrefix
+	if value, ok := pod.Annotations[prefix+"/"+annotationkeys.ScheduleAnnotationKey]; ok {
+		return s.compiledSchedule(pod, value)
+	}
+	if name, ok := pod.Annotations[prefix+"/"+annotationkeys.ProfileAnnotationKey]; ok {
+		if profile := s.limitProfiles.Get(name); profile != nil {
+			return profile.Schedule
+		}
+	}
+	return nil
+}
+
+// compiledSchedule 按注解值缓存编译后的时间表，避免每次检查和解析限速时重复解析JSON；非法值只在首次解析时记录日志
+func (s *KubeDiskGuardService) compiledSchedule(pod corev1.Pod, value string) *schedule.Schedule {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	if sched, ok := s.schedules[value]; ok {
+		return sched
+	}
+	sched, err := schedule.Parse(value)
+	if err != nil {
+		log.Printf("Ignoring invalid schedule on pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+	if s.schedules == nil || len(s.schedules) >= scheduleCacheSize {
+		s.schedules = make(map[string]*schedule.Schedule)
+	}
+	s.schedules[value] = sched
+	return sched
+}
+
+// windowName 返回窗口名，nil 表示不在任何窗口内
+func windowName(w *schedule.Window) string {
+	if w == nil {
+		return ""
+	}
+	return w.Name
+}
+
+// recordSchedule 记录Pod下发限速时所处的窗口，Pod没有时间表时清除记录
+func (s *KubeDiskGuardService) recordSchedule(pod corev1.Pod, sched *schedule.Schedule, window string) {
+	key := pod.Namespace + "/" + pod.Name
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	if sched == nil {
+		delete(s.scheduled, key)
+		return
+	}
+	if s.scheduled == nil {
+		s.scheduled = make(map[string]scheduledPod)
+	}
+	containers := make(map[string]string)
+	for _, cs := range s.podContainerStatuses(pod) {
+		if id := parseRuntimeID(cs.ContainerID); id != "" {
+			containers[id] = cs.Name
+		}
+	}
+	s.scheduled[key] = scheduledPod{window: window, containers: containers}
+}
+
+// scheduledPods 返回所有按时间表限速的Pod记录副本
+func (s *KubeDiskGuardService) scheduledPods() map[string]scheduledPod {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	pods := make(map[string]scheduledPod, len(s.scheduled))
+	for key, rec := range s.scheduled {
+		pods[key] = rec
+	}
+	return pods
+}
+
+// runScheduler 定期检查按时间表限速的Pod，生效窗口变化时重新下发限速，ctx 取消后返回
+// 重启后的首次全量同步按当前时间选择窗口，无需额外恢复
+func (s *KubeDiskGuardService) runScheduler(ctx context.Context, controller *podController) {
+	interval := time.Duration(s.Config.ScheduleCheckInterval) * time.Second
+	if interval <= 0 {
+		log.Println("Schedule check disabled, time windows only apply on pod sync")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkScheduleWindows(controller.informer.GetIndexer(), controller.resyncPod, time.Now())
+		}
+	}
+}
+
+// checkScheduleWindows 对生效窗口与下发时不同的Pod触发重新同步
+func (s *KubeDiskGuardService) checkScheduleWindows(indexer cache.Indexer, resync func(key string), now time.Time) {
+	for key, rec := range s.scheduledPods() {
+		obj, exists, err := indexer.GetByKey(key)
+		pod, ok := obj.(*corev1.Pod)
+		if err != nil || !exists || !ok {
+			s.scheduleMu.Lock()
+			delete(s.scheduled, key)
+			s.scheduleMu.Unlock()
+			continue
+		}
+		window := windowName(s.podSchedule(*pod).Active(now))
+		if window == rec.window {
+			continue
+		}
+		log.Printf("Pod %s schedule window changed from %q to %q, re-applying limits", key, rec.window, window)
+		// 先更新记录，Pod不再需要处理（如已被排除）时避免每次检查都重复入队
+		s.scheduleMu.Lock()
+		if cur, exists := s.scheduled[key]; exists {
+			cur.window = window
+			s.scheduled[key] = cur
+		}
+		s.scheduleMu.Unlock()
+		resync(key)
+	}
+}
+
+// ScheduleStatuses 返回按时间表限速的容器当前所处窗口及下次切换时间
+func (s *KubeDiskGuardService) ScheduleStatuses() []schedule.Status {
+	controller := s.pods.Load()
+	if controller == nil {
+		return nil
+	}
+	now := time.Now()
+	var statuses []schedule.Status
+	for key, rec := range s.scheduledPods() {
+		obj, exists, err := controller.informer.GetIndexer().GetByKey(key)
+		pod, ok := obj.(*corev1.Pod)
+		if err != nil || !exists || !ok {
+			continue
+		}
+		sched := s.podSchedule(*pod)
+		if sched == nil {
+			continue
+		}
+		window := windowName(sched.Active(now))
+		var next *time.Time
+		if t := sched.NextSwitch(now); !t.IsZero() {
+			next = &t
+		}
+		for id, name := range rec.containers {
+			statuses = append(statuses, schedule.Status{
+				ContainerID: id, ContainerName: name, PodName: pod.Name, Namespace: pod.Namespace,
+				Window: window, Timezone: sched.Timezone, NextSwitchAt: next,
+			})
+		}
+	}
+	return statuses
+}
@@ -1,27 +1,35 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/audit"
+	"KubeDiskGuard/pkg/budget"
 	"KubeDiskGuard/pkg/cgroup"
 	"KubeDiskGuard/pkg/config"
 	"KubeDiskGuard/pkg/container"
 	"KubeDiskGuard/pkg/detector"
+	"KubeDiskGuard/pkg/filter"
 	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
 	"KubeDiskGuard/pkg/runtime"
+	"KubeDiskGuard/pkg/schedule"
 	"KubeDiskGuard/pkg/smartlimit"
+	"KubeDiskGuard/pkg/standalone"
 
-	"github.com/docker/go-units"
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/watch"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -57,12 +65,42 @@ type KubeDiskGuardService struct {
 	runtime    container.Runtime
 	kubeClient kubeclient.IKubeClient
 	smartLimit *smartlimit.SmartLimitManager
+
+	standaloneRules *standalone.RuleSet // 独立模式静态规则
+	filters         *filter.RuleSet     // 过滤规则，来自 FILTER_RULES_FILE 或由 EXCLUDE_* 配置转换，创建时构建一次
+	limitClasses    *limits.ClassSet    // 按QoS/PriorityClass映射的限速等级
+	limitProfiles   *limits.ProfileSet  // 命名限速配置，注解通过 <prefix>/profile 引用
+
+	appliedMu sync.Mutex
+	applied   map[string]AppliedLimit // containerID -> 本实例下发的限速，用于退出时解除或交接
+
+	audit *audit.Recorder // 限速动作审计（含dry-run）
+
+	scheduleMu sync.Mutex
+	scheduled  map[string]scheduledPod       // namespace/name -> 按时间表限速的Pod下发时所处的窗口
+	schedules  map[string]*schedule.Schedule // schedule 注解值 -> 编译后的时间表，非法值缓存为nil
+
+	budgetMu     sync.Mutex
+	budgetTotal  limits.Limits            // 节点IO预算，各项为0表示不做预算，创建后不再修改
+	budgetShares map[string]limits.Limits // namespace/pod/container -> 预算分配给该容器的份额
+	budgetStatus budget.Status            // 最近一次分配结果，供API展示
+
+	storageParams limits.ParameterMap // 存储类参数到限速key的映射，为nil时不按PVC存储类限速
+	claimsMu      sync.Mutex
+	claims        map[string]cachedClaim // namespace/claim -> PVC存储参数缓存
+
+	pods       atomic.Pointer[podController]    // Pod同步控制器，Run 启动后设置
+	namespaces atomic.Pointer[namespaceWatcher] // 命名空间默认限速，kubelet API 模式下为nil
 }
 
 // NewKubeDiskGuardService 创建KubeDiskGuardService
 func NewKubeDiskGuardService(cfg *config.Config) (*KubeDiskGuardService, error) {
 	service := &KubeDiskGuardService{
 		Config: cfg,
+		audit:  audit.NewRecorder(cfg.AuditBufferSize),
+	}
+	if cfg.DryRun {
+		log.Printf("Dry-run mode enabled: limit decisions are recorded to audit log only, cgroups and pods are not modified")
 	}
 
 	if cfg.ContainerRuntime == "auto" {
@@ -88,8 +126,62 @@ func NewKubeDiskGuardService(cfg *config.Config) (*KubeDiskGuardService, error)
 		return nil, err
 	}
 
+	if cfg.FilterRulesFile != "" {
+		if service.filters, err = filter.LoadRules(cfg.FilterRulesFile); err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded %d filter rules from %s, EXCLUDE_* settings are ignored", len(service.filters.Rules), cfg.FilterRulesFile)
+	} else {
+		service.filters = filter.LegacyRules(cfg.ExcludeKeywords, cfg.ExcludeNamespaces, cfg.ExcludeLabelSelector)
+	}
+
+	profiles, err := limits.LoadProfiles(cfg.LimitProfilesFile)
+	if err != nil {
+		return nil, err
+	}
+	service.limitProfiles = profiles
+	if len(profiles.Profiles) > 0 {
+		log.Printf("Loaded %d limit profiles from %s", len(profiles.Profiles), cfg.LimitProfilesFile)
+	}
+
+	// 独立模式不依赖kubelet/API Server，直接从运行时发现容器
+	if cfg.StandaloneMode {
+		service.standaloneRules, err = standalone.LoadRules(cfg.StandaloneRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Standalone mode enabled with %d static rules, skipping kubeclient creation", len(service.standaloneRules.Rules))
+		return service, nil
+	}
+
+	if service.budgetTotal, err = nodeBudget(cfg); err != nil {
+		return nil, err
+	}
+	if !service.budgetTotal.IsZero() {
+		log.Printf("Node IO budget enabled: riops=%d wiops=%d rbps=%d wbps=%d", service.budgetTotal.ReadIOPS, service.budgetTotal.WriteIOPS, service.budgetTotal.ReadBPS, service.budgetTotal.WriteBPS)
+	}
+
+	if cfg.StorageClassLimits {
+		if service.storageParams, err = limits.ParseParameterMap(cfg.StorageClassParameterMap); err != nil {
+			return nil, fmt.Errorf("invalid STORAGE_CLASS_PARAMETER_MAP: %v", err)
+		}
+		log.Printf("Storage class limits enabled with parameter map %q", cfg.StorageClassParameterMap)
+	}
+
+	service.limitClasses, err = limits.LoadClasses(cfg.LimitClassesFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(service.limitClasses.Classes) > 0 {
+		log.Printf("Loaded %d limit classes from %s", len(service.limitClasses.Classes), cfg.LimitClassesFile)
+	}
+
 	// 只有在智能限速启用时才创建 kubeclient
 	if cfg.SmartLimitEnabled {
+		triggers, err := limits.ParseTriggers(cfg.SmartLimitTriggers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMART_LIMIT_TRIGGERS: %v", err)
+		}
 		nodeName := os.Getenv("NODE_NAME")
 		// 当不使用 kubelet API 时，NODE_NAME 是必需的
 		if !cfg.SmartLimitUseKubeletAPI && nodeName == "" {
@@ -108,6 +200,10 @@ func NewKubeDiskGuardService(cfg *config.Config) (*KubeDiskGuardService, error)
 
 		cgroupMgr := cgroup.NewManager(cfg.CgroupVersion)
 		service.smartLimit = smartlimit.NewSmartLimitManager(cfg, service.kubeClient, cgroupMgr)
+		service.smartLimit.SetAuditRecorder(service.audit)
+		service.smartLimit.SetLimitClasses(service.limitClasses)
+		service.smartLimit.SetLimitProfiles(profiles)
+		service.smartLimit.SetTriggers(triggers)
 		log.Printf("Smart limit manager initialized")
 	} else {
 		log.Printf("Smart limit disabled, skipping kubeclient creation")
@@ -116,76 +212,105 @@ func NewKubeDiskGuardService(cfg *config.Config) (*KubeDiskGuardService, error)
 	return service, nil
 }
 
+// ShouldSkipContainer 仅按镜像和容器名判断容器是否被过滤规则排除
 func (s *KubeDiskGuardService) ShouldSkipContainer(image, name string) bool {
-	for _, keyword := range s.Config.ExcludeKeywords {
-		if strings.Contains(image, keyword) || strings.Contains(name, keyword) {
-			return true
-		}
-	}
-	return false
+	return !s.filters.EvaluateContainer(filter.Target{ContainerName: name, Image: image}).Included()
 }
 
-func (s *KubeDiskGuardService) processPodContainers(pod corev1.Pod) {
+// processPodContainers 对Pod内所有容器下发限速，返回聚合后的可重试错误（获取容器信息或下发失败）
+func (s *KubeDiskGuardService) processPodContainers(pod corev1.Pod) error {
+	var errs []error
 	prefix := s.Config.SmartLimitAnnotationPrefix
-	readIopsVal, writeIopsVal := ParseIopsLimitFromAnnotations(pod.Annotations, s.Config.ContainerReadIOPSLimit, s.Config.ContainerWriteIOPSLimit, prefix)
-	readBps, writeBps := ParseBpsLimitFromAnnotations(pod.Annotations, s.Config.ContainerReadBPSLimit, s.Config.ContainerWriteBPSLimit, prefix)
-
-	for _, cs := range pod.Status.ContainerStatuses {
+	// 在解析限速前记录时间窗口，边界时刻记录的窗口不会比实际下发的更新，调度器检查时最多多触发一次同步
+	sched := s.podSchedule(pod)
+	s.recordSchedule(pod, sched, windowName(sched.Active(time.Now())))
+	volumes := s.podVolumeLimits(pod)
+	for _, cs := range s.podContainerStatuses(pod) {
 		containerID := parseRuntimeID(cs.ContainerID)
 		if containerID == "" {
 			continue
 		}
 		containerTotal.Inc()
+		if IsContainerExcludedByAnnotation(pod.Annotations, cs.Name, prefix) {
+			log.Printf("Skip IOPS/BPS limit for container %s (pod: %s/%s, excluded by annotation)", cs.Name, pod.Namespace, pod.Name)
+			containerSkip.Inc()
+			continue
+		}
+		if res := s.filterPodContainer(pod, cs.Name, cs.Image); !res.Included() {
+			log.Printf("Skip IOPS/BPS limit for container %s (pod: %s/%s, excluded by filter rule %s)", cs.Name, pod.Namespace, pod.Name, res.Rule)
+			containerSkip.Inc()
+			continue
+		}
 		containerInfo, err := s.runtime.GetContainerByID(containerID)
 		if err != nil {
 			log.Printf("Failed to get container info for %s: %v", containerID, err)
 			containerFail.Inc()
+			errs = append(errs, fmt.Errorf("failed to get container %s: %v", containerID, err))
 			continue
 		}
 
-		if s.ShouldSkipContainer(containerInfo.Image, containerInfo.Name) {
-			log.Printf("Skip IOPS/BPS limit for container %s (excluded by keyword)", containerInfo.ID)
-			containerSkip.Inc()
-			continue
+		// 优先级：全局 → 限速等级 → 命名空间 → Pod → 时间窗口 → 容器 → 节点预算 → 容器IO资源声明，高优先级覆盖低优先级
+		effective := s.resolvePodContainerLimits(pod, cs.Name).Effective
+		if err := s.enforceContainerLimits(containerInfo, AppliedLimit{
+			ContainerName: cs.Name, PodName: pod.Name, Namespace: pod.Namespace,
+			ReadIops: effective.ReadIOPS, WriteIops: effective.WriteIOPS, ReadBps: effective.ReadBPS, WriteBps: effective.WriteBPS,
+			Devices: containerVolumeLimits(pod, cs.Name, volumes),
+		}); err != nil {
+			errs = append(errs, err)
 		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
 
-		if readIopsVal == 0 && writeIopsVal == 0 && readBps == 0 && writeBps == 0 {
-			if err := s.runtime.ResetLimits(containerInfo); err != nil {
-				log.Printf("Failed to reset all limits for container %s: %v", containerInfo.ID, err)
-				containerFail.Inc()
-			} else {
-				log.Printf("Successfully reset all limits for container %s", containerInfo.ID)
-				log.Printf("Reset all limits for container %s (pod: %s/%s)", containerInfo.ID, pod.Namespace, pod.Name)
-				containerReset.Inc()
+// podContainerStatuses 返回Pod中需要限速的容器状态
+// 普通容器始终处理；init容器与临时容器按配置开关处理，且只处理运行中的容器
+func (s *KubeDiskGuardService) podContainerStatuses(pod corev1.Pod) []corev1.ContainerStatus {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses)+len(pod.Status.EphemeralContainerStatuses))
+	if s.Config.ProcessInitContainers {
+		for _, cs := range pod.Status.InitContainerStatuses {
+			if cs.State.Running != nil {
+				statuses = append(statuses, cs)
+			}
+		}
+	}
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	if s.Config.ProcessEphemeralContainers {
+		for _, cs := range pod.Status.EphemeralContainerStatuses {
+			if cs.State.Running != nil {
+				statuses = append(statuses, cs)
 			}
-			continue
 		}
+	}
+	return statuses
+}
 
-		if err := s.runtime.SetLimits(containerInfo, readIopsVal, writeIopsVal, readBps, writeBps); err != nil {
-			log.Printf("Failed to set limits for container %s: %v", containerInfo.ID, err)
-			containerFail.Inc()
-		} else {
-			log.Printf("Successfully set limits for container %s: riops=%d, wiops=%d, rbps=%d, wbps=%d", containerInfo.ID, readIopsVal, writeIopsVal, readBps, writeBps)
-			log.Printf("Applied limits for container %s (pod: %s/%s): riops=%d wiops=%d rbps=%d wbps=%d", containerInfo.ID, pod.Namespace, pod.Name, readIopsVal, writeIopsVal, readBps, writeBps)
-			containerSuccess.Inc()
+// hasRunningInitContainer 判断Pod是否有正在运行的init容器
+func hasRunningInitContainer(pod corev1.Pod) bool {
+	for _, cs := range pod.Status.InitContainerStatuses {
+		if cs.State.Running != nil {
+			return true
 		}
 	}
+	return false
 }
 
 func (s *KubeDiskGuardService) ShouldProcessPod(pod corev1.Pod) bool {
-	if pod.Status.Phase != corev1.PodRunning {
-		return false
-	}
-	for _, ns := range s.Config.ExcludeNamespaces {
-		if pod.Namespace == ns {
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+	case corev1.PodPending:
+		// Pending 阶段只有init容器在运行（如数据预加载），开启init容器限速时需要处理
+		if !s.Config.ProcessInitContainers || !hasRunningInitContainer(pod) {
 			return false
 		}
+	default:
+		return false
 	}
-	if s.Config.ExcludeLabelSelector != "" {
-		selector, err := labels.Parse(s.Config.ExcludeLabelSelector)
-		if err == nil && selector.Matches(labels.Set(pod.Labels)) {
-			return false
-		}
+	// 过滤规则在Pod级即可判定排除时直接跳过；带容器级条件的规则在 processPodContainers 中逐个容器判断
+	if res, decided := s.filters.EvaluatePod(s.podFilterTarget(pod)); decided && !res.Included() {
+		return false
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return true
 	}
 	for _, cs := range pod.Status.ContainerStatuses {
 		if cs.Started == nil || !*cs.Started {
@@ -210,12 +335,13 @@ func (s *KubeDiskGuardService) ProcessExistingContainers() error {
 	}
 
 	for _, pod := range pods {
-		fmt.Printf(pod.Name)
 		if !s.ShouldProcessPod(pod) {
 			continue
 		}
 		// 解析注解
-		s.processPodContainers(pod)
+		if err := s.processPodContainers(pod); err != nil {
+			log.Printf("Failed to process pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
 	}
 	return nil
 }
@@ -234,131 +360,9 @@ func parseRuntimeID(k8sID string) string {
 	return k8sID
 }
 
-// WatchEvents 监听事件
-func (s *KubeDiskGuardService) WatchEvents() error {
-	return s.WatchPodEvents()
-}
-
-// WatchPodEvents 监听本节点Pod变化，动态调整IOPS
-func (s *KubeDiskGuardService) WatchPodEvents() error {
-	// 如果 kubeClient 为 nil（智能限速禁用），则跳过监听
-	if s.kubeClient == nil {
-		log.Println("KubeClient is nil, skipping pod events watching (smart limit disabled)")
-		return nil
-	}
-
-	watcher, err := s.kubeClient.WatchNodePods()
-	if err != nil {
-		log.Printf("[DEBUG] WatchNodePods failed: %v", err)
-		return err
-	}
-	// 修正：通过环境变量获取节点名
-	nodeName := os.Getenv("NODE_NAME")
-	log.Printf("Start watching pods on node: %s", nodeName)
-	log.Printf("[DEBUG] Watcher created, entering event loop...")
-	podAnnotations := make(map[string]PodAnnotationState)
-	ch := watcher.ResultChan()
-	if ch == nil {
-		log.Printf("[DEBUG] watcher.ResultChan() is nil! Watcher: %#v", watcher)
-		return fmt.Errorf("watcher.ResultChan() is nil")
-	}
-	for event := range ch {
-		log.Printf("[DEBUG] Received event: %v", event.Type)
-		if event.Object == nil {
-			log.Printf("[DEBUG] Event object is nil")
-			continue
-		}
-		pod, ok := event.Object.(*corev1.Pod)
-		if !ok {
-			log.Printf("[DEBUG] Event object is not *corev1.Pod, got: %T", event.Object)
-			continue
-		}
-
-		key := pod.Namespace + "/" + pod.Name
-		switch event.Type {
-		case watch.Modified:
-			log.Printf("[DEBUG] Modified event for pod: %s", key)
-			if !s.ShouldProcessPod(*pod) {
-				log.Printf("[DEBUG] Pod %s should not be processed", key)
-				continue
-			}
-
-			old, exists := podAnnotations[key]
-			newAnn := pod.Annotations
-			readIops, writeIops := ParseIopsLimitFromAnnotations(newAnn, s.Config.ContainerReadIOPSLimit, s.Config.ContainerWriteIOPSLimit, s.Config.SmartLimitAnnotationPrefix)
-			readBps, writeBps := ParseBpsLimitFromAnnotations(newAnn, s.Config.ContainerReadBPSLimit, s.Config.ContainerWriteBPSLimit, s.Config.SmartLimitAnnotationPrefix)
-
-			if exists && reflect.DeepEqual(old.Annotations, newAnn) &&
-				old.ReadIops == readIops && old.WriteIops == writeIops &&
-				old.ReadBps == readBps && old.WriteBps == writeBps {
-				continue
-			}
-
-			s.processPodContainers(*pod)
-			podAnnotations[key] = PodAnnotationState{
-				Annotations: newAnn,
-				ReadIops:    readIops,
-				WriteIops:   writeIops,
-				ReadBps:     readBps,
-				WriteBps:    writeBps,
-			}
-		case watch.Deleted:
-			log.Printf("[DEBUG] Deleted event for pod: %s", key)
-			delete(podAnnotations, key)
-		}
-	}
-	log.Printf("[DEBUG] Event loop exited (watcher channel closed)")
-	return nil
-}
-
-// ParseIopsLimitFromAnnotations 解析注解中的iops限制（分别支持读写）
+// ParseIopsLimitFromAnnotations 解析注解中的iops限制（分别支持读写），<prefix>/profile 引用的命名配置由调用方先展开
 func ParseIopsLimitFromAnnotations(annotations map[string]string, defaultReadIops, defaultWriteIops int, prefix string) (int, int) {
-	readIops, writeIops := defaultReadIops, defaultWriteIops
-	annotationPrefix := prefix + "/"
-
-	if val, ok := annotations[annotationPrefix+annotationkeys.RemovedAnnotationKey]; ok && val == "true" {
-		return 0, 0
-	}
-
-	useSmart := hasSmartLimitAnnotation(annotations, prefix)
-
-	if useSmart {
-		if iops, ok := annotations[annotationPrefix+annotationkeys.IopsAnnotationKey]; ok {
-			if value, err := strconv.Atoi(iops); err == nil {
-				return value, value
-			}
-		}
-		if riops, ok := annotations[annotationPrefix+annotationkeys.ReadIopsAnnotationKey]; ok {
-			if value, err := strconv.Atoi(riops); err == nil {
-				readIops = value
-			}
-		}
-		if wiops, ok := annotations[annotationPrefix+annotationkeys.WriteIopsAnnotationKey]; ok {
-			if value, err := strconv.Atoi(wiops); err == nil {
-				writeIops = value
-			}
-		}
-		return readIops, writeIops
-	}
-
-	// Fallback to legacy only if no smart limit annotations are present
-	if iops, ok := annotations[annotationkeys.LegacyIopsAnnotationKey]; ok {
-		if value, err := strconv.Atoi(iops); err == nil {
-			return value, value
-		}
-	}
-	if riops, ok := annotations[annotationkeys.LegacyReadIopsAnnotationKey]; ok {
-		if value, err := strconv.Atoi(riops); err == nil {
-			readIops = value
-		}
-	}
-	if wiops, ok := annotations[annotationkeys.LegacyWriteIopsAnnotationKey]; ok {
-		if value, err := strconv.Atoi(wiops); err == nil {
-			writeIops = value
-		}
-	}
-
-	return readIops, writeIops
+	return limits.ParseIOPSAnnotations(annotations, defaultReadIops, defaultWriteIops, prefix)
 }
 
 func (s *KubeDiskGuardService) Close() error {
@@ -374,111 +378,80 @@ func (s *KubeDiskGuardService) GetSmartLimitManager() *smartlimit.SmartLimitMana
 	return s.smartLimit
 }
 
-func (s *KubeDiskGuardService) Run() error {
+// Run 运行服务，阻塞直到 ctx 取消；取消后Pod同步队列会处理完进行中的任务再返回
+func (s *KubeDiskGuardService) Run(ctx context.Context) error {
+	// 加载上一个实例以 handoff 策略退出时写入的状态快照
+	if err := s.loadStateSnapshot(); err != nil {
+		log.Printf("Failed to load state snapshot: %v", err)
+	}
+
+	if s.Config.StandaloneMode {
+		return s.runStandalone(ctx)
+	}
+
 	if s.smartLimit != nil {
-		s.smartLimit.Start()
+		s.smartLimit.Start(ctx)
 	}
 
 	// 如果 kubeClient 为 nil（智能限速禁用），则跳过 Pod 事件监听
 	if s.kubeClient == nil {
 		log.Println("KubeClient is nil, skipping pod event monitoring (smart limit disabled)")
 		// 只启动智能限速管理器，不进行 Pod 监听
-		select {} // 阻塞等待
-	}
-
-	pods, err := s.kubeClient.ListNodePodsWithKubeletFirst()
-	if err != nil {
-		// 在 kubelet API 模式下，如果连接失败，记录警告但不退出服务
-		if s.Config.SmartLimitUseKubeletAPI {
-			log.Printf("Warning: failed to list existing pods in kubelet API mode: %v", err)
-			log.Println("Continuing without initial pod list, will rely on smart limit manager...")
-			// 只启动智能限速管理器，不进行 Pod 监听
-			select {} // 阻塞等待
-		}
-		return fmt.Errorf("failed to list existing pods: %v", err)
+		<-ctx.Done()
+		return nil
 	}
 
-	podAnnotations := make(map[string]PodAnnotationState)
-	for _, pod := range pods {
-		if !s.ShouldProcessPod(pod) {
-			continue
-		}
-		s.processPodContainers(pod)
-		key := pod.Namespace + "/" + pod.Name
-		readIops, writeIops := ParseIopsLimitFromAnnotations(pod.Annotations, s.Config.ContainerReadIOPSLimit, s.Config.ContainerWriteIOPSLimit, s.Config.SmartLimitAnnotationPrefix)
-		readBps, writeBps := ParseBpsLimitFromAnnotations(pod.Annotations, s.Config.ContainerReadBPSLimit, s.Config.ContainerWriteBPSLimit, s.Config.SmartLimitAnnotationPrefix)
-		podAnnotations[key] = PodAnnotationState{
-			Annotations: pod.Annotations,
-			ReadIops:    readIops,
-			WriteIops:   writeIops,
-			ReadBps:     readBps,
-			WriteBps:    writeBps,
-		}
+	if s.Config.NodeCapacityPatch {
+		s.advertiseNodeCapacity()
 	}
 
-	watcher, err := s.kubeClient.WatchNodePods()
+	// kubelet API 模式下 ListWatch 通过轮询 kubelet /pods 实现，无需 API Server 权限
+	lw, err := s.kubeClient.NodePodListWatcher()
 	if err != nil {
-		// 在 kubelet API 模式下，如果监听失败，记录警告但不退出服务
-		if s.Config.SmartLimitUseKubeletAPI {
-			log.Printf("Warning: failed to watch pods in kubelet API mode: %v", err)
-			log.Println("Continuing without pod watching, will rely on smart limit manager...")
-			// 只启动智能限速管理器，不进行 Pod 监听
-			select {} // 阻塞等待
-		}
-		return fmt.Errorf("failed to watch pods: %v", err)
+		return fmt.Errorf("failed to create pod list watcher: %v", err)
 	}
-	defer watcher.Stop()
 
-	log.Println("Start watching pod events...")
-	s.watchPodEvents(watcher, podAnnotations)
-	return nil
-}
+	resync := time.Duration(s.Config.PodResyncPeriod) * time.Second
+	controller := newPodController(lw, resync, s.Config.WorkQueueMaxRetries, s.ShouldProcessPod, s.processPodContainers)
+	s.pods.Store(controller)
+	go s.runScheduler(ctx, controller)
+	go s.runBudget(ctx, controller)
 
-// watchPodEvents 监听Pod事件的内部方法
-func (s *KubeDiskGuardService) watchPodEvents(watcher watch.Interface, podAnnotations map[string]PodAnnotationState) {
-	ch := watcher.ResultChan()
-	for event := range ch {
-		if event.Object == nil {
-			continue
+	// 命名空间注解作为该命名空间下Pod的默认限速，注解变化时重新同步该命名空间的Pod
+	if nsLW, err := s.kubeClient.NamespaceListWatcher(); err != nil {
+		log.Printf("Namespace default limits disabled: %v", err)
+		if s.filters.UsesNamespaceSelector() {
+			log.Printf("Warning: filter rules with namespace_selector never match without namespace access")
 		}
-		pod, ok := event.Object.(*corev1.Pod)
-		if !ok {
-			continue
+	} else {
+		namespaces := newNamespaceWatcher(nsLW, resync, controller.resyncNamespace)
+		s.namespaces.Store(namespaces)
+		go namespaces.Run(ctx.Done())
+		syncCtx, cancel := context.WithTimeout(ctx, namespaceSyncTimeout)
+		if !cache.WaitForCacheSync(syncCtx.Done(), namespaces.HasSynced) {
+			log.Printf("Warning: namespace cache not synced within %v, namespace defaults apply once synced", namespaceSyncTimeout)
 		}
+		cancel()
+	}
 
-		key := pod.Namespace + "/" + pod.Name
-		switch event.Type {
-		case watch.Modified:
-			if !s.ShouldProcessPod(*pod) {
-				continue
-			}
-
-			old, exists := podAnnotations[key]
-			newAnn := pod.Annotations
-			readIops, writeIops := ParseIopsLimitFromAnnotations(newAnn, s.Config.ContainerReadIOPSLimit, s.Config.ContainerWriteIOPSLimit, s.Config.SmartLimitAnnotationPrefix)
-			readBps, writeBps := ParseBpsLimitFromAnnotations(newAnn, s.Config.ContainerReadBPSLimit, s.Config.ContainerWriteBPSLimit, s.Config.SmartLimitAnnotationPrefix)
-
-			if exists && reflect.DeepEqual(old.Annotations, newAnn) &&
-				old.ReadIops == readIops && old.WriteIops == writeIops &&
-				old.ReadBps == readBps && old.WriteBps == writeBps {
-				continue
-			}
+	log.Printf("Start pod informer on node %s (resync: %ds, workers: %d)", os.Getenv("NODE_NAME"), s.Config.PodResyncPeriod, s.Config.PodSyncWorkers)
+	return controller.Run(s.Config.PodSyncWorkers, ctx.Done())
+}
 
-			s.processPodContainers(*pod)
-			podAnnotations[key] = PodAnnotationState{
-				Annotations: newAnn,
-				ReadIops:    readIops,
-				WriteIops:   writeIops,
-				ReadBps:     readBps,
-				WriteBps:    writeBps,
+func (s *KubeDiskGuardService) ResetAllContainersIOPSLimit() error {
+	if s.Config.StandaloneMode {
+		containers, err := s.runtime.ListContainers()
+		if err != nil {
+			return fmt.Errorf("failed to list containers: %v", err)
+		}
+		for _, c := range containers {
+			if err := s.runtime.ResetLimits(c); err != nil {
+				log.Printf("Failed to reset IOPS limit for container %s: %v", c.ID, err)
 			}
-		case watch.Deleted:
-			delete(podAnnotations, key)
 		}
+		return nil
 	}
-}
 
-func (s *KubeDiskGuardService) ResetAllContainersIOPSLimit() error {
 	pods, err := s.kubeClient.ListNodePodsWithKubeletFirst()
 	if err != nil {
 		return fmt.Errorf("failed to list pods: %v", err)
@@ -487,7 +460,7 @@ func (s *KubeDiskGuardService) ResetAllContainersIOPSLimit() error {
 		if !s.ShouldProcessPod(pod) {
 			continue
 		}
-		for _, cs := range pod.Status.ContainerStatuses {
+		for _, cs := range s.podContainerStatuses(pod) {
 			containerID := parseRuntimeID(cs.ContainerID)
 			if containerID == "" {
 				continue
@@ -510,6 +483,8 @@ func NewKubeDiskGuardServiceWithKubeClient(cfg *config.Config, kc kubeclient.IKu
 	service := &KubeDiskGuardService{
 		Config:     cfg,
 		kubeClient: kc,
+		filters:    filter.LegacyRules(cfg.ExcludeKeywords, cfg.ExcludeNamespaces, cfg.ExcludeLabelSelector),
+		audit:      audit.NewRecorder(cfg.AuditBufferSize),
 	}
 
 	var err error
@@ -521,78 +496,58 @@ func NewKubeDiskGuardServiceWithKubeClient(cfg *config.Config, kc kubeclient.IKu
 	if cfg.SmartLimitEnabled {
 		cgroupMgr := cgroup.NewManager(cfg.CgroupVersion)
 		service.smartLimit = smartlimit.NewSmartLimitManager(cfg, kc, cgroupMgr)
+		service.smartLimit.SetAuditRecorder(service.audit)
 	}
 
 	return service, nil
 }
 
-func hasSmartLimitAnnotation(annotations map[string]string, prefix string) bool {
-	annotationPrefix := prefix + "/"
-	smartKeys := []string{
-		annotationkeys.IopsAnnotationKey, annotationkeys.ReadIopsAnnotationKey, annotationkeys.WriteIopsAnnotationKey,
-		annotationkeys.BpsAnnotationKey, annotationkeys.ReadBpsAnnotationKey, annotationkeys.WriteBpsAnnotationKey,
-	}
-	for _, key := range smartKeys {
-		if _, ok := annotations[annotationPrefix+key]; ok {
-			return true
-		}
-	}
-	return false
-}
-
+// ParseBpsLimitFromAnnotations 解析注解中的bps限制（分别支持读写），<prefix>/profile 引用的命名配置由调用方先展开
 func ParseBpsLimitFromAnnotations(annotations map[string]string, defaultReadBps, defaultWriteBps int, prefix string) (int, int) {
-	readBps, writeBps := defaultReadBps, defaultWriteBps
-	annotationPrefix := prefix + "/"
-
-	if val, ok := annotations[annotationPrefix+annotationkeys.RemovedAnnotationKey]; ok && val == "true" {
-		return 0, 0
-	}
+	return limits.ParseBPSAnnotations(annotations, defaultReadBps, defaultWriteBps, prefix)
+}
 
-	useSmart := hasSmartLimitAnnotation(annotations, prefix)
+// containerScopedAnnotations 提取指定容器的容器级注解，并转换为普通注解key（去掉 container.<name>. 部分）
+func containerScopedAnnotations(annotations map[string]string, containerName, prefix string) map[string]string {
+	return scopedAnnotations(annotations, prefix+"/"+annotationkeys.ContainerKey(containerName, ""), prefix)
+}
 
-	if useSmart {
-		if bps, ok := annotations[annotationPrefix+annotationkeys.BpsAnnotationKey]; ok {
-			if value, err := units.RAMInBytes(bps); err == nil {
-				return int(value), int(value)
-			}
-		}
-		if rbps, ok := annotations[annotationPrefix+annotationkeys.ReadBpsAnnotationKey]; ok {
-			if value, err := units.RAMInBytes(rbps); err == nil {
-				readBps = int(value)
-			}
-		}
-		if wbps, ok := annotations[annotationPrefix+annotationkeys.WriteBpsAnnotationKey]; ok {
-			if value, err := units.RAMInBytes(wbps); err == nil {
-				writeBps = int(value)
-			}
+// scopedAnnotations 提取以 scopePrefix 开头的注解，并转换为 <prefix>/<key> 形式
+func scopedAnnotations(annotations map[string]string, scopePrefix, prefix string) map[string]string {
+	scoped := make(map[string]string)
+	for k, v := range annotations {
+		if strings.HasPrefix(k, scopePrefix) {
+			scoped[prefix+"/"+strings.TrimPrefix(k, scopePrefix)] = v
 		}
-		return readBps, writeBps
 	}
+	return scoped
+}
 
-	// Fallback to legacy only if no smart limit annotations are present
-	if bps, ok := annotations[annotationkeys.LegacyBpsAnnotationKey]; ok {
-		if value, err := units.RAMInBytes(bps); err == nil {
-			return int(value), int(value)
-		}
-	}
-	if rbps, ok := annotations[annotationkeys.LegacyReadBpsAnnotationKey]; ok {
-		if value, err := units.RAMInBytes(rbps); err == nil {
-			readBps = int(value)
-		}
-	}
-	if wbps, ok := annotations[annotationkeys.LegacyWriteBpsAnnotationKey]; ok {
-		if value, err := units.RAMInBytes(wbps); err == nil {
-			writeBps = int(value)
-		}
-	}
+// ParseContainerLimitsFromAnnotations 解析容器级限速注解（如 <prefix>/container.app.write-iops），
+// 未设置的项使用传入的Pod级限速值；容器级注解可通过 <prefix>/container.<name>.profile 引用 profiles 中的命名配置
+func ParseContainerLimitsFromAnnotations(annotations map[string]string, containerName string, podReadIops, podWriteIops, podReadBps, podWriteBps int, prefix string, profiles *limits.ProfileSet) (int, int, int, int) {
+	scoped := containerScopedAnnotations(annotations, containerName, prefix)
+	if len(scoped) == 0 {
+		return podReadIops, podWriteIops, podReadBps, podWriteBps
+	}
+	scoped = profiles.Expand(scoped, prefix)
+	readIops, writeIops := ParseIopsLimitFromAnnotations(scoped, podReadIops, podWriteIops, prefix)
+	readBps, writeBps := ParseBpsLimitFromAnnotations(scoped, podReadBps, podWriteBps, prefix)
+	return readIops, writeIops, readBps, writeBps
+}
 
-	return readBps, writeBps
+// IsContainerExcludedByAnnotation 判断容器是否通过 <prefix>/container.<name>.exclude: "true" 注解排除
+func IsContainerExcludedByAnnotation(annotations map[string]string, containerName, prefix string) bool {
+	val, ok := annotations[prefix+"/"+annotationkeys.ContainerKey(containerName, annotationkeys.ExcludeAnnotationKey)]
+	if !ok {
+		return false
+	}
+	excluded, err := strconv.ParseBool(val)
+	return err == nil && excluded
 }
 
+// PodAnnotationState 记录Pod最近一次成功同步时的注解与容器ID
 type PodAnnotationState struct {
-	Annotations map[string]string
-	ReadIops    int
-	WriteIops   int
-	ReadBps     int
-	WriteBps    int
+	Annotations  map[string]string
+	ContainerIDs []string
 }
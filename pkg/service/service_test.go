@@ -2,9 +2,11 @@ package service
 
 import (
 	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/filter"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestParseAnnotations(t *testing.T) {
@@ -109,9 +111,8 @@ func TestParseAnnotations(t *testing.T) {
 
 func TestShouldSkipContainer(t *testing.T) {
 	svc := &KubeDiskGuardService{
-		Config: &config.Config{
-			ExcludeKeywords: []string{"pause", "istio-proxy"},
-		},
+		Config:  &config.Config{},
+		filters: filter.LegacyRules([]string{"pause", "istio-proxy"}, nil, ""),
 	}
 
 	testCases := []struct {
@@ -131,3 +132,79 @@ func TestShouldSkipContainer(t *testing.T) {
 		})
 	}
 }
+
+func TestParseContainerLimitsFromAnnotations(t *testing.T) {
+	prefix := "kubediskguard.io"
+	annotations := map[string]string{
+		prefix + "/read-iops":                 "100",
+		prefix + "/write-iops":                "200",
+		prefix + "/container.db.write-iops":   "50",
+		prefix + "/container.db.write-bps":    "1M",
+		prefix + "/container.cache.removed":   "true",
+		prefix + "/container.sidecar.exclude": "true",
+		prefix + "/container.logger.exclude":  "false",
+		prefix + "/container.database.iops":   "999", // 前缀相同的其他容器不应影响 db
+	}
+	podReadIops, podWriteIops := ParseIopsLimitFromAnnotations(annotations, 500, 500, prefix)
+	podReadBps, podWriteBps := ParseBpsLimitFromAnnotations(annotations, 0, 0, prefix)
+
+	cases := []struct {
+		name      string
+		container string
+		expected  [4]int
+	}{
+		{"container override with pod fallback", "db", [4]int{100, 50, 0, 1024 * 1024}},
+		{"container removed", "cache", [4]int{0, 0, 0, 0}},
+		{"no container annotations", "app", [4]int{100, 200, 0, 0}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			riops, wiops, rbps, wbps := ParseContainerLimitsFromAnnotations(annotations, tc.container, podReadIops, podWriteIops, podReadBps, podWriteBps, prefix, nil)
+			assert.Equal(t, tc.expected, [4]int{riops, wiops, rbps, wbps})
+		})
+	}
+
+	assert.True(t, IsContainerExcludedByAnnotation(annotations, "sidecar", prefix))
+	assert.False(t, IsContainerExcludedByAnnotation(annotations, "logger", prefix))
+	assert.False(t, IsContainerExcludedByAnnotation(annotations, "db", prefix))
+}
+
+func TestPodContainerStatusesByKind(t *testing.T) {
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	terminated := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+	started := true
+	pod := corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{Name: "done", ContainerID: "containerd://init-1", State: terminated},
+				{Name: "loader", ContainerID: "containerd://init-2", State: running},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", ContainerID: "containerd://app", Started: &started}},
+			EphemeralContainerStatuses: []corev1.ContainerStatus{
+				{Name: "debugger", ContainerID: "containerd://debug", State: running},
+			},
+		},
+	}
+	names := func(statuses []corev1.ContainerStatus) []string {
+		var result []string
+		for _, cs := range statuses {
+			result = append(result, cs.Name)
+		}
+		return result
+	}
+
+	svc := &KubeDiskGuardService{Config: &config.Config{ProcessInitContainers: true, ProcessEphemeralContainers: true}}
+	assert.Equal(t, []string{"loader", "app", "debugger"}, names(svc.podContainerStatuses(pod)))
+	// Pending 阶段有运行中的init容器时需要处理
+	assert.True(t, svc.ShouldProcessPod(pod))
+
+	svc = &KubeDiskGuardService{Config: &config.Config{}}
+	assert.Equal(t, []string{"app"}, names(svc.podContainerStatuses(pod)))
+	assert.False(t, svc.ShouldProcessPod(pod))
+
+	// 容器重启后ID变化应体现在同步状态中
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].ContainerID = "containerd://app-restarted"
+	assert.NotEqual(t, podContainerIDs(pod), podContainerIDs(*restarted))
+}
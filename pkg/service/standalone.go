@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"KubeDiskGuard/pkg/container"
+	"KubeDiskGuard/pkg/limits"
+)
+
+// standaloneRetryInterval 运行时事件流断开后的重连间隔
+const standaloneRetryInterval = 5 * time.Second
+
+// runStandalone 独立模式主循环：直接从容器运行时发现容器，按容器标签或静态规则限速，ctx 取消后返回
+func (s *KubeDiskGuardService) runStandalone(ctx context.Context) error {
+	log.Println("Running in standalone mode, discovering containers from runtime")
+	for {
+		// 每次（重新）订阅事件后全量同步一次，避免断连期间遗漏的容器
+		events, errs := s.runtime.WatchContainerEvents(ctx)
+		if err := s.syncStandaloneContainers(); err != nil {
+			log.Printf("Failed to sync containers in standalone mode: %v", err)
+		}
+		s.consumeStandaloneEvents(ctx, events, errs)
+		if ctx.Err() != nil {
+			log.Println("Standalone mode stopped")
+			return nil
+		}
+		log.Printf("Runtime event stream closed, reconnecting in %v", standaloneRetryInterval)
+		select {
+		case <-time.After(standaloneRetryInterval):
+		case <-ctx.Done():
+			log.Println("Standalone mode stopped")
+			return nil
+		}
+	}
+}
+
+// consumeStandaloneEvents 处理运行时事件，直到事件流关闭或 ctx 取消
+func (s *KubeDiskGuardService) consumeStandaloneEvents(ctx context.Context, events <-chan container.ContainerEvent, errs <-chan error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if ev.Type != container.ContainerEventStart {
+				continue
+			}
+			containerInfo, err := s.runtime.GetContainerByID(ev.ContainerID)
+			if err != nil {
+				log.Printf("Failed to get container info for %s: %v", ev.ContainerID, err)
+				continue
+			}
+			s.processStandaloneContainer(containerInfo)
+		case err, ok := <-errs:
+			if ok && err != nil {
+				log.Printf("Runtime event stream error: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// syncStandaloneContainers 对运行时中所有运行中的容器执行一次限速
+func (s *KubeDiskGuardService) syncStandaloneContainers() error {
+	containers, err := s.runtime.ListContainers()
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		s.processStandaloneContainer(c)
+	}
+	return nil
+}
+
+// processStandaloneContainer 解析单个容器的限速配置并下发
+// 优先级：容器标签 > 静态规则 > 全局默认值
+func (s *KubeDiskGuardService) processStandaloneContainer(containerInfo *container.ContainerInfo) {
+	containerTotal.Inc()
+	if res := s.filterStandaloneContainer(containerInfo); !res.Included() {
+		log.Printf("Skip IOPS/BPS limit for container %s (excluded by filter rule %s)", containerInfo.ID, res.Rule)
+		containerSkip.Inc()
+		return
+	}
+
+	// 优先级：全局 → 静态规则 → 容器标签
+	res := s.resolveStandaloneLimits(containerInfo)
+	for _, layer := range res.Chain {
+		if layer.Source == limits.LayerRule {
+			log.Printf("Container %s (%s) matched standalone rule %s", containerInfo.ID, containerInfo.Name, layer.Name)
+		}
+	}
+	effective := res.Effective
+
+	// 失败已在 enforceContainerLimits 中记录日志与指标，独立模式下等待下次事件或重连后的全量同步重试
+	_ = s.enforceContainerLimits(containerInfo, AppliedLimit{
+		ContainerName: containerInfo.Name,
+		ReadIops:      effective.ReadIOPS, WriteIops: effective.WriteIOPS, ReadBps: effective.ReadBPS, WriteBps: effective.WriteBPS,
+	})
+}
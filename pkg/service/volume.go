@@ -0,0 +1,192 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/budget"
+	"KubeDiskGuard/pkg/device"
+	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// claimCacheTTL PVC存储参数的缓存时间，卷属性类变更后最多延迟该时间生效
+const claimCacheTTL = 5 * time.Minute
+
+// 设备号查询，测试中替换
+var (
+	pathMajMin     = device.GetPathMajMin
+	dataDiskMajMin = device.GetMajMin
+)
+
+// cachedClaim 缓存的PVC存储参数
+type cachedClaim struct {
+	storage   *kubeclient.ClaimStorage
+	expiresAt time.Time
+}
+
+// claimStorage 获取PVC绑定的卷及存储类参数，成功的结果缓存 claimCacheTTL
+func (s *KubeDiskGuardService) claimStorage(namespace, claimName string) (*kubeclient.ClaimStorage, error) {
+	key := namespace + "/" + claimName
+	now := time.Now()
+	s.claimsMu.Lock()
+	if cached, ok := s.claims[key]; ok && now.Before(cached.expiresAt) {
+		s.claimsMu.Unlock()
+		return cached.storage, nil
+	}
+	s.claimsMu.Unlock()
+
+	storage, err := s.kubeClient.GetClaimStorage(namespace, claimName)
+	if err != nil {
+		return nil, err
+	}
+	s.claimsMu.Lock()
+	defer s.claimsMu.Unlock()
+	if s.claims == nil {
+		s.claims = make(map[string]cachedClaim)
+	}
+	for k, cached := range s.claims {
+		if now.After(cached.expiresAt) {
+			delete(s.claims, k)
+		}
+	}
+	s.claims[key] = cachedClaim{storage: storage, expiresAt: now.Add(claimCacheTTL)}
+	return storage, nil
+}
+
+// podVolumeLimits 按PVC绑定的存储类（及卷属性类）参数解析Pod各卷所在设备的限速，
+// 优先级：存储类参数 → 卷属性类参数 → 卷级注解（<prefix>/volume.<卷名>.<key>）；未启用或无法访问API Server时返回nil
+func (s *KubeDiskGuardService) podVolumeLimits(pod corev1.Pod) []limits.VolumeLimit {
+	if s.storageParams == nil || s.kubeClient == nil {
+		return nil
+	}
+	prefix := s.Config.SmartLimitAnnotationPrefix
+	unit := s.Config.StorageClassThroughputUnit
+	var result []limits.VolumeLimit
+	var dataDevice string
+	for _, vol := range pod.Spec.Volumes {
+		claimName := volumeClaimName(pod, vol)
+		if claimName == "" {
+			continue
+		}
+		storage, err := s.claimStorage(pod.Namespace, claimName)
+		if err != nil {
+			log.Printf("Skip storage class limits for volume %s (pod: %s/%s): %v", vol.Name, pod.Namespace, pod.Name, err)
+			continue
+		}
+		current := limits.ParseLayer(s.storageParams.Annotations(storage.StorageClassParameters, unit, prefix), limits.Limits{}, prefix, s.limitProfiles)
+		current = limits.ParseLayer(s.storageParams.Annotations(storage.VolumeAttributesClassParameters, unit, prefix), current, prefix, s.limitProfiles)
+		current = limits.ParseLayer(volumeScopedAnnotations(pod.Annotations, vol.Name, prefix), current, prefix, s.limitProfiles)
+		if current.IsZero() {
+			continue
+		}
+
+		dev, err := s.volumeDevice(pod, storage.VolumeName)
+		if err != nil {
+			log.Printf("Skip storage class limits for volume %s (pod: %s/%s): %v", vol.Name, pod.Namespace, pod.Name, err)
+			continue
+		}
+		if dataDevice == "" {
+			if dataDevice, err = dataDiskMajMin(s.Config.DataMount); err != nil {
+				log.Printf("Failed to get data disk device of %s: %v", s.Config.DataMount, err)
+			}
+		}
+		if dev == dataDevice {
+			// 同一设备在cgroup中只能有一组限速，数据盘上的卷按数据盘限速
+			log.Printf("Skip storage class limits for volume %s (pod: %s/%s): volume is on the data disk %s", vol.Name, pod.Namespace, pod.Name, dev)
+			continue
+		}
+		result = append(result, limits.VolumeLimit{
+			Volume:                vol.Name,
+			Claim:                 claimName,
+			StorageClass:          storage.StorageClassName,
+			VolumeAttributesClass: storage.VolumeAttributesClassName,
+			Device:                dev,
+			Limits:                current,
+		})
+	}
+	return result
+}
+
+// volumeClaimName 返回卷引用的PVC名称，通用临时卷的PVC名称为 <Pod名>-<卷名>，非PVC卷返回空
+func volumeClaimName(pod corev1.Pod, vol corev1.Volume) string {
+	switch {
+	case vol.PersistentVolumeClaim != nil:
+		return vol.PersistentVolumeClaim.ClaimName
+	case vol.Ephemeral != nil:
+		return pod.Name + "-" + vol.Name
+	}
+	return ""
+}
+
+// volumeDevice 在kubelet目录下定位PV在本Pod中的挂载点（文件系统卷）或设备文件（Block模式的卷），返回其所在块设备
+func (s *KubeDiskGuardService) volumeDevice(pod corev1.Pod, pvName string) (string, error) {
+	podDir := filepath.Join(s.Config.KubeletRootDir, "pods", string(pod.UID))
+	// 文件系统卷挂载在 volumes/<插件>/<PV名>，CSI卷的挂载点为其下的 mount 目录
+	mounts, _ := filepath.Glob(filepath.Join(podDir, "volumes", "*", pvName))
+	for _, path := range mounts {
+		if _, err := os.Stat(filepath.Join(path, "mount")); err == nil {
+			path = filepath.Join(path, "mount")
+		}
+		return pathMajMin(path)
+	}
+	blocks, _ := filepath.Glob(filepath.Join(podDir, "volumeDevices", "*", pvName))
+	for _, path := range blocks {
+		return pathMajMin(path)
+	}
+	return "", fmt.Errorf("volume %s not found under %s", pvName, podDir)
+}
+
+// containerVolumeLimits 返回容器挂载了的卷的设备限速，同一设备被多个卷使用时逐项取更严格的值
+func containerVolumeLimits(pod corev1.Pod, containerName string, volumes []limits.VolumeLimit) []limits.VolumeLimit {
+	if len(volumes) == 0 {
+		return nil
+	}
+	mounted := make(map[string]bool)
+	collect := func(name string, mounts []corev1.VolumeMount, devices []corev1.VolumeDevice) {
+		if name != containerName {
+			return
+		}
+		for _, m := range mounts {
+			mounted[m.Name] = true
+		}
+		for _, d := range devices {
+			mounted[d.Name] = true
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		collect(c.Name, c.VolumeMounts, c.VolumeDevices)
+	}
+	for _, c := range pod.Spec.Containers {
+		collect(c.Name, c.VolumeMounts, c.VolumeDevices)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		collect(c.Name, c.VolumeMounts, c.VolumeDevices)
+	}
+
+	var result []limits.VolumeLimit
+	byDevice := make(map[string]int)
+	for _, v := range volumes {
+		if !mounted[v.Volume] {
+			continue
+		}
+		if i, ok := byDevice[v.Device]; ok {
+			result[i].Limits = budget.Min(result[i].Limits, v.Limits)
+			continue
+		}
+		byDevice[v.Device] = len(result)
+		result = append(result, v)
+	}
+	return result
+}
+
+// volumeScopedAnnotations 提取指定卷的卷级注解，并转换为普通注解key（去掉 volume.<name>. 部分）
+func volumeScopedAnnotations(annotations map[string]string, volumeName, prefix string) map[string]string {
+	return scopedAnnotations(annotations, prefix+"/"+annotationkeys.VolumeKey(volumeName, ""), prefix)
+}
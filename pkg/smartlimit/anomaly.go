@@ -0,0 +1,258 @@
+package smartlimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
+)
+
+// anomalyTrigger 异常检测触发的限速记录的触发来源
+const anomalyTrigger = "anomaly"
+
+// Baseline 单项IO速率的EWMA基线
+type Baseline struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+}
+
+// StdDev 基线标准差
+func (b Baseline) StdDev() float64 {
+	return math.Sqrt(b.Variance)
+}
+
+// upper 基线均值加 sigma 倍标准差
+func (b Baseline) upper(sigma float64) float64 {
+	return b.Mean + sigma*b.StdDev()
+}
+
+// update 按EWMA更新均值和方差，首个样本直接作为均值
+func (b *Baseline) update(value, alpha float64, first bool) {
+	if first {
+		b.Mean, b.Variance = value, 0
+		return
+	}
+	diff := value - b.Mean
+	incr := alpha * diff
+	b.Mean += incr
+	b.Variance = (1 - alpha) * (b.Variance + diff*incr)
+}
+
+// IORate 一个采集间隔内的平均读写速率
+type IORate struct {
+	ReadIOPS  float64 `json:"read_iops"`
+	WriteIOPS float64 `json:"write_iops"`
+	ReadBPS   float64 `json:"read_bps"`
+	WriteBPS  float64 `json:"write_bps"`
+}
+
+// ContainerBaseline 容器各项IO速率的基线及当前异常状态（API 接口、持久化）
+type ContainerBaseline struct {
+	ContainerID         string    `json:"container_id"`
+	ContainerName       string    `json:"container_name"`
+	PodName             string    `json:"pod_name"`
+	Namespace           string    `json:"namespace"`
+	ReadIOPS            Baseline  `json:"read_iops"`
+	WriteIOPS           Baseline  `json:"write_iops"`
+	ReadBPS             Baseline  `json:"read_bps"`
+	WriteBPS            Baseline  `json:"write_bps"`
+	Samples             int       `json:"samples"`               // 已学习的样本数
+	Current             IORate    `json:"current"`               // 最近一个采集间隔的速率
+	ReadAnomalousSince  time.Time `json:"read_anomalous_since"`  // 读方向连续超出基线的起始时间，零值表示当前未超出
+	WriteAnomalousSince time.Time `json:"write_anomalous_since"` // 写方向连续超出基线的起始时间，零值表示当前未超出
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// sampleRate 计算相邻两次采集之间的速率，计数器回退（容器重启）或时间未前进时返回false
+func sampleRate(prev, cur *kubeclient.IOStats) (IORate, bool) {
+	seconds := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+	if seconds <= 0 || cur.ReadIOPS < prev.ReadIOPS || cur.WriteIOPS < prev.WriteIOPS ||
+		cur.ReadBPS < prev.ReadBPS || cur.WriteBPS < prev.WriteBPS {
+		return IORate{}, false
+	}
+	return IORate{
+		ReadIOPS:  float64(cur.ReadIOPS-prev.ReadIOPS) / seconds,
+		WriteIOPS: float64(cur.WriteIOPS-prev.WriteIOPS) / seconds,
+		ReadBPS:   float64(cur.ReadBPS-prev.ReadBPS) / seconds,
+		WriteBPS:  float64(cur.WriteBPS-prev.WriteBPS) / seconds,
+	}, true
+}
+
+// exceeds 单项速率是否超出基线 sigma 倍标准差，且不低于最小值（避免近乎空闲的容器因微小波动触发）
+func exceeds(rate float64, b Baseline, sigma float64, floor int) bool {
+	return rate > b.upper(sigma) && rate > float64(floor)
+}
+
+// within 单项速率是否已回落到基线 sigma 倍标准差以内或低于最小值
+func within(rate float64, b Baseline, sigma float64, floor int) bool {
+	return rate <= b.upper(sigma) || rate <= float64(floor)
+}
+
+// updateBaseline 用最近一个采集间隔的速率更新容器基线，返回更新后的基线副本供调用方释放锁后持久化，
+// 未更新时返回nil；调用方需持有 m.mu 写锁和 history 锁：
+// 预热结束后先按当前基线判断读写方向是否超出并记录起始时间，再学习样本；超出的样本截断到基线上界再学习，
+// 避免突发被快速吸收进基线，持续的水位变化仍会逐步抬高基线；已限速容器的速率受限速值约束，不学习
+func (m *SmartLimitManager) updateBaseline(history *ContainerIOHistory) *ContainerBaseline {
+	n := len(history.Stats)
+	if n < 2 {
+		return nil
+	}
+	latest := history.Stats[n-1]
+	rate, ok := sampleRate(history.Stats[n-2], latest)
+	if !ok {
+		return nil
+	}
+	b, exists := m.baselines[history.ContainerID]
+	if !exists {
+		b = &ContainerBaseline{ContainerID: history.ContainerID}
+		m.baselines[history.ContainerID] = b
+	}
+	b.ContainerName, b.PodName, b.Namespace = history.ContainerName, history.PodName, history.Namespace
+	b.Current = rate
+	b.UpdatedAt = latest.Timestamp
+
+	sigma := m.config.SmartLimitAnomalySigma * history.Policy.Scale()
+	minIOPS, minBPS := m.config.SmartLimitAnomalyMinIOPS, m.config.SmartLimitAnomalyMinBPS
+	warmedUp := b.Samples >= m.config.SmartLimitAnomalyWarmup
+	if warmedUp {
+		read := exceeds(rate.ReadIOPS, b.ReadIOPS, sigma, minIOPS) || exceeds(rate.ReadBPS, b.ReadBPS, sigma, minBPS)
+		write := exceeds(rate.WriteIOPS, b.WriteIOPS, sigma, minIOPS) || exceeds(rate.WriteBPS, b.WriteBPS, sigma, minBPS)
+		b.ReadAnomalousSince = anomalousSince(b.ReadAnomalousSince, read, latest.Timestamp)
+		b.WriteAnomalousSince = anomalousSince(b.WriteAnomalousSince, write, latest.Timestamp)
+	}
+
+	if status := m.limitStatus[history.ContainerID]; status != nil {
+		status.mu.RLock()
+		limited := status.IsLimited
+		status.mu.RUnlock()
+		if limited {
+			baselineCopy := *b
+			return &baselineCopy
+		}
+	}
+	learn := func(base *Baseline, value float64) {
+		if warmedUp {
+			value = math.Min(value, base.upper(sigma))
+		}
+		base.update(value, m.config.SmartLimitAnomalyAlpha, b.Samples == 0)
+	}
+	learn(&b.ReadIOPS, rate.ReadIOPS)
+	learn(&b.WriteIOPS, rate.WriteIOPS)
+	learn(&b.ReadBPS, rate.ReadBPS)
+	learn(&b.WriteBPS, rate.WriteBPS)
+	b.Samples++
+	baselineCopy := *b
+	return &baselineCopy
+}
+
+// anomalousSince 更新连续超出基线的起始时间
+func anomalousSince(since time.Time, anomalous bool, at time.Time) time.Time {
+	switch {
+	case !anomalous:
+		return time.Time{}
+	case since.IsZero():
+		return at
+	}
+	return since
+}
+
+// detect 按Pod策略选择的检测方式（未设置时使用全局默认）判断是否需要限速
+func (m *SmartLimitManager) detect(containerID string, trend *IOTrend, policy *limits.SmartLimitPolicy) (bool, *LimitResult) {
+	if policy.DetectorOr(m.config.SmartLimitDetector) != limits.DetectorAnomaly {
+		return m.shouldApplyLimitForPolicy(trend, policy)
+	}
+	if policy.IsExempt() {
+		return false, nil
+	}
+	return m.shouldApplyLimitAnomaly(containerID, policy.Scale())
+}
+
+// shouldApplyLimitAnomaly 异常检测：读或写方向持续超出基线 SmartLimitAnomalySustain 分钟后限速该方向，
+// 限速值为基线均值加 sigma 倍标准差，限制在最小值与 MaxIOPSLimit/MaxBPSLimit 之间
+func (m *SmartLimitManager) shouldApplyLimitAnomaly(containerID string, scale float64) (bool, *LimitResult) {
+	b, exists := m.GetContainerBaseline(containerID)
+	if !exists || b.Samples < m.config.SmartLimitAnomalyWarmup {
+		return false, nil
+	}
+	sustain := time.Duration(m.config.SmartLimitAnomalySustain) * time.Minute
+	sustained := func(since time.Time) bool {
+		return !since.IsZero() && time.Since(since) >= sustain
+	}
+	read, write := sustained(b.ReadAnomalousSince), sustained(b.WriteAnomalousSince)
+	if !read && !write {
+		return false, nil
+	}
+
+	sigma := m.config.SmartLimitAnomalySigma * scale
+	result := &LimitResult{TriggeredBy: anomalyTrigger, Read: read, Write: write}
+	var steps []string
+	limit := func(name string, rate float64, base Baseline, floor, ceiling int) int {
+		value := proportionalLimit(base.upper(sigma), 1, floor, ceiling)
+		steps = append(steps, fmt.Sprintf("%s:%.2f(基线%.2f±%.2f)=>%d", name, rate, base.Mean, base.StdDev(), value))
+		return value
+	}
+	if read {
+		result.ReadIOPS = limit("ReadIOPS", b.Current.ReadIOPS, b.ReadIOPS, m.config.SmartLimitAnomalyMinIOPS, m.config.MaxIOPSLimit)
+		result.ReadBPS = limit("ReadBPS", b.Current.ReadBPS, b.ReadBPS, m.config.SmartLimitAnomalyMinBPS, m.config.MaxBPSLimit)
+	}
+	if write {
+		result.WriteIOPS = limit("WriteIOPS", b.Current.WriteIOPS, b.WriteIOPS, m.config.SmartLimitAnomalyMinIOPS, m.config.MaxIOPSLimit)
+		result.WriteBPS = limit("WriteBPS", b.Current.WriteBPS, b.WriteBPS, m.config.SmartLimitAnomalyMinBPS, m.config.MaxBPSLimit)
+	}
+	result.Reason = fmt.Sprintf("速率持续%d分钟超出基线%.2f倍标准差，限速方向:%s，限速为基线上界[%s]",
+		m.config.SmartLimitAnomalySustain, sigma, directionLabel(read, write), strings.Join(steps, ","))
+	return true, result
+}
+
+// anomalyReleased 异常检测触发的限速，各项速率都回落到基线 SmartLimitAnomalyReleaseSigma 倍标准差以内时可以解除
+func (m *SmartLimitManager) anomalyReleased(b *ContainerBaseline) bool {
+	if b == nil {
+		return false
+	}
+	sigma := m.config.SmartLimitAnomalyReleaseSigma
+	minIOPS, minBPS := m.config.SmartLimitAnomalyMinIOPS, m.config.SmartLimitAnomalyMinBPS
+	return within(b.Current.ReadIOPS, b.ReadIOPS, sigma, minIOPS) &&
+		within(b.Current.WriteIOPS, b.WriteIOPS, sigma, minIOPS) &&
+		within(b.Current.ReadBPS, b.ReadBPS, sigma, minBPS) &&
+		within(b.Current.WriteBPS, b.WriteBPS, sigma, minBPS)
+}
+
+// buildAnomalyRemoveReason 构建异常检测限速的解除原因
+func (m *SmartLimitManager) buildAnomalyRemoveReason(containerID string) string {
+	b, exists := m.GetContainerBaseline(containerID)
+	if !exists {
+		return "IO已恢复正常[无基线]"
+	}
+	return fmt.Sprintf("IO已回落到基线[ReadIOPS:%.2f/%.2f,WriteIOPS:%.2f/%.2f,ReadBPS:%.2f/%.2f,WriteBPS:%.2f/%.2f], 标准差倍数:%.2f",
+		b.Current.ReadIOPS, b.ReadIOPS.Mean, b.Current.WriteIOPS, b.WriteIOPS.Mean,
+		b.Current.ReadBPS, b.ReadBPS.Mean, b.Current.WriteBPS, b.WriteBPS.Mean, m.config.SmartLimitAnomalyReleaseSigma)
+}
+
+// GetAllBaselines 获取所有容器的IO基线（API 接口）
+func (m *SmartLimitManager) GetAllBaselines() map[string]*ContainerBaseline {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string]*ContainerBaseline, len(m.baselines))
+	for containerID, b := range m.baselines {
+		baselineCopy := *b
+		result[containerID] = &baselineCopy
+	}
+	return result
+}
+
+// GetContainerBaseline 获取单个容器的IO基线（API 接口）
+func (m *SmartLimitManager) GetContainerBaseline(containerID string) (*ContainerBaseline, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.baselines[containerID]
+	if !exists {
+		return nil, false
+	}
+	baselineCopy := *b
+	return &baselineCopy, true
+}
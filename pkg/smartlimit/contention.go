@@ -0,0 +1,145 @@
+package smartlimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"KubeDiskGuard/pkg/device"
+)
+
+// sampleDevice 采样数据盘负载供争用判断使用，未启用争用判断时不采样；只在监控循环中调用
+func (m *SmartLimitManager) sampleDevice() {
+	if !m.config.SmartLimitContentionEnabled {
+		return
+	}
+	if m.deviceCollector == nil {
+		majMin := m.dataDiskMajMin()
+		if majMin == "" {
+			return
+		}
+		m.deviceCollector = device.NewCollector(device.DiskStatsFile, majMin)
+		log.Printf("Sampling data disk %s for smart limit contention check", majMin)
+	}
+	usage, ok, err := m.deviceCollector.Sample(time.Now())
+	if err != nil {
+		log.Printf("Failed to sample data disk usage: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	m.deviceUsage = &usage
+	m.mu.Unlock()
+}
+
+// dataDiskMajMin 数据盘设备号，首次调用时解析，解析失败时返回空字符串并在下次调用时重试；只在监控循环中调用
+func (m *SmartLimitManager) dataDiskMajMin() string {
+	if m.dataDisk == "" {
+		majMin, err := device.GetPathMajMin(m.config.DataMount)
+		if err != nil {
+			log.Printf("Failed to resolve data disk of %s: %v", m.config.DataMount, err)
+			return ""
+		}
+		m.dataDisk = majMin
+	}
+	return m.dataDisk
+}
+
+// GetDeviceUsage 获取最近一次采样的数据盘负载（API 接口）
+func (m *SmartLimitManager) GetDeviceUsage() (*device.Usage, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.deviceUsage == nil {
+		return nil, false
+	}
+	usage := *m.deviceUsage
+	return &usage, true
+}
+
+// checkContention 争用判断：数据盘繁忙（利用率、等待时间或其他容器的IO延迟达到阈值）且容器占设备IO的比例达到阈值时返回true，
+// 同时返回判断依据；未启用争用判断、尚未采样或采样已过期时不拦截限速
+func (m *SmartLimitManager) checkContention(history *ContainerIOHistory) (bool, string) {
+	if !m.config.SmartLimitContentionEnabled {
+		return true, ""
+	}
+	usage, ok := m.GetDeviceUsage()
+	if !ok || time.Since(usage.SampledAt) > m.sampleTTL() {
+		return true, ""
+	}
+	busy := (m.config.SmartLimitContentionUtil > 0 && usage.Utilization >= m.config.SmartLimitContentionUtil) ||
+		(m.config.SmartLimitContentionAwait > 0 && usage.Await >= m.config.SmartLimitContentionAwait)
+	share := deviceShare(history, usage)
+	detail := fmt.Sprintf("设备%s利用率:%.1f%%,等待:%.1fms,队列:%.2f,容器占比:%.1f%%",
+		usage.Name, usage.Utilization, usage.Await, usage.QueueDepth, share*100)
+	// 受影响方信号：其他容器IO延迟达到阈值时，即使设备整体指标未达阈值也视为繁忙
+	if m.config.SmartLimitContentionLatency > 0 {
+		if victim, latency := m.slowestNeighbor(history.ContainerID); latency >= m.config.SmartLimitContentionLatency {
+			busy = true
+			detail += fmt.Sprintf(",受影响容器%s延迟:%.1fms", victim, latency)
+		}
+	}
+	return busy && share >= m.config.SmartLimitContentionShare, detail
+}
+
+// sampleTTL 采样的有效期，超过3个监控间隔未更新的采样视为过期
+func (m *SmartLimitManager) sampleTTL() time.Duration {
+	return 3 * time.Duration(m.config.SmartLimitMonitorInterval) * time.Second
+}
+
+// slowestNeighbor 除指定容器外，同一数据盘上最近一次采样读写延迟最高的容器（namespace/pod/container）及其延迟（毫秒），
+// 过期的采样不参与比较；已限速容器的延迟受自身限速影响，不作为受影响方；无法确认使用数据盘的容器（如 cgroup v1）不参与比较；
+// 没有容器报告延迟时返回0
+func (m *SmartLimitManager) slowestNeighbor(containerID string) (string, float64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var name string
+	var slowest float64
+	for id, history := range m.history {
+		if id == containerID || !m.dataDiskUsers[id] {
+			continue
+		}
+		if status, ok := m.limitStatus[id]; ok {
+			status.mu.RLock()
+			limited := status.IsLimited
+			status.mu.RUnlock()
+			if limited {
+				continue
+			}
+		}
+		history.mu.RLock()
+		if n := len(history.Stats); n > 0 {
+			last := history.Stats[n-1]
+			latency := float64(max(last.ReadLatency, last.WriteLatency)) / 1000
+			if time.Since(last.Timestamp) <= m.sampleTTL() && latency > slowest {
+				name, slowest = history.Namespace+"/"+history.PodName+"/"+history.ContainerName, latency
+			}
+		}
+		history.mu.RUnlock()
+	}
+	return name, slowest
+}
+
+// deviceShare 容器最近一个采集间隔的IOPS、BPS分别占设备的比例，取较大者
+func deviceShare(history *ContainerIOHistory, usage *device.Usage) float64 {
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+	n := len(history.Stats)
+	if n < 2 {
+		return 0
+	}
+	rate, ok := sampleRate(history.Stats[n-2], history.Stats[n-1])
+	if !ok {
+		return 0
+	}
+	var share float64
+	if iops := usage.IOPS(); iops > 0 {
+		share = (rate.ReadIOPS + rate.WriteIOPS) / iops
+	}
+	if bps := usage.BPS(); bps > 0 {
+		share = math.Max(share, (rate.ReadBPS+rate.WriteBPS)/bps)
+	}
+	return math.Min(share, 1)
+}
@@ -0,0 +1,107 @@
+package smartlimit
+
+import (
+	"log"
+	"strings"
+
+	"KubeDiskGuard/pkg/cgroup"
+	"KubeDiskGuard/pkg/kubeclient"
+)
+
+// cgroupRoot cgroup v2 挂载点，查找容器 cgroup 目录的起点
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cadvisorIOTime 获取各容器的累积加权IO时间（cAdvisor container_fs_io_time_weighted_seconds_total），获取失败时返回nil
+func (m *SmartLimitManager) cadvisorIOTime() map[string]float64 {
+	metrics, err := m.kubeClient.GetCadvisorMetrics()
+	if err != nil {
+		log.Printf("Failed to get cadvisor metrics for IO latency: %v", err)
+		return nil
+	}
+	parsed, err := m.kubeClient.ParseCadvisorMetrics(metrics)
+	if err != nil {
+		log.Printf("Failed to parse cadvisor metrics for IO latency: %v", err)
+		return nil
+	}
+	return parsed.ContainerFSIoTimeWeighted
+}
+
+// fillLatency kubelet summary 不含IO延迟：按cAdvisor累积加权IO时间与上次采集的差值除以期间的IO次数计算平均延迟
+// （不区分读写，读写延迟取同一值），cAdvisor没有该容器的数据或期间无IO时再从 cgroup io.stat 补充
+func (m *SmartLimitManager) fillLatency(containerID string, stats *kubeclient.IOStats, ioTime map[string]float64) {
+	if t, ok := ioTime[containerID]; ok {
+		stats.IOTime = t
+		if prev := m.lastIOStats(containerID); prev != nil && prev.IOTime > 0 && t >= prev.IOTime {
+			if ios := stats.ReadIOPS - prev.ReadIOPS + stats.WriteIOPS - prev.WriteIOPS; ios > 0 {
+				latency := int64((t - prev.IOTime) / float64(ios) * 1e6)
+				stats.ReadLatency, stats.WriteLatency = latency, latency
+			}
+		}
+	}
+	m.fillCgroupLatency(containerID, stats)
+}
+
+// lastIOStats 返回容器最近一次的采集结果，没有历史时返回nil
+func (m *SmartLimitManager) lastIOStats(containerID string) *kubeclient.IOStats {
+	m.mu.RLock()
+	history, exists := m.history[containerID]
+	m.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+	if n := len(history.Stats); n > 0 {
+		return history.Stats[n-1]
+	}
+	return nil
+}
+
+// fillCgroupLatency cgroup v2 下读取容器 io.stat 中数据盘的统计：记录容器是否在数据盘上有IO，未取得延迟时用 avg_lat 补充；
+// avg_lat 仅在内核报告 io.latency 统计时存在且不区分读写，不可用时延迟保持为0；只在监控循环中调用
+func (m *SmartLimitManager) fillCgroupLatency(containerID string, stats *kubeclient.IOStats) {
+	if m.config.CgroupVersion != "v2" {
+		return
+	}
+	path := m.containerCgroup(containerID)
+	if path == "" {
+		return
+	}
+	majMin := m.dataDiskMajMin()
+	if majMin == "" {
+		return
+	}
+	stat, err := cgroup.ReadIOStat(path, majMin)
+	if err != nil {
+		log.Printf("Failed to read io.stat of container %s: %v", containerID, err)
+		return
+	}
+	m.mu.Lock()
+	m.dataDiskUsers[containerID] = stat.ReadIOs+stat.WriteIOs > 0
+	m.mu.Unlock()
+	if stats.ReadLatency == 0 && stats.WriteLatency == 0 {
+		stats.ReadLatency, stats.WriteLatency = int64(stat.AvgLatency), int64(stat.AvgLatency)
+	}
+}
+
+// containerCgroup 返回容器的 cgroup v2 目录，首次查找后缓存（包括未找到），容器ID无法解析时返回空字符串
+func (m *SmartLimitManager) containerCgroup(containerID string) string {
+	// 未能映射到容器ID时使用 namespace/pod/container 作为键，无法定位 cgroup
+	if strings.Contains(containerID, "/") {
+		return ""
+	}
+	m.mu.RLock()
+	path, ok := m.cgroupPaths[containerID]
+	m.mu.RUnlock()
+	if ok {
+		return path
+	}
+	path, err := cgroup.FindContainerCgroup(cgroupRoot, containerID)
+	if err != nil {
+		log.Printf("Failed to find cgroup of container %s: %v", containerID, err)
+	}
+	m.mu.Lock()
+	m.cgroupPaths[containerID] = path
+	m.mu.Unlock()
+	return path
+}
@@ -40,23 +40,36 @@ func (m *SmartLimitManager) AnalyzeContainerTrend(stats []*kubeclient.IOStats) *
 
 	now := time.Now()
 	intervals := []struct {
-		duration  time.Duration
-		readIOPS  *float64
-		writeIOPS *float64
-		readBPS   *float64
-		writeBPS  *float64
+		name         string
+		duration     time.Duration
+		readIOPS     *float64
+		writeIOPS    *float64
+		readBPS      *float64
+		writeBPS     *float64
+		readLatency  *float64
+		writeLatency *float64
 	}{
-		{15 * time.Minute, &trend.ReadIOPS15m, &trend.WriteIOPS15m, &trend.ReadBPS15m, &trend.WriteBPS15m},
-		{30 * time.Minute, &trend.ReadIOPS30m, &trend.WriteIOPS30m, &trend.ReadBPS30m, &trend.WriteBPS30m},
-		{60 * time.Minute, &trend.ReadIOPS60m, &trend.WriteIOPS60m, &trend.ReadBPS60m, &trend.WriteBPS60m},
+		{"15m", 15 * time.Minute, &trend.ReadIOPS15m, &trend.WriteIOPS15m, &trend.ReadBPS15m, &trend.WriteBPS15m, &trend.ReadLatency15m, &trend.WriteLatency15m},
+		{"30m", 30 * time.Minute, &trend.ReadIOPS30m, &trend.WriteIOPS30m, &trend.ReadBPS30m, &trend.WriteBPS30m, &trend.ReadLatency30m, &trend.WriteLatency30m},
+		{"60m", 60 * time.Minute, &trend.ReadIOPS60m, &trend.WriteIOPS60m, &trend.ReadBPS60m, &trend.WriteBPS60m, &trend.ReadLatency60m, &trend.WriteLatency60m},
 	}
+	trend.Percentiles = make(map[string]*WindowPercentiles, len(intervals))
 
 	for _, interval := range intervals {
 		cutoff := now.Add(-interval.duration)
 		var totalReadIOPS, totalWriteIOPS, totalReadBPS, totalWriteBPS int64
+		var readIOPSRates, writeIOPSRates, readBPSRates, writeBPSRates []float64
+		var readLatencies, writeLatencies []float64
 		var count int
 		for i := 1; i < len(stats); i++ {
 			if stats[i].Timestamp.After(cutoff) {
+				// 延迟是采集间隔内的平均值（微秒），不做差分，换算为毫秒；为0表示数据源未报告
+				if stats[i].ReadLatency > 0 {
+					readLatencies = append(readLatencies, float64(stats[i].ReadLatency)/1000)
+				}
+				if stats[i].WriteLatency > 0 {
+					writeLatencies = append(writeLatencies, float64(stats[i].WriteLatency)/1000)
+				}
 				readIOPS := stats[i].ReadIOPS - stats[i-1].ReadIOPS
 				writeIOPS := stats[i].WriteIOPS - stats[i-1].WriteIOPS
 				readBPS := stats[i].ReadBPS - stats[i-1].ReadBPS
@@ -67,6 +80,10 @@ func (m *SmartLimitManager) AnalyzeContainerTrend(stats []*kubeclient.IOStats) *
 					totalWriteIOPS += int64(float64(writeIOPS) / timeDiff)
 					totalReadBPS += int64(float64(readBPS) / timeDiff)
 					totalWriteBPS += int64(float64(writeBPS) / timeDiff)
+					readIOPSRates = append(readIOPSRates, float64(readIOPS)/timeDiff)
+					writeIOPSRates = append(writeIOPSRates, float64(writeIOPS)/timeDiff)
+					readBPSRates = append(readBPSRates, float64(readBPS)/timeDiff)
+					writeBPSRates = append(writeBPSRates, float64(writeBPS)/timeDiff)
 					count++
 				}
 			}
@@ -76,6 +93,16 @@ func (m *SmartLimitManager) AnalyzeContainerTrend(stats []*kubeclient.IOStats) *
 			*interval.writeIOPS = float64(totalWriteIOPS) / float64(count)
 			*interval.readBPS = float64(totalReadBPS) / float64(count)
 			*interval.writeBPS = float64(totalWriteBPS) / float64(count)
+			*interval.readLatency = mean(readLatencies)
+			*interval.writeLatency = mean(writeLatencies)
+			trend.Percentiles[interval.name] = &WindowPercentiles{
+				ReadIOPS:     percentiles(readIOPSRates),
+				WriteIOPS:    percentiles(writeIOPSRates),
+				ReadBPS:      percentiles(readBPSRates),
+				WriteBPS:     percentiles(writeBPSRates),
+				ReadLatency:  percentiles(readLatencies),
+				WriteLatency: percentiles(writeLatencies),
+			}
 		}
 	}
 	return trend
@@ -1,9 +1,13 @@
 package smartlimit
 
 import (
+	"KubeDiskGuard/pkg/annotationkeys"
 	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
 	"log"
 	"time"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 // collectIOStats 收集IO统计信息
@@ -34,6 +38,25 @@ func (m *SmartLimitManager) collectIOStatsFromKubelet() {
 		return
 	}
 
+	// summary 中只有容器名，通过Pod列表映射到真实容器ID，避免不同Pod的同名容器相互覆盖
+	containerIDs := make(map[string]string)
+	podsByKey := make(map[string]*corev1.Pod)
+	if pods, err := m.kubeClient.ListNodePodsWithKubeletFirst(); err == nil {
+		for i := range pods {
+			pod := &pods[i]
+			podsByKey[pod.Namespace+"/"+pod.Name] = pod
+			for _, cs := range pod.Status.ContainerStatuses {
+				if cs.ContainerID != "" {
+					containerIDs[pod.Namespace+"/"+pod.Name+"/"+cs.Name] = parseContainerID(cs.ContainerID)
+				}
+			}
+		}
+	} else {
+		log.Printf("Failed to list node pods for container ID mapping: %v", err)
+	}
+
+	// summary 不含IO延迟，每轮从cAdvisor获取一次累积加权IO时间计算延迟
+	ioTime := m.cadvisorIOTime()
 	containerCount := 0
 	for _, podStats := range summary.Pods {
 		podName := podStats.PodRef.Name
@@ -48,17 +71,26 @@ func (m *SmartLimitManager) collectIOStatsFromKubelet() {
 			if containerStats.DiskIO == nil {
 				continue
 			}
+			containerID, ok := containerIDs[namespace+"/"+podName+"/"+containerStats.Name]
+			if !ok {
+				containerID = namespace + "/" + podName + "/" + containerStats.Name
+			}
 			stats := &kubeclient.IOStats{
-				ContainerID: containerStats.Name,
+				ContainerID: containerID,
 				Timestamp:   containerStats.Timestamp,
 				ReadIOPS:    int64(containerStats.DiskIO.ReadIOPS),
 				WriteIOPS:   int64(containerStats.DiskIO.WriteIOPS),
 				ReadBPS:     int64(containerStats.DiskIO.ReadBytes),
 				WriteBPS:    int64(containerStats.DiskIO.WriteBytes),
 			}
-			log.Printf("[DEBUG] Adding IO stats for container %s: ReadIOPS=%d, WriteIOPS=%d, ReadBPS=%d, WriteBPS=%d", 
-				containerStats.Name, stats.ReadIOPS, stats.WriteIOPS, stats.ReadBPS, stats.WriteBPS)
-			m.addIOStats(containerStats.Name, podName, namespace, stats)
+			m.fillLatency(containerID, stats, ioTime)
+			log.Printf("[DEBUG] Adding IO stats for container %s: ReadIOPS=%d, WriteIOPS=%d, ReadBPS=%d, WriteBPS=%d, ReadLatency=%dus, WriteLatency=%dus",
+				containerStats.Name, stats.ReadIOPS, stats.WriteIOPS, stats.ReadBPS, stats.WriteBPS, stats.ReadLatency, stats.WriteLatency)
+			var policy *limits.SmartLimitPolicy
+			if pod, ok := podsByKey[namespace+"/"+podName]; ok {
+				policy = m.smartLimitPolicy(pod, containerStats.Name)
+			}
+			m.addIOStats(containerID, containerStats.Name, podName, namespace, policy, stats)
 			containerCount++
 		}
 	}
@@ -91,7 +123,8 @@ func (m *SmartLimitManager) collectIOStatsFromCadvisor() {
 		return
 	}
 
-	for _, pod := range pods {
+	for i := range pods {
+		pod := pods[i]
 		if !m.shouldMonitorPod(pod) {
 			continue
 		}
@@ -103,14 +136,39 @@ func (m *SmartLimitManager) collectIOStatsFromCadvisor() {
 			containerID := parseContainerID(container.ContainerID)
 			stats := m.kubeClient.ConvertCadvisorToIOStats(parsedMetrics, containerID)
 			if stats != nil {
-				m.addIOStats(containerID, pod.Name, pod.Namespace, stats)
+				m.fillCgroupLatency(containerID, stats)
+				m.addIOStats(containerID, container.Name, pod.Name, pod.Namespace, m.smartLimitPolicy(&pod, container.Name), stats)
 			}
 		}
 	}
 }
 
-// addIOStats 添加IO统计信息到历史记录
-func (m *SmartLimitManager) addIOStats(containerID, podName, namespace string, stats *kubeclient.IOStats) {
+// smartLimitPolicy 返回容器的智能限速策略：按 容器级 > Pod级 的优先级取引用的命名配置中设置的策略，
+// 都未设置时使用命中的限速等级
+func (m *SmartLimitManager) smartLimitPolicy(pod *corev1.Pod, containerName string) *limits.SmartLimitPolicy {
+	for _, key := range []string{m.containerAnnotationKey(containerName, annotationkeys.ProfileAnnotationKey),
+		m.config.SmartLimitAnnotationPrefix + "/" + annotationkeys.ProfileAnnotationKey} {
+		if name, ok := pod.Annotations[key]; ok {
+			if profile := m.limitProfiles.Get(name); profile != nil && profile.SmartLimit != nil {
+				return profile.SmartLimit
+			}
+		}
+	}
+	return m.limitClasses.Match(pod).Policy()
+}
+
+// addIOStats 添加IO统计信息到历史记录，并刷新Pod的智能限速策略；
+// 采集结果和更新后的基线在释放锁后一次写入本地存储
+func (m *SmartLimitManager) addIOStats(containerID, containerName, podName, namespace string, policy *limits.SmartLimitPolicy, stats *kubeclient.IOStats) {
+	records := []pendingRecord{{storeKindStats, containerID, storedStats{ContainerName: containerName, PodName: podName, Namespace: namespace, Stats: stats}}}
+	if baseline := m.appendIOStats(containerID, containerName, podName, namespace, policy, stats); baseline != nil {
+		records = append(records, pendingRecord{storeKindBaseline, containerID, baseline})
+	}
+	m.persistAll(records...)
+}
+
+// appendIOStats 在锁内追加采集结果、更新基线并清理过期数据，返回更新后的基线副本
+func (m *SmartLimitManager) appendIOStats(containerID, containerName, podName, namespace string, policy *limits.SmartLimitPolicy, stats *kubeclient.IOStats) *ContainerBaseline {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -118,10 +176,11 @@ func (m *SmartLimitManager) addIOStats(containerID, podName, namespace string, s
 	if !exists {
 		log.Printf("[DEBUG] Creating new history for container %s (pod: %s/%s)", containerID, namespace, podName)
 		history = &ContainerIOHistory{
-			ContainerID: containerID,
-			PodName:     podName,
-			Namespace:   namespace,
-			Stats:       make([]*kubeclient.IOStats, 0),
+			ContainerID:   containerID,
+			ContainerName: containerName,
+			PodName:       podName,
+			Namespace:     namespace,
+			Stats:         make([]*kubeclient.IOStats, 0),
 		}
 		m.history[containerID] = history
 	}
@@ -131,10 +190,13 @@ func (m *SmartLimitManager) addIOStats(containerID, podName, namespace string, s
 
 	history.Stats = append(history.Stats, stats)
 	history.LastUpdate = time.Now()
+	history.Policy = policy
+	baseline := m.updateBaseline(history)
 	log.Printf("[DEBUG] Added IO stats to container %s, total stats count: %d", containerID, len(history.Stats))
 
 	// 清理过期数据
 	m.cleanupContainerHistory(history)
+	return baseline
 }
 
 // cleanupContainerHistory 清理容器的历史数据
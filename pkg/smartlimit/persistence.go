@@ -0,0 +1,172 @@
+package smartlimit
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/store"
+)
+
+// 持久化记录类型
+const (
+	storeKindStats    = "stats"    // 一次IO采集结果
+	storeKindDecision = "decision" // 限速或解除限速后的状态
+	storeKindStatus   = "status"   // 压缩时写入的限速状态检查点
+	storeKindBaseline = "baseline" // 异常检测的容器IO基线，压缩时同样写入检查点
+)
+
+// storedStats 持久化的IO采集结果及所属容器
+type storedStats struct {
+	ContainerName string              `json:"container_name"`
+	PodName       string              `json:"pod_name"`
+	Namespace     string              `json:"namespace"`
+	Stats         *kubeclient.IOStats `json:"stats"`
+}
+
+// historyWindow 历史数据保留窗口
+func (m *SmartLimitManager) historyWindow() time.Duration {
+	return time.Duration(m.config.SmartLimitHistoryWindow) * time.Minute
+}
+
+// openStore 打开本地存储并回放历史窗口内的采集数据和限速状态，未配置存储目录时不持久化
+func (m *SmartLimitManager) openStore() {
+	dir := m.config.SmartLimitStoreDir
+	if dir == "" {
+		return
+	}
+	// 每个段覆盖四分之一个历史窗口，压缩时最多多保留一个段
+	st, err := store.Open(dir, m.historyWindow()/4)
+	if err != nil {
+		log.Printf("Failed to open smart limit store, history will not be persisted: %v", err)
+		return
+	}
+	m.store = st
+	stats, statuses := m.replayStore()
+	log.Printf("Replayed smart limit store %s: %d IO stats, %d limit statuses, %d baselines", dir, stats, statuses, len(m.baselines))
+	// 回放后立即写入检查点，回放出的限速状态不会因决策记录超出历史窗口而在下次重启时丢失
+	m.compactStore()
+}
+
+// replayStore 回放历史窗口内的记录，同一容器的限速状态和基线以最后一条为准；
+// 基线需要长期学习，不受历史窗口限制
+func (m *SmartLimitManager) replayStore() (int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := 0
+	cutoff := time.Now().Add(-m.historyWindow())
+	err := m.store.Replay(time.Time{}, func(rec store.Record) {
+		if rec.Kind == storeKindBaseline {
+			b := &ContainerBaseline{}
+			if err := json.Unmarshal(rec.Data, b); err == nil && b.ContainerID != "" {
+				m.baselines[b.ContainerID] = b
+			}
+			return
+		}
+		if rec.Time.Before(cutoff) {
+			return
+		}
+		switch rec.Kind {
+		case storeKindStats:
+			var s storedStats
+			if err := json.Unmarshal(rec.Data, &s); err != nil || s.Stats == nil {
+				return
+			}
+			history, exists := m.history[rec.Key]
+			if !exists {
+				history = &ContainerIOHistory{
+					ContainerID:   rec.Key,
+					ContainerName: s.ContainerName,
+					PodName:       s.PodName,
+					Namespace:     s.Namespace,
+				}
+				m.history[rec.Key] = history
+			}
+			history.Stats = append(history.Stats, s.Stats)
+			history.LastUpdate = rec.Time
+			stats++
+		case storeKindDecision, storeKindStatus:
+			status := &LimitStatus{}
+			if err := json.Unmarshal(rec.Data, status); err != nil || status.ContainerID == "" {
+				return
+			}
+			m.limitStatus[status.ContainerID] = status
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to replay smart limit store: %v", err)
+	}
+	return stats, len(m.limitStatus)
+}
+
+// pendingRecord 待写入本地存储的记录
+type pendingRecord struct {
+	kind  string
+	key   string
+	value interface{}
+}
+
+// persist 追加记录到本地存储，未启用存储时忽略
+func (m *SmartLimitManager) persist(kind, key string, value interface{}) {
+	m.persistAll(pendingRecord{kind, key, value})
+}
+
+// persistAll 编码后一次追加多条记录，调用方不应持有 m.mu 或 history 锁，避免写盘阻塞采集和分析
+func (m *SmartLimitManager) persistAll(pending ...pendingRecord) {
+	if m.store == nil {
+		return
+	}
+	records := make([]store.Record, 0, len(pending))
+	for _, p := range pending {
+		data, err := json.Marshal(p.value)
+		if err != nil {
+			log.Printf("Failed to encode smart limit %s record for %s: %v", p.kind, p.key, err)
+			continue
+		}
+		records = append(records, store.Record{Kind: p.kind, Key: p.key, Data: data})
+	}
+	if err := m.store.Append(records...); err != nil {
+		log.Printf("Failed to persist %d smart limit records: %v", len(records), err)
+	}
+}
+
+// compactStore 删除超出历史窗口的段，并把当前限速状态和基线写为检查点，避免长期未变化的状态随旧段一起删除；
+// 除定期压缩外，打开存储完成回放后和停止时也会执行
+func (m *SmartLimitManager) compactStore() {
+	if m.store == nil {
+		return
+	}
+	var checkpoint []store.Record
+	for containerID, status := range m.GetAllLimitStatus() {
+		data, err := json.Marshal(status)
+		if err != nil {
+			continue
+		}
+		checkpoint = append(checkpoint, store.Record{Kind: storeKindStatus, Key: containerID, Data: data})
+	}
+	for containerID, b := range m.GetAllBaselines() {
+		data, err := json.Marshal(b)
+		if err != nil {
+			continue
+		}
+		checkpoint = append(checkpoint, store.Record{Kind: storeKindBaseline, Key: containerID, Data: data})
+	}
+	removed, err := m.store.Compact(time.Now().Add(-m.historyWindow()), checkpoint)
+	if err != nil {
+		log.Printf("Failed to compact smart limit store: %v", err)
+		return
+	}
+	log.Printf("Compacted smart limit store: removed %d segments, checkpointed %d records", removed, len(checkpoint))
+}
+
+// closeStore 关闭本地存储
+func (m *SmartLimitManager) closeStore() {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.Close(); err != nil {
+		log.Printf("Failed to close smart limit store: %v", err)
+	}
+}
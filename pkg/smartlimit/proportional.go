@@ -0,0 +1,64 @@
+package smartlimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 限速值计算方式
+const (
+	LimitModeFixed        = "fixed"        // 使用各窗口配置的固定限速值
+	LimitModeProportional = "proportional" // 按观测速率的比例计算限速值
+)
+
+// proportional 是否按观测速率比例计算限速值
+func (m *SmartLimitManager) proportional() bool {
+	return m.config.SmartLimitLimitMode == LimitModeProportional
+}
+
+// windowLimitResult 构建窗口触发后的限速结果，只限制超过阈值的方向（read/write），
+// 固定模式使用该窗口的配置值，比例模式按观测速率计算
+func (m *SmartLimitManager) windowLimitResult(w windowConfig, read, write bool, readIOPS, writeIOPS, readBPS, writeBPS float64) *LimitResult {
+	reason := fmt.Sprintf("%s，限速方向:%s", m.buildTriggerReason(w.name, readIOPS, writeIOPS, readBPS, writeBPS), directionLabel(read, write))
+	result := &LimitResult{TriggeredBy: w.name, Read: read, Write: write, Reason: reason}
+	if !m.proportional() {
+		if read {
+			result.ReadIOPS, result.ReadBPS = w.readIOPSLimit, w.readBPSLimit
+		}
+		if write {
+			result.WriteIOPS, result.WriteBPS = w.writeIOPSLimit, w.writeBPSLimit
+		}
+		return result
+	}
+
+	var steps []string
+	scale := func(name string, observed, fraction float64, floor, max int) int {
+		value := proportionalLimit(observed, fraction, floor, max)
+		steps = append(steps, fmt.Sprintf("%s:%.2f*%.2f=%d", name, observed, fraction, value))
+		return value
+	}
+	if read {
+		result.ReadIOPS = scale("ReadIOPS", readIOPS, m.config.SmartLimitReadIOPSFraction, m.config.SmartLimitProportionalMinIOPS, m.config.MaxIOPSLimit)
+		result.ReadBPS = scale("ReadBPS", readBPS, m.config.SmartLimitReadBPSFraction, m.config.SmartLimitProportionalMinBPS, m.config.MaxBPSLimit)
+	}
+	if write {
+		result.WriteIOPS = scale("WriteIOPS", writeIOPS, m.config.SmartLimitWriteIOPSFraction, m.config.SmartLimitProportionalMinIOPS, m.config.MaxIOPSLimit)
+		result.WriteBPS = scale("WriteBPS", writeBPS, m.config.SmartLimitWriteBPSFraction, m.config.SmartLimitProportionalMinBPS, m.config.MaxBPSLimit)
+	}
+	result.Reason = fmt.Sprintf("%s，按比例限速[%s]（下限IOPS:%d,BPS:%d，上限IOPS:%d,BPS:%d）", reason, strings.Join(steps, ","),
+		m.config.SmartLimitProportionalMinIOPS, m.config.SmartLimitProportionalMinBPS, m.config.MaxIOPSLimit, m.config.MaxBPSLimit)
+	return result
+}
+
+// proportionalLimit 观测速率乘以比例后限制在 [floor, max] 之间，max<=0 表示不设上限
+func proportionalLimit(observed, fraction float64, floor, max int) int {
+	value := int(math.Round(observed * fraction))
+	if value < floor {
+		value = floor
+	}
+	if max > 0 && value > max {
+		value = max
+	}
+	return value
+}
@@ -0,0 +1,118 @@
+package smartlimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"KubeDiskGuard/pkg/audit"
+)
+
+// shouldRelax 判断满足解除条件时是否先放宽一级而不是直接解除：
+// 启用逐级放宽、仍有可放宽的级数，且限速值来自分级结果（固定自动限速值无法按倍数放宽）
+func (m *SmartLimitManager) shouldRelax(limitStatus *LimitStatus) bool {
+	if m.config.SmartLimitRelaxFactor <= 1 || m.config.SmartLimitRelaxSteps <= 0 {
+		return false
+	}
+	limitStatus.mu.RLock()
+	defer limitStatus.mu.RUnlock()
+	return limitStatus.LimitResult != nil && limitStatus.RelaxStep < m.config.SmartLimitRelaxSteps
+}
+
+// appliedResult 返回实际写入注解的限速值，早期版本未记录时使用限速结果
+func (s *LimitStatus) appliedResult() *LimitResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.Applied != nil {
+		return s.Applied
+	}
+	return s.LimitResult
+}
+
+// relaxing 判断容器是否处于放宽过程中
+func (s *LimitStatus) relaxing() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.IsLimited && s.RelaxStep > 0
+}
+
+// flapCooldown 按抖动次数计算开始放宽前的冷却时间：基数 × 2^(次数-1)，不超过上限
+func (m *SmartLimitManager) flapCooldown(flaps int) time.Duration {
+	if flaps <= 0 || m.config.SmartLimitFlapCooldown <= 0 {
+		return 0
+	}
+	cooldown := float64(m.config.SmartLimitFlapCooldown) * math.Pow(2, float64(flaps-1))
+	if ceiling := float64(m.config.SmartLimitFlapCooldownMax); ceiling > 0 && cooldown > ceiling {
+		cooldown = ceiling
+	}
+	return time.Duration(cooldown * float64(time.Minute))
+}
+
+// countFlap 重新限速时更新抖动次数，调用方需持有 limitStatus 的写锁：
+// 放宽过程中再次触发，或解除后 SmartLimitFlapWindow 内再次触发计为一次抖动，解除后长时间未触发则清零
+func (m *SmartLimitManager) countFlap(limitStatus *LimitStatus, now time.Time) {
+	flapWindow := time.Duration(m.config.SmartLimitFlapWindow) * time.Minute
+	switch {
+	case limitStatus.IsLimited && limitStatus.RelaxStep > 0:
+		limitStatus.Flaps++
+	case !limitStatus.IsLimited && !limitStatus.RemovedAt.IsZero() && now.Sub(limitStatus.RemovedAt) < flapWindow:
+		limitStatus.Flaps++
+	case !limitStatus.IsLimited:
+		limitStatus.Flaps = 0
+	}
+}
+
+// relaxValue 放宽单项限速值，0（未设置）保持不变
+func relaxValue(value int, factor float64) int {
+	if value <= 0 {
+		return value
+	}
+	return int(math.Ceil(float64(value) * factor))
+}
+
+// relaxSmartLimit 把容器实际生效的限速值放宽一级，不重置限速时间，下一级在下一个检查间隔后继续判断；
+// 写入注解失败时不更新限速状态，下一个检查间隔重试；dry-run 只审计，不推进放宽级数
+func (m *SmartLimitManager) relaxSmartLimit(history *ContainerIOHistory, trend *IOTrend, limitStatus *LimitStatus) {
+	removeReason := m.buildRemoveReason(trend, limitStatus)
+	factor, steps := m.config.SmartLimitRelaxFactor, m.config.SmartLimitRelaxSteps
+
+	current := limitStatus.appliedResult()
+	limitStatus.mu.RLock()
+	step := limitStatus.RelaxStep + 1
+	limitStatus.mu.RUnlock()
+	relaxed := &LimitResult{
+		TriggeredBy: current.TriggeredBy,
+		ReadIOPS:    relaxValue(current.ReadIOPS, factor),
+		WriteIOPS:   relaxValue(current.WriteIOPS, factor),
+		ReadBPS:     relaxValue(current.ReadBPS, factor),
+		WriteBPS:    relaxValue(current.WriteBPS, factor),
+		Read:        current.Read,
+		Write:       current.Write,
+		Reason:      fmt.Sprintf("逐级放宽限速[%d/%d,x%.2f]，%s", step, steps, factor, removeReason),
+	}
+	applied, err := m.applySmartLimitAction(audit.ActionRelaxSmartLimit, history, relaxed)
+	var snapshot *LimitStatus
+	limitStatus.mu.Lock()
+	limitStatus.LastCheckAt = time.Now()
+	if err == nil && !m.config.DryRun {
+		limitStatus.LimitResult = relaxed
+		limitStatus.Applied = applied
+		limitStatus.RelaxStep = step
+		snapshot = limitStatus.copyLocked()
+	}
+	limitStatus.mu.Unlock()
+	if snapshot != nil {
+		m.persist(storeKindDecision, history.ContainerID, snapshot)
+	}
+	if err != nil {
+		log.Printf("Failed to relax smart limit for container %s: %v", history.ContainerID, err)
+		return
+	}
+	if m.config.DryRun {
+		return
+	}
+
+	log.Printf("Relaxed smart limit for container %s (%d/%d): %s", history.ContainerID, step, steps, relaxed.Reason)
+	m.createEvent(history, "SmartLimitRelaxed", "容器"+history.ContainerName+"限速放宽: "+relaxed.Reason)
+}
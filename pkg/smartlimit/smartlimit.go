@@ -1,6 +1,7 @@
 package smartlimit
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
@@ -10,35 +11,51 @@ import (
 	"time"
 
 	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/audit"
 	"KubeDiskGuard/pkg/cgroup"
 	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/device"
 	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
+	"KubeDiskGuard/pkg/store"
 )
 
 // ContainerIOHistory 容器IO历史记录
 type ContainerIOHistory struct {
-	ContainerID string
-	PodName     string
-	Namespace   string
-	Stats       []*kubeclient.IOStats
-	LastUpdate  time.Time
-	mu          sync.RWMutex
+	ContainerID   string
+	ContainerName string
+	PodName       string
+	Namespace     string
+	Stats         []*kubeclient.IOStats
+	LastUpdate    time.Time
+	Policy        *limits.SmartLimitPolicy // Pod的智能限速策略（命名配置或限速等级），每次采集时刷新
+	mu            sync.RWMutex
 }
 
-// IOTrend IO趋势分析结果
+// IOTrend IO趋势分析结果，各窗口的平均速率及速率分布
 type IOTrend struct {
-	ReadIOPS15m  float64
-	WriteIOPS15m float64
-	ReadBPS15m   float64
-	WriteBPS15m  float64
-	ReadIOPS30m  float64
-	WriteIOPS30m float64
-	ReadBPS30m   float64
-	WriteBPS30m  float64
-	ReadIOPS60m  float64
-	WriteIOPS60m float64
-	ReadBPS60m   float64
-	WriteBPS60m  float64
+	ReadIOPS15m  float64 `json:"read_iops_15m"`
+	WriteIOPS15m float64 `json:"write_iops_15m"`
+	ReadBPS15m   float64 `json:"read_bps_15m"`
+	WriteBPS15m  float64 `json:"write_bps_15m"`
+	ReadIOPS30m  float64 `json:"read_iops_30m"`
+	WriteIOPS30m float64 `json:"write_iops_30m"`
+	ReadBPS30m   float64 `json:"read_bps_30m"`
+	WriteBPS30m  float64 `json:"write_bps_30m"`
+	ReadIOPS60m  float64 `json:"read_iops_60m"`
+	WriteIOPS60m float64 `json:"write_iops_60m"`
+	ReadBPS60m   float64 `json:"read_bps_60m"`
+	WriteBPS60m  float64 `json:"write_bps_60m"`
+
+	// 各窗口的平均IO延迟（毫秒），只统计报告了延迟的采样，数据源不提供延迟时为0
+	ReadLatency15m  float64 `json:"read_latency_15m"`
+	WriteLatency15m float64 `json:"write_latency_15m"`
+	ReadLatency30m  float64 `json:"read_latency_30m"`
+	WriteLatency30m float64 `json:"write_latency_30m"`
+	ReadLatency60m  float64 `json:"read_latency_60m"`
+	WriteLatency60m float64 `json:"write_latency_60m"`
+
+	Percentiles map[string]*WindowPercentiles `json:"percentiles,omitempty"` // 窗口（15m/30m/60m）内各采集间隔速率和延迟的分位数和最大值
 }
 
 // LimitResult 限速结果
@@ -48,20 +65,68 @@ type LimitResult struct {
 	WriteIOPS   int    // 建议的写IOPS限速值
 	ReadBPS     int    // 建议的读BPS限速值
 	WriteBPS    int    // 建议的写BPS限速值
+	Read        bool   // 读方向触发限速
+	Write       bool   // 写方向触发限速
 	Reason      string // 触发原因
+	// 满足的统计量触发条件（阈值已按策略倍数调整）
+	Triggers []limits.Trigger
 }
 
 // LimitStatus 限速状态
 type LimitStatus struct {
-	ContainerID string
-	PodName     string
-	Namespace   string
-	IsLimited   bool
-	TriggeredBy string
-	LimitResult *LimitResult
-	AppliedAt   time.Time
-	LastCheckAt time.Time
-	mu          sync.RWMutex
+	ContainerID   string
+	ContainerName string
+	PodName       string
+	Namespace     string
+	IsLimited     bool
+	TriggeredBy   string
+	LimitResult   *LimitResult
+	Applied       *LimitResult // 实际写入注解的限速值（含容器限额回退和全局最大值约束），逐级放宽和解除以此为准
+	AppliedAt     time.Time
+	LastCheckAt   time.Time
+	RelaxStep     int       // 已放宽的级数，0表示完整限速
+	Flaps         int       // 抖动次数（放宽中或解除后不久再次触发），决定开始放宽前的冷却时间
+	RemovedAt     time.Time // 最近一次解除限速的时间
+	// 本次限速满足的统计量触发条件，解除限速时要求这些统计量回落到阈值以下，避免按均值解除后再次触发
+	Triggers []limits.Trigger
+	mu       sync.RWMutex
+}
+
+// clone 深拷贝限速结果，nil 返回nil
+func (r *LimitResult) clone() *LimitResult {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.Triggers = append([]limits.Trigger(nil), r.Triggers...)
+	return &c
+}
+
+// copyStatus 返回限速状态的副本，供API、检查点和交接快照使用，避免并发问题
+func (s *LimitStatus) copyStatus() *LimitStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.copyLocked()
+}
+
+// copyLocked 同 copyStatus，调用方需持有 s.mu
+func (s *LimitStatus) copyLocked() *LimitStatus {
+	return &LimitStatus{
+		ContainerID:   s.ContainerID,
+		ContainerName: s.ContainerName,
+		PodName:       s.PodName,
+		Namespace:     s.Namespace,
+		IsLimited:     s.IsLimited,
+		TriggeredBy:   s.TriggeredBy,
+		LimitResult:   s.LimitResult.clone(),
+		Applied:       s.Applied.clone(),
+		AppliedAt:     s.AppliedAt,
+		LastCheckAt:   s.LastCheckAt,
+		RelaxStep:     s.RelaxStep,
+		Flaps:         s.Flaps,
+		RemovedAt:     s.RemovedAt,
+		Triggers:      append([]limits.Trigger(nil), s.Triggers...),
+	}
 }
 
 // ContainerLimit 容器限额结构体
@@ -76,10 +141,24 @@ type SmartLimitManager struct {
 	kubeClient      kubeclient.IKubeClient
 	cgroupMgr       *cgroup.Manager
 	history         map[string]*ContainerIOHistory
-	limitStatus     map[string]*LimitStatus    // 限速状态跟踪
-	containerLimits map[string]*ContainerLimit // containerID -> 限额
+	limitStatus     map[string]*LimitStatus       // 限速状态跟踪
+	baselines       map[string]*ContainerBaseline // 异常检测使用的容器IO基线
+	containerLimits map[string]*ContainerLimit    // containerID -> 限额
 	mu              sync.RWMutex
 	stopCh          chan struct{}
+	stopOnce        sync.Once
+	closeOnce       sync.Once      // 写入检查点并关闭本地存储，只执行一次
+	wg              sync.WaitGroup // 跟踪后台goroutine，Stop时等待进行中的工作完成
+	audit           *audit.Recorder
+	limitClasses    *limits.ClassSet   // 按QoS/PriorityClass映射的限速等级，决定是否豁免及阈值倍数
+	limitProfiles   *limits.ProfileSet // 命名限速配置，Pod引用的配置中的智能限速策略优先于限速等级
+	triggers        []limits.Trigger   // 全局的窗口统计量触发条件，Pod策略设置了触发条件时替换
+	store           *store.Store       // 采集数据和限速状态的本地存储，重启后回放，为nil时不持久化
+	deviceCollector *device.Collector  // 数据盘负载采集器，启用争用判断时在监控循环中创建
+	deviceUsage     *device.Usage      // 最近一次采样的数据盘负载
+	dataDisk        string             // 数据盘设备号 major:minor，首次使用时解析
+	cgroupPaths     map[string]string  // containerID -> cgroup v2 目录，读取 io.stat 延迟时查找并缓存，未找到时为空
+	dataDiskUsers   map[string]bool    // containerID -> io.stat 中是否有数据盘的IO，争用判断只比较同一数据盘上的容器
 }
 
 // NewSmartLimitManager 创建智能限速管理器
@@ -90,13 +169,66 @@ func NewSmartLimitManager(config *config.Config, kubeClient kubeclient.IKubeClie
 		cgroupMgr:       cgroupMgr,
 		history:         make(map[string]*ContainerIOHistory),
 		limitStatus:     make(map[string]*LimitStatus),
+		baselines:       make(map[string]*ContainerBaseline),
 		stopCh:          make(chan struct{}),
 		containerLimits: make(map[string]*ContainerLimit),
+		cgroupPaths:     make(map[string]string),
+		dataDiskUsers:   make(map[string]bool),
 	}
 }
 
-// Start 启动智能限速管理器
-func (m *SmartLimitManager) Start() {
+// SetAuditRecorder 设置限速动作审计记录器
+func (m *SmartLimitManager) SetAuditRecorder(recorder *audit.Recorder) {
+	m.audit = recorder
+}
+
+// SetLimitClasses 设置限速等级，命中豁免等级的Pod不会被自动限速
+func (m *SmartLimitManager) SetLimitClasses(classes *limits.ClassSet) {
+	m.limitClasses = classes
+}
+
+// SetLimitProfiles 设置命名限速配置
+func (m *SmartLimitManager) SetLimitProfiles(profiles *limits.ProfileSet) {
+	m.limitProfiles = profiles
+}
+
+// SetTriggers 设置全局的窗口统计量触发条件
+func (m *SmartLimitManager) SetTriggers(triggers []limits.Trigger) {
+	m.triggers = triggers
+}
+
+// recordAudit 记录一次智能限速动作
+func (m *SmartLimitManager) recordAudit(action string, history *ContainerIOHistory, readIOPS, writeIOPS, readBPS, writeBPS int, reason string, err error) {
+	rec := audit.Record{
+		Source:        audit.SourceSmartLimit,
+		Action:        action,
+		DryRun:        m.config.DryRun,
+		Namespace:     history.Namespace,
+		PodName:       history.PodName,
+		ContainerName: history.ContainerName,
+		ContainerID:   history.ContainerID,
+		ReadIOPS:      readIOPS,
+		WriteIOPS:     writeIOPS,
+		ReadBPS:       readBPS,
+		WriteBPS:      writeBPS,
+		Reason:        reason,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	m.audit.Record(rec)
+}
+
+// createEvent 记录Pod事件，dry-run 模式下不写入
+func (m *SmartLimitManager) createEvent(history *ContainerIOHistory, reason, message string) {
+	if m.config.DryRun {
+		return
+	}
+	_ = m.kubeClient.CreateEvent(history.Namespace, history.PodName, "Normal", reason, message)
+}
+
+// Start 启动智能限速管理器，ctx 取消时自动停止
+func (m *SmartLimitManager) Start(ctx context.Context) {
 	if !m.config.SmartLimitEnabled {
 		log.Println("Smart limit is disabled")
 		return
@@ -104,25 +236,61 @@ func (m *SmartLimitManager) Start() {
 
 	log.Println("Starting smart limit manager...")
 
-	// 恢复限速状态
-	go m.restoreLimitStatus()
+	// 回放本地存储的历史数据和限速状态，再从注解补全存储中没有的容器
+	m.openStore()
+	m.goRun(m.restoreLimitStatus)
 
 	// 启动监控循环
-	go m.monitorLoop()
+	m.goRun(m.monitorLoop)
 
 	// 启动清理循环
-	go m.cleanupLoop()
+	m.goRun(m.cleanupLoop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.Stop()
+		case <-m.stopCh:
+		}
+	}()
 
 	log.Println("Smart limit manager started")
 }
 
-// Stop 停止智能限速管理器
+// goRun 启动受WaitGroup跟踪的后台goroutine
+func (m *SmartLimitManager) goRun(fn func()) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		fn()
+	}()
+}
+
+// Stop 停止智能限速管理器，等待进行中的采集、限速和Pod注解更新完成，可重复调用
 func (m *SmartLimitManager) Stop() {
-	log.Println("Stopping smart limit manager...")
-	close(m.stopCh)
+	m.stopOnce.Do(func() {
+		log.Println("Stopping smart limit manager...")
+		close(m.stopCh)
+	})
+	m.wg.Wait()
+	m.closeOnce.Do(func() {
+		// 退出前写入检查点，重启后恢复的限速状态不依赖压缩时机
+		m.compactStore()
+		m.closeStore()
+	})
 	log.Println("Smart limit manager stopped")
 }
 
+// stopping 判断管理器是否正在停止
+func (m *SmartLimitManager) stopping() bool {
+	select {
+	case <-m.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
 // monitorLoop 监控循环
 func (m *SmartLimitManager) monitorLoop() {
 	interval := time.Duration(m.config.SmartLimitMonitorInterval) * time.Second
@@ -133,6 +301,7 @@ func (m *SmartLimitManager) monitorLoop() {
 		select {
 		case <-ticker.C:
 			m.collectIOStats()
+			m.sampleDevice()
 			m.analyzeAndLimit()
 		case <-m.stopCh:
 			return
@@ -149,6 +318,10 @@ func (m *SmartLimitManager) analyzeAndLimit() {
 // ApplyLimitIfNeeded 根据分析结果判断并执行限速
 func (m *SmartLimitManager) ApplyLimitIfNeeded(trends map[string]*IOTrend) {
 	for containerID, trend := range trends {
+		// 停止时不再发起新的Pod注解更新，已开始的更新会执行完
+		if m.stopping() {
+			return
+		}
 		m.applyLimitForContainer(containerID, trend)
 	}
 }
@@ -162,15 +335,36 @@ func (m *SmartLimitManager) applyLimitForContainer(containerID string, trend *IO
 		return
 	}
 	limitStatus := m.getLimitStatus(containerID)
-	shouldLimit, limitResult := m.shouldApplyLimitGraded(trend)
+	history.mu.RLock()
+	policy := history.Policy
+	history.mu.RUnlock()
+	shouldLimit, limitResult := m.detect(containerID, trend, policy)
+
+	// 设备争用判断：设备空闲或容器不是主要IO来源时不限速，已限速的容器仍按解除条件处理
+	if shouldLimit {
+		if contended, detail := m.checkContention(history); !contended {
+			log.Printf("Skipping smart limit for container %s, data disk is not contended: %s", containerID, detail)
+			shouldLimit, limitResult = false, nil
+		} else if detail != "" && limitResult != nil {
+			limitResult.Reason += "，" + detail
+		}
+	}
 
-	// 1. 需要解除限速
+	// 1. 需要解除限速（豁免的Pod立即解除，如配置变更前已被限速），启用逐级放宽时先逐级放宽再解除
 	if !shouldLimit && limitStatus != nil && limitStatus.IsLimited {
-		if m.shouldRemoveLimit(trend, limitStatus) {
-			m.removeSmartLimit(history.PodName, history.Namespace, trend, limitStatus)
-			m.updateLimitStatus(containerID, history.PodName, history.Namespace, false, nil)
+		exempt := policy.IsExempt()
+		release := exempt || m.shouldRemoveLimit(trend, limitStatus)
+		if release && !exempt && m.shouldRelax(limitStatus) {
+			m.relaxSmartLimit(history, trend, limitStatus)
+		} else if release {
+			m.removeSmartLimit(history, trend, limitStatus)
+			if m.config.DryRun {
+				// dry-run 只审计决策，不改变限速状态
+				return
+			}
+			m.updateLimitStatus(containerID, history.ContainerName, history.PodName, history.Namespace, false, nil, nil)
 			removeReason := m.buildRemoveReason(trend, limitStatus)
-			_ = m.kubeClient.CreateEvent(history.Namespace, history.PodName, "Normal", "SmartLimitRemoved", "解除限速原因: "+removeReason)
+			m.createEvent(history, "SmartLimitRemoved", "容器"+history.ContainerName+"解除限速原因: "+removeReason)
 		} else {
 			limitStatus.mu.Lock()
 			limitStatus.LastCheckAt = time.Now()
@@ -184,27 +378,43 @@ func (m *SmartLimitManager) applyLimitForContainer(containerID string, trend *IO
 		return
 	}
 
-	// 3. 需要限速，且已限速，判断是否需要更新
+	// 3. 需要限速，且已限速，判断是否需要更新；放宽过程中再次触发时恢复完整限速
 	if limitStatus != nil && limitStatus.IsLimited {
-		if !m.shouldUpdateLimit(limitStatus, limitResult) {
+		if !limitStatus.relaxing() && !m.shouldUpdateLimit(limitStatus, limitResult) {
 			return
 		}
+		var applied *LimitResult
 		if limitResult != nil {
 			log.Printf("Updating limit for container %s: %s", containerID, limitResult.Reason)
-			m.applySmartLimitWithResult(history.PodName, history.Namespace, trend, limitResult)
-			_ = m.kubeClient.CreateEvent(history.Namespace, history.PodName, "Normal", "SmartLimitUpdated", "限速更新: "+limitResult.Reason)
+			var err error
+			if applied, err = m.applySmartLimitWithResult(history, trend, limitResult); err != nil {
+				log.Printf("Failed to update smart limit for container %s: %v", containerID, err)
+				return
+			}
+			m.createEvent(history, "SmartLimitUpdated", "容器"+history.ContainerName+"限速更新: "+limitResult.Reason)
 		} else {
-			m.applySmartLimit(history.PodName, history.Namespace, trend)
+			m.applySmartLimit(history, trend)
 		}
-		m.updateLimitStatus(containerID, history.PodName, history.Namespace, true, limitResult)
+		if m.config.DryRun {
+			return
+		}
+		m.updateLimitStatus(containerID, history.ContainerName, history.PodName, history.Namespace, true, limitResult, applied)
 		return
 	}
 
 	// 4. 需要限速，且未限速，首次限速
 	if limitResult != nil {
-		m.applySmartLimitWithResult(history.PodName, history.Namespace, trend, limitResult)
-		m.updateLimitStatus(containerID, history.PodName, history.Namespace, true, limitResult)
-		_ = m.kubeClient.CreateEvent(history.Namespace, history.PodName, "Normal", "SmartLimitApplied", "限速原因: "+limitResult.Reason)
+		applied, err := m.applySmartLimitWithResult(history, trend, limitResult)
+		if err != nil {
+			log.Printf("Failed to apply smart limit for container %s: %v", containerID, err)
+			return
+		}
+		if m.config.DryRun {
+			// dry-run 未写入注解，不记录为已限速，下个周期仍按未限速判断
+			return
+		}
+		m.updateLimitStatus(containerID, history.ContainerName, history.PodName, history.Namespace, true, limitResult, applied)
+		m.createEvent(history, "SmartLimitApplied", "容器"+history.ContainerName+"限速原因: "+limitResult.Reason)
 	}
 }
 
@@ -217,6 +427,7 @@ func (m *SmartLimitManager) cleanupLoop() {
 		select {
 		case <-ticker.C:
 			m.cleanupHistory()
+			m.compactStore()
 		case <-m.stopCh:
 			return
 		}
@@ -239,9 +450,23 @@ func (m *SmartLimitManager) cleanupHistory() {
 			delete(m.history, containerID)
 		}
 	}
+	for containerID, b := range m.baselines {
+		if b.UpdatedAt.Before(cutoff) {
+			delete(m.baselines, containerID)
+		}
+	}
+	for containerID := range m.cgroupPaths {
+		if _, ok := m.history[containerID]; !ok {
+			delete(m.cgroupPaths, containerID)
+			delete(m.dataDiskUsers, containerID)
+		}
+	}
 
-	// 清理过期的限速状态
-	limitCutoff := time.Now().Add(-time.Duration(m.config.SmartLimitHistoryWindow) * time.Minute)
+	// 清理过期的限速状态，解除后的状态至少保留抖动判断窗口
+	limitCutoff := cutoff
+	if flapCutoff := time.Now().Add(-time.Duration(m.config.SmartLimitFlapWindow) * time.Minute); flapCutoff.Before(limitCutoff) {
+		limitCutoff = flapCutoff
+	}
 	for containerID, limitStatus := range m.limitStatus {
 		limitStatus.mu.RLock()
 		lastCheck := limitStatus.LastCheckAt
@@ -266,54 +491,52 @@ func (m *SmartLimitManager) getLimitStatus(containerID string) *LimitStatus {
 	return limitStatus
 }
 
+// shouldApplyLimitForPolicy 按Pod的智能限速策略判断是否需要限速：豁免的Pod永不限速，其他按阈值倍数调整触发阈值，
+// 策略设置了触发条件时替换全局触发条件
+func (m *SmartLimitManager) shouldApplyLimitForPolicy(trend *IOTrend, policy *limits.SmartLimitPolicy) (bool, *LimitResult) {
+	if policy.IsExempt() {
+		return false, nil
+	}
+	return m.shouldApplyLimitScaled(trend, policy.Scale(), policy.TriggersOr(m.triggers))
+}
+
 // 判断是否需要应用限速
 // shouldApplyLimitGraded 分级阈值判断
 func (m *SmartLimitManager) shouldApplyLimitGraded(trend *IOTrend) (bool, *LimitResult) {
+	return m.shouldApplyLimitScaled(trend, 1, m.triggers)
+}
+
+// shouldApplyLimitScaled 分级阈值判断，触发阈值（含触发条件的阈值）乘以 scale
+func (m *SmartLimitManager) shouldApplyLimitScaled(trend *IOTrend, scale float64, triggers []limits.Trigger) (bool, *LimitResult) {
 	// 按优先级检查：15分钟 > 30分钟 > 60分钟
 	// 优先使用更短时间窗口的阈值，因为短期高IO更需要立即处理
-	// Todo: 调整算法
-	// 1. avg_with_window < setmax , use avg_with_window
-	// 2. avg_with_window > setmax , use setmax
-
-	// 检查15分钟窗口
-	if m.checkWindowThreshold(trend.ReadIOPS15m, trend.WriteIOPS15m, trend.ReadBPS15m, trend.WriteBPS15m,
-		m.config.SmartLimitIOThreshold15m, m.config.SmartLimitBPSThreshold15m) {
-
-		return true, &LimitResult{
-			TriggeredBy: "15m",
-			ReadIOPS:    m.config.SmartLimitIOPSLimit15m,
-			WriteIOPS:   m.config.SmartLimitIOPSLimit15m,
-			ReadBPS:     m.config.SmartLimitBPSLimit15m,
-			WriteBPS:    m.config.SmartLimitBPSLimit15m,
-			Reason:      m.buildTriggerReason("15m", trend.ReadIOPS15m, trend.WriteIOPS15m, trend.ReadBPS15m, trend.WriteBPS15m),
+	// 限速值按 SmartLimitLimitMode 取窗口的固定值或按该窗口的观测速率比例计算
+
+	// 读写分别与各自的阈值比较，只限制超过阈值的方向；该窗口的触发条件满足时同样限制其方向
+	for _, w := range m.windowConfigs() {
+		readIOPS, writeIOPS, readBPS, writeBPS := trend.window(w.name)
+		read := m.checkDirectionThreshold(readIOPS, readBPS, w.readIOThreshold*scale, w.readBPSThreshold*scale)
+		write := m.checkDirectionThreshold(writeIOPS, writeBPS, w.writeIOThreshold*scale, w.writeBPSThreshold*scale)
+		var fired []string
+		var firedTriggers []limits.Trigger
+		for _, t := range triggers {
+			if t.Window != w.name {
+				continue
+			}
+			t.Threshold *= scale
+			if value := trend.statistic(t.Window, t.Statistic, t.Metric); value > t.Threshold {
+				fired = append(fired, fmt.Sprintf("%s(%.2f)", t, value))
+				firedTriggers = append(firedTriggers, t)
+				read, write = read || t.Read(), write || !t.Read()
+			}
 		}
-	}
-
-	// 检查30分钟窗口
-	if m.checkWindowThreshold(trend.ReadIOPS30m, trend.WriteIOPS30m, trend.ReadBPS30m, trend.WriteBPS30m,
-		m.config.SmartLimitIOThreshold30m, m.config.SmartLimitBPSThreshold30m) {
-
-		return true, &LimitResult{
-			TriggeredBy: "30m",
-			ReadIOPS:    m.config.SmartLimitIOPSLimit30m,
-			WriteIOPS:   m.config.SmartLimitIOPSLimit30m,
-			ReadBPS:     m.config.SmartLimitBPSLimit30m,
-			WriteBPS:    m.config.SmartLimitBPSLimit30m,
-			Reason:      m.buildTriggerReason("30m", trend.ReadIOPS30m, trend.WriteIOPS30m, trend.ReadBPS30m, trend.WriteBPS30m),
-		}
-	}
-
-	// 检查60分钟窗口
-	if m.checkWindowThreshold(trend.ReadIOPS60m, trend.WriteIOPS60m, trend.ReadBPS60m, trend.WriteBPS60m,
-		m.config.SmartLimitIOThreshold60m, m.config.SmartLimitBPSThreshold60m) {
-
-		return true, &LimitResult{
-			TriggeredBy: "60m",
-			ReadIOPS:    m.config.SmartLimitIOPSLimit60m,
-			WriteIOPS:   m.config.SmartLimitIOPSLimit60m,
-			ReadBPS:     m.config.SmartLimitBPSLimit60m,
-			WriteBPS:    m.config.SmartLimitBPSLimit60m,
-			Reason:      m.buildTriggerReason("60m", trend.ReadIOPS60m, trend.WriteIOPS60m, trend.ReadBPS60m, trend.WriteBPS60m),
+		if read || write {
+			result := m.windowLimitResult(w, read, write, readIOPS, writeIOPS, readBPS, writeBPS)
+			if len(fired) > 0 {
+				result.Reason += "，满足触发条件[" + strings.Join(fired, ",") + "]"
+				result.Triggers = firedTriggers
+			}
+			return true, result
 		}
 	}
 
@@ -322,11 +545,17 @@ func (m *SmartLimitManager) shouldApplyLimitGraded(trend *IOTrend) (bool, *Limit
 
 // shouldRemoveLimit 判断是否需要解除限速
 func (m *SmartLimitManager) shouldRemoveLimit(trend *IOTrend, limitStatus *LimitStatus) bool {
+	// 异常检测按容器基线判断，基线在持有状态锁之前读取
+	baseline, _ := m.GetContainerBaseline(limitStatus.ContainerID)
+
 	limitStatus.mu.RLock()
 	defer limitStatus.mu.RUnlock()
 
-	// 检查是否达到解除延迟时间
+	// 检查是否达到解除延迟时间，频繁抖动的容器按抖动冷却时间延长
 	removeDelay := time.Duration(m.config.SmartLimitRemoveDelay) * time.Minute
+	if cooldown := m.flapCooldown(limitStatus.Flaps); cooldown > removeDelay {
+		removeDelay = cooldown
+	}
 	if time.Since(limitStatus.AppliedAt) < removeDelay {
 		return false
 	}
@@ -337,6 +566,13 @@ func (m *SmartLimitManager) shouldRemoveLimit(trend *IOTrend, limitStatus *Limit
 		return false
 	}
 
+	// 统计量触发的限速要求触发条件的统计量回落到阈值以下
+	for _, t := range limitStatus.Triggers {
+		if trend.statistic(t.Window, t.Statistic, t.Metric) > t.Threshold {
+			return false
+		}
+	}
+
 	// 根据触发的时间窗口检查IO是否已经降低到安全水平
 	switch limitStatus.TriggeredBy {
 	case "15m":
@@ -353,6 +589,8 @@ func (m *SmartLimitManager) shouldRemoveLimit(trend *IOTrend, limitStatus *Limit
 			math.Max(trend.ReadBPS15m, math.Max(trend.ReadBPS30m, trend.ReadBPS60m)),
 			math.Max(trend.WriteBPS15m, math.Max(trend.WriteBPS30m, trend.WriteBPS60m)),
 		)
+	case anomalyTrigger:
+		return m.anomalyReleased(baseline)
 	default:
 		return false
 	}
@@ -374,7 +612,9 @@ func (m *SmartLimitManager) checkRemoveCondition(readIOPS, writeIOPS, readBPS, w
 }
 
 // removeSmartLimit 移除限速
-func (m *SmartLimitManager) removeSmartLimit(podName, namespace string, trend *IOTrend, limitStatus *LimitStatus) {
+// 只移除该容器的容器级智能限速注解，容器回退到Pod级/全局限速配置
+func (m *SmartLimitManager) removeSmartLimit(history *ContainerIOHistory, trend *IOTrend, limitStatus *LimitStatus) {
+	podName, namespace, containerName := history.PodName, history.Namespace, history.ContainerName
 	// 如果 kubeClient 为 nil，跳过智能限速移除
 	if m.kubeClient == nil {
 		log.Printf("KubeClient is nil, skipping smart limit removal for pod %s/%s", namespace, podName)
@@ -388,39 +628,95 @@ func (m *SmartLimitManager) removeSmartLimit(podName, namespace string, trend *I
 		return
 	}
 
-	// 构建注解
+	// 构建注解，只移除智能限速写入的注解，保留用户设置的容器级注解
 	annotations := make(map[string]string)
 	for k, v := range pod.Annotations {
-		// 移除限速相关的注解
-		if !strings.HasPrefix(k, m.config.SmartLimitAnnotationPrefix+"/") {
-			annotations[k] = v
-		}
+		annotations[k] = v
+	}
+	m.deleteAppliedAnnotations(annotations, containerName, limitStatus.appliedResult())
+	for _, key := range []string{annotationkeys.TriggeredByAnnotationKey, annotationkeys.TriggerReasonAnnotationKey,
+		"trend-read-iops-15m", "trend-write-iops-15m", "trend-read-bps-15m", "trend-write-bps-15m"} {
+		delete(annotations, m.containerAnnotationKey(containerName, key))
 	}
 
 	// 添加解除限速的标记
-	annotations[m.config.SmartLimitAnnotationPrefix+"/limit-removed"] = "true"
-	annotations[m.config.SmartLimitAnnotationPrefix+"/removed-at"] = time.Now().Format(time.RFC3339)
-	annotations[m.config.SmartLimitAnnotationPrefix+"/removed-reason"] = m.buildRemoveReason(trend, limitStatus)
+	removeReason := m.buildRemoveReason(trend, limitStatus)
+	annotations[m.containerAnnotationKey(containerName, annotationkeys.LimitRemovedAnnotationKey)] = "true"
+	annotations[m.containerAnnotationKey(containerName, annotationkeys.RemovedAtAnnotationKey)] = time.Now().Format(time.RFC3339)
+	annotations[m.containerAnnotationKey(containerName, annotationkeys.RemovedReasonAnnotationKey)] = removeReason
+
+	if m.config.DryRun {
+		log.Printf("[DRY-RUN] Would remove smart limit from pod %s/%s container %s: %s", namespace, podName, containerName, removeReason)
+		m.recordAudit(audit.ActionRemoveSmartLimit, history, 0, 0, 0, 0, removeReason, nil)
+		return
+	}
 
 	// 更新Pod注解
 	pod.Annotations = annotations
 	_, err = m.kubeClient.UpdatePod(pod)
+	m.recordAudit(audit.ActionRemoveSmartLimit, history, 0, 0, 0, 0, removeReason, err)
 	if err != nil {
-		log.Printf("Failed to remove smart limit for pod %s/%s: %v", namespace, podName, err)
+		log.Printf("Failed to remove smart limit for pod %s/%s container %s: %v", namespace, podName, containerName, err)
 		return
 	}
 
-	log.Printf("Removed smart limit from pod %s/%s: %s", namespace, podName, m.buildRemoveReason(trend, limitStatus))
+	log.Printf("Removed smart limit from pod %s/%s container %s: %s", namespace, podName, containerName, removeReason)
+}
+
+// containerAnnotationKey 返回带前缀的容器级注解key
+func (m *SmartLimitManager) containerAnnotationKey(containerName, key string) string {
+	return m.config.SmartLimitAnnotationPrefix + "/" + annotationkeys.ContainerKey(containerName, key)
+}
+
+// deleteAppliedAnnotations 移除智能限速上次写入的容器级限速注解：值与上次写入值不同的注解视为用户修改，予以保留；
+// applied 为nil时为自动限速模式，按配置的自动限速值判断
+func (m *SmartLimitManager) deleteAppliedAnnotations(annotations map[string]string, containerName string, applied *LimitResult) {
+	written := map[string]int{
+		annotationkeys.IopsAnnotationKey: m.config.SmartLimitAutoIOPS,
+		annotationkeys.BpsAnnotationKey:  m.config.SmartLimitAutoBPS,
+	}
+	if applied != nil {
+		written = map[string]int{
+			annotationkeys.ReadIopsAnnotationKey:  applied.ReadIOPS,
+			annotationkeys.WriteIopsAnnotationKey: applied.WriteIOPS,
+			annotationkeys.ReadBpsAnnotationKey:   applied.ReadBPS,
+			annotationkeys.WriteBpsAnnotationKey:  applied.WriteBPS,
+		}
+	}
+	for key, value := range written {
+		annotationKey := m.containerAnnotationKey(containerName, key)
+		if value > 0 && annotations[annotationKey] == strconv.Itoa(value) {
+			delete(annotations, annotationKey)
+		}
+	}
+}
+
+// optedOut 判断用户是否通过0值注解关闭该项限速，按 容器级 > Pod级 的优先级解析，
+// 同一层级的 iops/bps 注解优先于分读写的注解（与限速下发时的解析规则一致）
+func (m *SmartLimitManager) optedOut(annotations map[string]string, containerName, key, sharedKey string) bool {
+	prefix := m.config.SmartLimitAnnotationPrefix + "/"
+	for _, scope := range []string{annotationkeys.ContainerKey(containerName, ""), ""} {
+		for _, k := range []string{sharedKey, key} {
+			if val, ok := annotations[prefix+scope+k]; ok {
+				return val == "0"
+			}
+		}
+	}
+	return false
 }
 
 // buildRemoveReason 构建解除限速原因
 func (m *SmartLimitManager) buildRemoveReason(trend *IOTrend, limitStatus *LimitStatus) string {
 	limitStatus.mu.RLock()
-	defer limitStatus.mu.RUnlock()
+	triggeredBy := limitStatus.TriggeredBy
+	limitStatus.mu.RUnlock()
+	if triggeredBy == anomalyTrigger {
+		return m.buildAnomalyRemoveReason(limitStatus.ContainerID)
+	}
 
 	var currentValues []string
 
-	switch limitStatus.TriggeredBy {
+	switch triggeredBy {
 	case "15m":
 		currentValues = append(currentValues, fmt.Sprintf("ReadIOPS:%.2f", trend.ReadIOPS15m))
 		currentValues = append(currentValues, fmt.Sprintf("WriteIOPS:%.2f", trend.WriteIOPS15m))
@@ -471,6 +767,11 @@ func (m *SmartLimitManager) shouldUpdateLimit(currentStatus *LimitStatus, newRes
 		return false
 	}
 
+	// 比例模式下已限速容器的观测速率受限速值约束，按其重新计算会使限速值逐轮下降，保持首次触发时的值
+	if m.proportional() {
+		return false
+	}
+
 	// 检查是否触发了不同的时间窗口
 	if currentStatus.TriggeredBy != newResult.TriggeredBy {
 		return true
@@ -488,19 +789,9 @@ func (m *SmartLimitManager) shouldUpdateLimit(currentStatus *LimitStatus, newRes
 	return false
 }
 
-// checkWindowThreshold 检查单个时间窗口的阈值
-func (m *SmartLimitManager) checkWindowThreshold(readIOPS, writeIOPS, readBPS, writeBPS, ioThreshold, bpsThreshold float64) bool {
-	// 检查IOPS阈值
-	if readIOPS > ioThreshold || writeIOPS > ioThreshold {
-		return true
-	}
-
-	// 检查BPS阈值
-	if readBPS > bpsThreshold || writeBPS > bpsThreshold {
-		return true
-	}
-
-	return false
+// checkDirectionThreshold 检查单个方向（读或写）的IOPS和BPS是否超过阈值
+func (m *SmartLimitManager) checkDirectionThreshold(iops, bps, ioThreshold, bpsThreshold float64) bool {
+	return iops > ioThreshold || bps > bpsThreshold
 }
 
 // buildTriggerReason 构建触发原因描述
@@ -524,7 +815,8 @@ func (m *SmartLimitManager) buildTriggerReason(window string, readIOPS, writeIOP
 }
 
 // applySmartLimit 应用智能限速
-func (m *SmartLimitManager) applySmartLimit(podName, namespace string, trend *IOTrend) {
+func (m *SmartLimitManager) applySmartLimit(history *ContainerIOHistory, trend *IOTrend) {
+	podName, namespace, containerName := history.PodName, history.Namespace, history.ContainerName
 	// 如果 kubeClient 为 nil，跳过智能限速应用
 	if m.kubeClient == nil {
 		log.Printf("KubeClient is nil, skipping smart limit application for pod %s/%s", namespace, podName)
@@ -543,127 +835,191 @@ func (m *SmartLimitManager) applySmartLimit(podName, namespace string, trend *IO
 	for k, v := range pod.Annotations {
 		annotations[k] = v
 	}
+	delete(annotations, m.containerAnnotationKey(containerName, annotationkeys.LimitRemovedAnnotationKey))
 
 	if m.config.SmartLimitAutoIOPS > 0 {
-		annotations[m.config.SmartLimitAnnotationPrefix+"/iops-limit"] = strconv.Itoa(m.config.SmartLimitAutoIOPS)
+		annotations[m.containerAnnotationKey(containerName, annotationkeys.IopsAnnotationKey)] = strconv.Itoa(m.config.SmartLimitAutoIOPS)
 	}
 
 	if m.config.SmartLimitAutoBPS > 0 {
-		annotations[m.config.SmartLimitAnnotationPrefix+"/bps-limit"] = strconv.Itoa(m.config.SmartLimitAutoBPS)
+		annotations[m.containerAnnotationKey(containerName, annotationkeys.BpsAnnotationKey)] = strconv.Itoa(m.config.SmartLimitAutoBPS)
 	}
 
 	// 添加趋势信息
-	annotations[m.config.SmartLimitAnnotationPrefix+"/trend-read-iops-15m"] = strconv.FormatFloat(trend.ReadIOPS15m, 'f', 2, 64)
-	annotations[m.config.SmartLimitAnnotationPrefix+"/trend-write-iops-15m"] = strconv.FormatFloat(trend.WriteIOPS15m, 'f', 2, 64)
-	annotations[m.config.SmartLimitAnnotationPrefix+"/trend-read-bps-15m"] = strconv.FormatFloat(trend.ReadBPS15m, 'f', 2, 64)
-	annotations[m.config.SmartLimitAnnotationPrefix+"/trend-write-bps-15m"] = strconv.FormatFloat(trend.WriteBPS15m, 'f', 2, 64)
+	annotations[m.containerAnnotationKey(containerName, "trend-read-iops-15m")] = strconv.FormatFloat(trend.ReadIOPS15m, 'f', 2, 64)
+	annotations[m.containerAnnotationKey(containerName, "trend-write-iops-15m")] = strconv.FormatFloat(trend.WriteIOPS15m, 'f', 2, 64)
+	annotations[m.containerAnnotationKey(containerName, "trend-read-bps-15m")] = strconv.FormatFloat(trend.ReadBPS15m, 'f', 2, 64)
+	annotations[m.containerAnnotationKey(containerName, "trend-write-bps-15m")] = strconv.FormatFloat(trend.WriteBPS15m, 'f', 2, 64)
+
+	autoIOPS, autoBPS := m.config.SmartLimitAutoIOPS, m.config.SmartLimitAutoBPS
+	if m.config.DryRun {
+		log.Printf("[DRY-RUN] Would apply smart limit to pod %s/%s container %s: IOPS=%d, BPS=%d", namespace, podName, containerName, autoIOPS, autoBPS)
+		m.recordAudit(audit.ActionApplySmartLimit, history, autoIOPS, autoIOPS, autoBPS, autoBPS, "auto", nil)
+		return
+	}
 
 	// 更新Pod注解
 	pod.Annotations = annotations
 	_, err = m.kubeClient.UpdatePod(pod)
+	m.recordAudit(audit.ActionApplySmartLimit, history, autoIOPS, autoIOPS, autoBPS, autoBPS, "auto", err)
 	if err != nil {
 		log.Printf("Failed to update pod annotations for %s/%s: %v", namespace, podName, err)
 		return
 	}
 
-	log.Printf("Applied smart limit to pod %s/%s: IOPS=%d, BPS=%d", namespace, podName, m.config.SmartLimitAutoIOPS, m.config.SmartLimitAutoBPS)
+	log.Printf("Applied smart limit to pod %s/%s container %s: IOPS=%d, BPS=%d", namespace, podName, containerName, m.config.SmartLimitAutoIOPS, m.config.SmartLimitAutoBPS)
 }
 
-// applySmartLimitWithResult 应用分级智能限速
-func (m *SmartLimitManager) applySmartLimitWithResult(podName, namespace string, trend *IOTrend, limitResult *LimitResult) {
+// applySmartLimitWithResult 应用分级智能限速（写入容器级注解，只影响触发限速的容器），返回实际写入的限速值
+func (m *SmartLimitManager) applySmartLimitWithResult(history *ContainerIOHistory, trend *IOTrend, limitResult *LimitResult) (*LimitResult, error) {
+	return m.applySmartLimitAction(audit.ActionApplySmartLimit, history, limitResult)
+}
+
+// applySmartLimitAction 按限速结果写入容器级注解，action 为审计记录的动作（限速或放宽）；
+// 返回实际写入的限速值（含容器限额回退和全局最大值约束，用户设置为0而跳过的项为0），写入失败时返回错误
+func (m *SmartLimitManager) applySmartLimitAction(action string, history *ContainerIOHistory, limitResult *LimitResult) (*LimitResult, error) {
+	podName, namespace, containerName := history.PodName, history.Namespace, history.ContainerName
 	// 如果 kubeClient 为 nil，跳过智能限速应用
 	if m.kubeClient == nil {
-		log.Printf("KubeClient is nil, skipping smart limit application for pod %s/%s", namespace, podName)
-		return
+		return nil, fmt.Errorf("kube client not available, skipping smart limit application for pod %s/%s", namespace, podName)
 	}
 
 	// 获取Pod
 	pod, err := m.kubeClient.GetPod(namespace, podName)
 	if err != nil {
-		log.Printf("Failed to get pod %s/%s: %v", namespace, podName, err)
-		return
+		return nil, fmt.Errorf("failed to get pod %s/%s: %v", namespace, podName, err)
 	}
 	// 构建注解
 	annotations := make(map[string]string)
 	for k, v := range pod.Annotations {
 		annotations[k] = v
 	}
+	delete(annotations, m.containerAnnotationKey(containerName, annotationkeys.LimitRemovedAnnotationKey))
 	// 获取容器限额
-	containerID := podName + ":" + namespace // 简化，实际应用时可用真实containerID
-	limit := m.getOrInitContainerLimit(containerID)
+	limit := m.getOrInitContainerLimit(history.ContainerID)
 
-	// 融合分级限速与全局限额逻辑
+	// 融合分级限速与全局限额逻辑：分级限速优先，触发方向上未给出限速值（窗口限速值未配置）时使用容器限额，
+	// 未触发的方向不限速；没有限速结果时读写都使用容器限额；均不超过全局最大
+	read, write := true, true
 	var readIOPS, writeIOPS, readBPS, writeBPS int
-	if limitResult != nil && (limitResult.ReadIOPS > 0 || limitResult.ReadBPS > 0) {
-		// 分级限速优先，且不超过全局最大
-		readIOPS = min(limitResult.ReadIOPS, m.config.MaxIOPSLimit)
-		writeIOPS = min(limitResult.WriteIOPS, m.config.MaxIOPSLimit)
-		readBPS = min(limitResult.ReadBPS, m.config.MaxBPSLimit)
-		writeBPS = min(limitResult.WriteBPS, m.config.MaxBPSLimit)
-	} else {
-		// 否则用containerLimits
-		readIOPS = min(limit.IOPS, m.config.MaxIOPSLimit)
-		writeIOPS = min(limit.IOPS, m.config.MaxIOPSLimit)
-		readBPS = min(limit.BPS, m.config.MaxBPSLimit)
-		writeBPS = min(limit.BPS, m.config.MaxBPSLimit)
+	if limitResult != nil {
+		read, write = limitResult.Read, limitResult.Write
+		readIOPS, writeIOPS, readBPS, writeBPS = limitResult.ReadIOPS, limitResult.WriteIOPS, limitResult.ReadBPS, limitResult.WriteBPS
 	}
-
-	prefix := m.config.SmartLimitAnnotationPrefix
-	// 检查当前注解中是否有0值，若有则本轮跳过下发该项
-	if !(annotations[prefix+"/"+annotationkeys.ReadIopsAnnotationKey] == "0") && readIOPS > 0 {
-		annotations[prefix+"/"+annotationkeys.ReadIopsAnnotationKey] = strconv.Itoa(readIOPS)
+	if read && readIOPS == 0 && readBPS == 0 {
+		readIOPS, readBPS = limit.IOPS, limit.BPS
 	}
-	if !(annotations[prefix+"/"+annotationkeys.WriteIopsAnnotationKey] == "0") && writeIOPS > 0 {
-		annotations[prefix+"/"+annotationkeys.WriteIopsAnnotationKey] = strconv.Itoa(writeIOPS)
+	if write && writeIOPS == 0 && writeBPS == 0 {
+		writeIOPS, writeBPS = limit.IOPS, limit.BPS
 	}
-	if !(annotations[prefix+"/"+annotationkeys.ReadBpsAnnotationKey] == "0") && readBPS > 0 {
-		annotations[prefix+"/"+annotationkeys.ReadBpsAnnotationKey] = strconv.Itoa(readBPS)
+	readIOPS = min(readIOPS, m.config.MaxIOPSLimit)
+	writeIOPS = min(writeIOPS, m.config.MaxIOPSLimit)
+	readBPS = min(readBPS, m.config.MaxBPSLimit)
+	writeBPS = min(writeBPS, m.config.MaxBPSLimit)
+
+	// 先移除上次写入的值（如触发方向由读变为写），再按用户注解判断：用户将某项设置为0时本轮跳过下发该项
+	if status := m.getLimitStatus(history.ContainerID); status != nil {
+		if applied := status.appliedResult(); applied != nil {
+			m.deleteAppliedAnnotations(annotations, containerName, applied)
+		}
 	}
-	if !(annotations[prefix+"/"+annotationkeys.WriteBpsAnnotationKey] == "0") && writeBPS > 0 {
-		annotations[prefix+"/"+annotationkeys.WriteBpsAnnotationKey] = strconv.Itoa(writeBPS)
+	for _, item := range []struct {
+		key, sharedKey string
+		value          *int
+	}{
+		{annotationkeys.ReadIopsAnnotationKey, annotationkeys.IopsAnnotationKey, &readIOPS},
+		{annotationkeys.WriteIopsAnnotationKey, annotationkeys.IopsAnnotationKey, &writeIOPS},
+		{annotationkeys.ReadBpsAnnotationKey, annotationkeys.BpsAnnotationKey, &readBPS},
+		{annotationkeys.WriteBpsAnnotationKey, annotationkeys.BpsAnnotationKey, &writeBPS},
+	} {
+		switch {
+		case m.optedOut(annotations, containerName, item.key, item.sharedKey):
+			*item.value = 0
+		case *item.value > 0:
+			annotations[m.containerAnnotationKey(containerName, item.key)] = strconv.Itoa(*item.value)
+		}
 	}
+	applied := &LimitResult{ReadIOPS: readIOPS, WriteIOPS: writeIOPS, ReadBPS: readBPS, WriteBPS: writeBPS, Read: read, Write: write}
 	// 添加触发信息
 	if limitResult != nil {
-		annotations[prefix+"/triggered-by"] = limitResult.TriggeredBy
-		annotations[prefix+"/trigger-reason"] = limitResult.Reason
+		annotations[m.containerAnnotationKey(containerName, annotationkeys.TriggeredByAnnotationKey)] = limitResult.TriggeredBy
+		annotations[m.containerAnnotationKey(containerName, annotationkeys.TriggerReasonAnnotationKey)] = limitResult.Reason
+		applied.TriggeredBy, applied.Reason = limitResult.TriggeredBy, limitResult.Reason
+	}
+	if m.config.DryRun {
+		log.Printf("[DRY-RUN] Would apply smart limit to pod %s/%s container %s: IOPS[%d,%d], BPS[%d,%d]", namespace, podName, containerName, readIOPS, writeIOPS, readBPS, writeBPS)
+		m.recordAudit(action, history, readIOPS, writeIOPS, readBPS, writeBPS, applied.Reason, nil)
+		return applied, nil
 	}
 	// 添加趋势信息（略）
 	pod.Annotations = annotations
 	_, err = m.kubeClient.UpdatePod(pod)
+	m.recordAudit(action, history, readIOPS, writeIOPS, readBPS, writeBPS, applied.Reason, err)
 	if err != nil {
-		log.Printf("Failed to update pod annotations for %s/%s: %v", namespace, podName, err)
-		return
+		return nil, fmt.Errorf("failed to update pod annotations for %s/%s: %v", namespace, podName, err)
 	}
-	log.Printf("Applied smart limit to pod %s/%s: IOPS[%d,%d], BPS[%d,%d]", namespace, podName, readIOPS, writeIOPS, readBPS, writeIOPS)
+	log.Printf("Applied smart limit to pod %s/%s container %s: IOPS[%d,%d], BPS[%d,%d]", namespace, podName, containerName, readIOPS, writeIOPS, readBPS, writeBPS)
+	return applied, nil
 }
 
-// updateLimitStatus 更新容器限速状态
-func (m *SmartLimitManager) updateLimitStatus(containerID, podName, namespace string, isLimited bool, limitResult *LimitResult) {
+// updateLimitStatus 更新容器限速状态，更新后的副本在释放锁之后写入本地存储
+func (m *SmartLimitManager) updateLimitStatus(containerID, containerName, podName, namespace string, isLimited bool, limitResult, applied *LimitResult) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	limitStatus, exists := m.limitStatus[containerID]
 	if !exists {
 		limitStatus = &LimitStatus{
-			ContainerID: containerID,
-			PodName:     podName,
-			Namespace:   namespace,
-			IsLimited:   isLimited,
-			mu:          sync.RWMutex{},
+			ContainerID:   containerID,
+			ContainerName: containerName,
+			PodName:       podName,
+			Namespace:     namespace,
+			IsLimited:     isLimited,
+			mu:            sync.RWMutex{},
 		}
 		m.limitStatus[containerID] = limitStatus
 	}
 
 	limitStatus.mu.Lock()
-	defer limitStatus.mu.Unlock()
-
+	now := time.Now()
+	if isLimited {
+		m.countFlap(limitStatus, now)
+	} else {
+		limitStatus.RemovedAt = now
+	}
 	limitStatus.IsLimited = isLimited
+	limitStatus.RelaxStep = 0
 	limitStatus.LimitResult = limitResult
+	limitStatus.Applied = applied
+	limitStatus.Triggers = nil
 	if limitResult != nil {
 		limitStatus.TriggeredBy = limitResult.TriggeredBy
+		limitStatus.Triggers = limitResult.Triggers
 	}
-	limitStatus.AppliedAt = time.Now()
-	limitStatus.LastCheckAt = time.Now()
+	limitStatus.AppliedAt = now
+	limitStatus.LastCheckAt = now
+	snapshot := limitStatus.copyLocked()
+	limitStatus.mu.Unlock()
+	m.mu.Unlock()
+
+	m.persist(storeKindDecision, containerID, snapshot)
+}
+
+// RestoreLimitStatusSnapshot 从上一个实例交接的状态快照恢复限速状态，已存在的容器状态不覆盖
+func (m *SmartLimitManager) RestoreLimitStatusSnapshot(statuses []*LimitStatus) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restored := 0
+	for _, status := range statuses {
+		if status == nil || status.ContainerID == "" {
+			continue
+		}
+		if _, exists := m.limitStatus[status.ContainerID]; exists {
+			continue
+		}
+		m.limitStatus[status.ContainerID] = status.copyStatus()
+		restored++
+	}
+	return restored
 }
 
 // GetAllLimitStatus 获取所有容器的限速状态（API 接口）
@@ -673,18 +1029,7 @@ func (m *SmartLimitManager) GetAllLimitStatus() map[string]*LimitStatus {
 
 	result := make(map[string]*LimitStatus)
 	for containerID, status := range m.limitStatus {
-		// 创建副本以避免并发问题
-		statusCopy := &LimitStatus{
-			ContainerID: status.ContainerID,
-			PodName:     status.PodName,
-			Namespace:   status.Namespace,
-			IsLimited:   status.IsLimited,
-			TriggeredBy: status.TriggeredBy,
-			LimitResult: status.LimitResult,
-			AppliedAt:   status.AppliedAt,
-			LastCheckAt: status.LastCheckAt,
-		}
-		result[containerID] = statusCopy
+		result[containerID] = status.copyStatus()
 	}
 	return result
 }
@@ -699,18 +1044,7 @@ func (m *SmartLimitManager) GetContainerLimitStatus(containerID string) (*LimitS
 		return nil, false
 	}
 
-	// 创建副本以避免并发问题
-	statusCopy := &LimitStatus{
-		ContainerID: status.ContainerID,
-		PodName:     status.PodName,
-		Namespace:   status.Namespace,
-		IsLimited:   status.IsLimited,
-		TriggeredBy: status.TriggeredBy,
-		LimitResult: status.LimitResult,
-		AppliedAt:   status.AppliedAt,
-		LastCheckAt: status.LastCheckAt,
-	}
-	return statusCopy, true
+	return status.copyStatus(), true
 }
 
 // GetAllContainerHistory 获取所有容器的历史数据（API 接口）
@@ -724,11 +1058,12 @@ func (m *SmartLimitManager) GetAllContainerHistory() map[string]*ContainerIOHist
 		// 创建副本以避免并发问题
 		history.mu.RLock()
 		historyCopy := &ContainerIOHistory{
-			ContainerID: history.ContainerID,
-			PodName:     history.PodName,
-			Namespace:   history.Namespace,
-			LastUpdate:  history.LastUpdate,
-			Stats:       make([]*kubeclient.IOStats, len(history.Stats)),
+			ContainerID:   history.ContainerID,
+			ContainerName: history.ContainerName,
+			PodName:       history.PodName,
+			Namespace:     history.Namespace,
+			LastUpdate:    history.LastUpdate,
+			Stats:         make([]*kubeclient.IOStats, len(history.Stats)),
 		}
 		copy(historyCopy.Stats, history.Stats)
 		log.Printf("[DEBUG] Container %s has %d stats entries, last update: %v", containerID, len(history.Stats), history.LastUpdate)
@@ -752,11 +1087,12 @@ func (m *SmartLimitManager) GetContainerHistory(containerID string) (*ContainerI
 	// 创建副本以避免并发问题
 	history.mu.RLock()
 	historyCopy := &ContainerIOHistory{
-		ContainerID: history.ContainerID,
-		PodName:     history.PodName,
-		Namespace:   history.Namespace,
-		LastUpdate:  history.LastUpdate,
-		Stats:       make([]*kubeclient.IOStats, len(history.Stats)),
+		ContainerID:   history.ContainerID,
+		ContainerName: history.ContainerName,
+		PodName:       history.PodName,
+		Namespace:     history.Namespace,
+		LastUpdate:    history.LastUpdate,
+		Stats:         make([]*kubeclient.IOStats, len(history.Stats)),
 	}
 	copy(historyCopy.Stats, history.Stats)
 	history.mu.RUnlock()
@@ -1,13 +1,22 @@
 package smartlimit
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"KubeDiskGuard/pkg/audit"
 	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/device"
 	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
+	"KubeDiskGuard/pkg/store"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,8 +25,9 @@ import (
 // mockKubeClient 是一个用于测试的模拟kubeClient
 type mockKubeClient struct {
 	kubeclient.IKubeClient
-	pods []corev1.Pod
-	mu   sync.Mutex
+	pods      []corev1.Pod
+	updateErr error
+	mu        sync.Mutex
 }
 
 func (m *mockKubeClient) ListNodePodsWithKubeletFirst() ([]corev1.Pod, error) {
@@ -40,6 +50,9 @@ func (m *mockKubeClient) GetPod(namespace, name string) (*corev1.Pod, error) {
 func (m *mockKubeClient) UpdatePod(pod *corev1.Pod) (*corev1.Pod, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.updateErr != nil {
+		return nil, m.updateErr
+	}
 	for i, p := range m.pods {
 		if p.Namespace == pod.Namespace && p.Name == pod.Name {
 			m.pods[i] = *pod
@@ -49,13 +62,21 @@ func (m *mockKubeClient) UpdatePod(pod *corev1.Pod) (*corev1.Pod, error) {
 	return nil, fmt.Errorf("pod not found")
 }
 
+func (m *mockKubeClient) CreateEvent(namespace, podName, eventType, reason, message string) error {
+	return nil
+}
+
 func newTestManager(cfg *config.Config) *SmartLimitManager {
 	return &SmartLimitManager{
-		config:      cfg,
-		history:     make(map[string]*ContainerIOHistory),
-		limitStatus: make(map[string]*LimitStatus),
-		stopCh:      make(chan struct{}),
-		kubeClient:  &mockKubeClient{},
+		config:          cfg,
+		history:         make(map[string]*ContainerIOHistory),
+		limitStatus:     make(map[string]*LimitStatus),
+		baselines:       make(map[string]*ContainerBaseline),
+		containerLimits: make(map[string]*ContainerLimit),
+		cgroupPaths:     make(map[string]string),
+		dataDiskUsers:   make(map[string]bool),
+		stopCh:          make(chan struct{}),
+		kubeClient:      &mockKubeClient{},
 		// No cgroupMgr needed for these specific tests
 	}
 }
@@ -113,6 +134,136 @@ func TestShouldApplyLimit(t *testing.T) {
 	}
 }
 
+func TestShouldApplyLimitDirectional(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 100
+	cfg.SmartLimitBPSThreshold15m = 10 * 1024 * 1024
+	cfg.SmartLimitIOPSLimit15m = 200
+	cfg.SmartLimitBPSLimit15m = 5 * 1024 * 1024
+	cfg.SmartLimitWriteIOThreshold15m = 500
+	cfg.SmartLimitWriteIOPSLimit15m = 400
+	manager := newTestManager(cfg)
+
+	// 纯读容器只限制读方向
+	_, result := manager.shouldApplyLimitGraded(&IOTrend{ReadIOPS15m: 150, WriteIOPS15m: 20})
+	if result.ReadIOPS != 200 || result.ReadBPS != 5*1024*1024 || result.WriteIOPS != 0 || result.WriteBPS != 0 {
+		t.Errorf("pure reader should only be read limited: %+v", result)
+	}
+	if !strings.Contains(result.Reason, "限速方向:读") {
+		t.Errorf("reason should record the direction, got=%s", result.Reason)
+	}
+	// 写IOPS使用自己的阈值：300未超过500不触发，超过后使用写方向的限速值
+	if shouldLimit, _ := manager.shouldApplyLimitGraded(&IOTrend{WriteIOPS15m: 300}); shouldLimit {
+		t.Error("write IOPS below its own threshold should not trigger")
+	}
+	_, result = manager.shouldApplyLimitGraded(&IOTrend{WriteIOPS15m: 600})
+	if result.WriteIOPS != 400 || result.WriteBPS != 5*1024*1024 || result.ReadIOPS != 0 {
+		t.Errorf("writer should use write limits only: %+v", result)
+	}
+}
+
+func TestApplyDirectionalLimitWithDefaultConfig(t *testing.T) {
+	// 默认配置下各窗口限速值为0，触发方向使用容器限额，未触发的方向不限速
+	cfg := config.GetDefaultConfig()
+	manager := newTestManager(cfg)
+	manager.kubeClient = &mockKubeClient{pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}}}
+	manager.history["app-id"] = &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default"}
+
+	manager.applyLimitForContainer("app-id", &IOTrend{ReadIOPS15m: 150})
+	pod, _ := manager.kubeClient.GetPod("default", "web")
+	annotations := pod.Annotations
+	if got := annotations[manager.containerAnnotationKey("app", "read-iops")]; got != "500" {
+		t.Errorf("read direction should fall back to the container limit, got=%q", got)
+	}
+	for _, key := range []string{"write-iops", "write-bps"} {
+		if got, ok := annotations[manager.containerAnnotationKey("app", key)]; ok {
+			t.Errorf("pure reader should not get %s limited, got=%q", key, got)
+		}
+	}
+}
+
+func TestSmartLimitPolicyFromContainerProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(file, []byte(`{"profiles": [
+		{"name": "batch", "smart_limit": {"threshold_scale": 2}},
+		{"name": "database", "smart_limit": {"exempt": true}}
+	]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := limits.LoadProfiles(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.GetDefaultConfig()
+	manager := newTestManager(cfg)
+	manager.SetLimitProfiles(profiles)
+	prefix := cfg.SmartLimitAnnotationPrefix + "/"
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+		prefix + "profile":              "batch",
+		prefix + "container.db.profile": "database",
+	}}}
+
+	// 容器级引用的配置优先于Pod级
+	if policy := manager.smartLimitPolicy(pod, "db"); !policy.IsExempt() {
+		t.Errorf("container profile should take precedence, got=%+v", policy)
+	}
+	if policy := manager.smartLimitPolicy(pod, "app"); policy.IsExempt() || policy.Scale() != 2 {
+		t.Errorf("pod profile should apply to other containers, got=%+v", policy)
+	}
+}
+
+func TestShouldApplyLimitForPolicy(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 100
+	cfg.SmartLimitIOPSLimit15m = 115
+	manager := newTestManager(cfg)
+
+	trend := &IOTrend{ReadIOPS15m: 80}
+	if shouldLimit, _ := manager.shouldApplyLimitForPolicy(trend, nil); shouldLimit {
+		t.Error("trend below threshold should not be limited without policy")
+	}
+	// 阈值倍数0.5时80超过50，提前触发
+	if shouldLimit, _ := manager.shouldApplyLimitForPolicy(trend, &limits.SmartLimitPolicy{ThresholdScale: 0.5}); !shouldLimit {
+		t.Error("scaled threshold should trigger limit")
+	}
+	// 豁免的Pod即使远超阈值也不限速
+	exempt := &limits.SmartLimitPolicy{Exempt: true}
+	if shouldLimit, _ := manager.shouldApplyLimitForPolicy(&IOTrend{ReadIOPS15m: 10000}, exempt); shouldLimit {
+		t.Error("exempt policy should never be limited")
+	}
+}
+
+func TestShouldApplyLimitProportional(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitLimitMode = LimitModeProportional
+	cfg.SmartLimitIOThreshold15m = 100
+	cfg.SmartLimitBPSThreshold15m = 10 * 1024 * 1024
+	cfg.SmartLimitWriteIOPSFraction = 0.5
+	cfg.SmartLimitProportionalMinIOPS = 50
+	cfg.SmartLimitProportionalMinBPS = 1024 * 1024
+	cfg.MaxIOPSLimit = 2000
+	cfg.MaxBPSLimit = 100 * 1024 * 1024
+	manager := newTestManager(cfg)
+
+	shouldLimit, result := manager.shouldApplyLimitGraded(&IOTrend{ReadIOPS15m: 20, ReadBPS15m: 20 * 1024 * 1024, WriteIOPS15m: 1200, WriteBPS15m: 200 * 1024 * 1024})
+	if !shouldLimit {
+		t.Fatal("trend above threshold should be limited")
+	}
+	// 写IOPS按0.5取600；读IOPS 20*0.8=16 抬到下限50；写BPS 160M 压到上限100M；读BPS 20M*0.8=16M
+	if result.WriteIOPS != 600 || result.ReadIOPS != 50 || result.WriteBPS != 100*1024*1024 || result.ReadBPS != 16*1024*1024 {
+		t.Errorf("unexpected proportional limits: %+v", result)
+	}
+	if !strings.Contains(result.Reason, "WriteIOPS:1200.00*0.50=600") {
+		t.Errorf("reason should record the calculation, got=%s", result.Reason)
+	}
+
+	// 已限速的容器不随受限后的速率重新计算
+	current := &LimitStatus{IsLimited: true, TriggeredBy: "15m", LimitResult: result}
+	if manager.shouldUpdateLimit(current, &LimitResult{TriggeredBy: "15m", WriteIOPS: 300}) {
+		t.Error("proportional limit should not be recalculated while limited")
+	}
+}
+
 func TestShouldRemoveLimit(t *testing.T) {
 	cfg := config.GetDefaultConfig()
 	cfg.SmartLimitRemoveThreshold = 50
@@ -289,3 +440,720 @@ func TestShouldMonitorPodByNamespace(t *testing.T) {
 		t.Error("should monitor default")
 	}
 }
+
+func TestRestoreContainerScopedLimitStatus(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	manager := newTestManager(cfg)
+
+	prefix := cfg.SmartLimitAnnotationPrefix + "/"
+	manager.kubeClient = &mockKubeClient{
+		pods: []corev1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "multi",
+					Namespace: "default",
+					Annotations: map[string]string{
+						prefix + "container.app.triggered-by":      "30m",
+						prefix + "container.app.read-iops-limit":   "300",
+						prefix + "container.sidecar.limit-removed": "true",
+					},
+				},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{
+						{Name: "app", ContainerID: "containerd://app-id"},
+						{Name: "sidecar", ContainerID: "containerd://sidecar-id"},
+					},
+				},
+			},
+		},
+	}
+
+	manager.restoreLimitStatus()
+
+	status, exists := manager.limitStatus["app-id"]
+	if !exists {
+		t.Fatal("app container should be restored")
+	}
+	if status.ContainerName != "app" || status.LimitResult.TriggeredBy != "30m" || status.LimitResult.ReadIOPS != 300 {
+		t.Errorf("unexpected restored status: %+v %+v", status, status.LimitResult)
+	}
+	if _, exists := manager.limitStatus["sidecar-id"]; exists {
+		t.Error("sidecar container without smart limit should not be restored")
+	}
+}
+
+func TestStopWaitsAndRestoreSnapshot(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	manager := newTestManager(cfg)
+
+	finished := false
+	manager.goRun(func() {
+		<-manager.stopCh
+		time.Sleep(20 * time.Millisecond)
+		finished = true
+	})
+	manager.Stop()
+	if !finished {
+		t.Errorf("Stop should wait for in-flight work")
+	}
+	// 重复调用不应panic
+	manager.Stop()
+
+	restored := manager.RestoreLimitStatusSnapshot([]*LimitStatus{
+		{ContainerID: "c1", PodName: "app", Namespace: "default", IsLimited: true, TriggeredBy: "15m"},
+		{ContainerID: ""},
+	})
+	if restored != 1 {
+		t.Errorf("restored mismatch. got=%d, want=1", restored)
+	}
+	status, ok := manager.GetContainerLimitStatus("c1")
+	if !ok || !status.IsLimited {
+		t.Errorf("expected container c1 to be restored as limited, got=%v", status)
+	}
+}
+
+func TestDryRunDoesNotPatchPod(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.DryRun = true
+	manager := newTestManager(cfg)
+	recorder := audit.NewRecorder(10)
+	manager.SetAuditRecorder(recorder)
+	client := &mockKubeClient{
+		pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}},
+	}
+	manager.kubeClient = client
+
+	history := &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "app", Namespace: "default"}
+	result := &LimitResult{TriggeredBy: "15m", ReadIOPS: 100, WriteIOPS: 100, Reason: "test"}
+	manager.applySmartLimitWithResult(history, &IOTrend{}, result)
+
+	if len(client.pods[0].Annotations) != 0 {
+		t.Errorf("dry-run should not patch pod annotations, got=%v", client.pods[0].Annotations)
+	}
+	records := recorder.List(audit.Filter{})
+	if len(records) != 1 {
+		t.Fatalf("expected 1 audit record, got=%d", len(records))
+	}
+	if !records[0].DryRun || records[0].Action != audit.ActionApplySmartLimit || records[0].ReadIOPS != 100 {
+		t.Errorf("unexpected audit record: %+v", records[0])
+	}
+
+	// dry-run 不记录为已限速
+	cfg.SmartLimitIOThreshold15m = 100
+	manager.history["app-id"] = history
+	manager.applyLimitForContainer("app-id", &IOTrend{WriteIOPS15m: 500})
+	if status := manager.getLimitStatus("app-id"); status != nil && status.IsLimited {
+		t.Errorf("dry-run should not record limited status: %+v", status)
+	}
+	if len(recorder.List(audit.Filter{})) != 2 {
+		t.Error("dry-run decision should still be audited")
+	}
+}
+
+func TestPersistHistoryAcrossRestart(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitStoreDir = t.TempDir()
+	prefix := cfg.SmartLimitAnnotationPrefix + "/"
+
+	manager := newTestManager(cfg)
+	manager.openStore()
+	now := time.Now()
+	manager.addIOStats("app-id", "app", "web", "default", nil, &kubeclient.IOStats{ContainerID: "app-id", Timestamp: now.Add(-time.Minute), WriteIOPS: 100})
+	manager.addIOStats("app-id", "app", "web", "default", nil, &kubeclient.IOStats{ContainerID: "app-id", Timestamp: now, WriteIOPS: 200})
+	manager.updateLimitStatus("app-id", "app", "web", "default", true, &LimitResult{TriggeredBy: "15m", WriteIOPS: 500, Reason: "persisted"}, nil)
+	appliedAt := manager.limitStatus["app-id"].AppliedAt
+	manager.closeStore()
+
+	// 新实例回放存储，注解恢复不覆盖已回放的状态
+	restarted := newTestManager(cfg)
+	restarted.kubeClient = &mockKubeClient{pods: []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+			prefix + "container.app.triggered-by": "30m",
+			prefix + "container.app.write-iops":   "300",
+		}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", ContainerID: "containerd://app-id"}}},
+	}}}
+	restarted.openStore()
+	defer restarted.closeStore()
+	restarted.restoreLimitStatus()
+
+	history, ok := restarted.GetContainerHistory("app-id")
+	if !ok || len(history.Stats) != 2 || history.PodName != "web" {
+		t.Fatalf("expected 2 replayed stats for app-id, got=%+v", history)
+	}
+	if history.Stats[1].WriteIOPS != 200 {
+		t.Errorf("replayed stats mismatch. got=%d, want=200", history.Stats[1].WriteIOPS)
+	}
+	status, ok := restarted.GetContainerLimitStatus("app-id")
+	if !ok || !status.IsLimited || status.TriggeredBy != "15m" || status.LimitResult.WriteIOPS != 500 {
+		t.Fatalf("expected replayed limit status, got=%+v", status)
+	}
+	if !status.AppliedAt.Equal(appliedAt) {
+		t.Errorf("AppliedAt should survive restart. got=%v, want=%v", status.AppliedAt, appliedAt)
+	}
+}
+
+func TestCheckpointOnStopAndOpen(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitStoreDir = t.TempDir()
+	checkpoints := func(st *store.Store) []*LimitStatus {
+		t.Helper()
+		var statuses []*LimitStatus
+		err := st.Replay(time.Time{}, func(rec store.Record) {
+			status := &LimitStatus{}
+			if rec.Kind == storeKindStatus && json.Unmarshal(rec.Data, status) == nil {
+				statuses = append(statuses, status)
+			}
+		})
+		if err != nil {
+			t.Fatalf("replay store failed: %v", err)
+		}
+		return statuses
+	}
+
+	// 停止时写入检查点，检查点保留实际写入的值和触发条件
+	manager := newTestManager(cfg)
+	manager.openStore()
+	trigger := limits.Trigger{Window: "15m", Statistic: limits.StatisticP99, Metric: limits.MetricWriteIOPS, Threshold: 2000}
+	manager.updateLimitStatus("app-id", "app", "web", "default", true,
+		&LimitResult{TriggeredBy: "15m", Write: true, Triggers: []limits.Trigger{trigger}}, &LimitResult{TriggeredBy: "15m", WriteIOPS: 500, Write: true})
+	manager.Stop()
+	st, err := store.Open(cfg.SmartLimitStoreDir, time.Hour)
+	if err != nil {
+		t.Fatalf("open store failed: %v", err)
+	}
+	statuses := checkpoints(st)
+	st.Close()
+	if len(statuses) != 1 || statuses[0].Applied == nil || statuses[0].Applied.WriteIOPS != 500 || len(statuses[0].Triggers) != 1 {
+		t.Fatalf("stop should checkpoint the limit status, got=%+v", statuses)
+	}
+
+	// 回放后立即写入检查点
+	restarted := newTestManager(cfg)
+	restarted.openStore()
+	if got := len(checkpoints(restarted.store)); got != 2 {
+		t.Errorf("open should checkpoint the replayed status, got %d checkpoints", got)
+	}
+	restarted.Stop()
+}
+
+func TestRestoreLimitStatusFromAppliedAnnotations(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	manager := newTestManager(cfg)
+
+	prefix := cfg.SmartLimitAnnotationPrefix + "/"
+	manager.kubeClient = &mockKubeClient{pods: []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+			prefix + "container.app.triggered-by": "15m",
+			prefix + "container.app.read-iops":    "300",
+			prefix + "container.app.write-bps":    "1048576",
+		}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", ContainerID: "containerd://app-id"}}},
+	}}}
+
+	manager.restoreLimitStatus()
+
+	status, ok := manager.limitStatus["app-id"]
+	if !ok {
+		t.Fatal("app container should be restored")
+	}
+	if status.LimitResult.ReadIOPS != 300 || status.LimitResult.WriteBPS != 1048576 {
+		t.Errorf("restored limits mismatch: %+v", status.LimitResult)
+	}
+}
+
+func TestStagedRelaxation(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 100
+	cfg.SmartLimitIOPSLimit15m = 200
+	cfg.SmartLimitRemoveThreshold = 50
+	cfg.SmartLimitRemoveDelay = 0
+	cfg.SmartLimitRemoveCheckInterval = 0
+	cfg.SmartLimitRelaxFactor = 2
+	cfg.SmartLimitRelaxSteps = 2
+	cfg.SmartLimitFlapCooldown = 5
+	manager := newTestManager(cfg)
+	client := &mockKubeClient{pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}}}
+	manager.kubeClient = client
+	manager.history["app-id"] = &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default"}
+
+	key := cfg.SmartLimitAnnotationPrefix + "/container.app.write-iops"
+	high, low := &IOTrend{WriteIOPS15m: 500}, &IOTrend{WriteIOPS15m: 10}
+	expect := func(step string, value string, relaxStep, flaps int) {
+		t.Helper()
+		status := manager.limitStatus["app-id"]
+		if got := client.pods[0].Annotations[key]; got != value {
+			t.Errorf("%s: write-iops annotation mismatch. got=%s, want=%s", step, got, value)
+		}
+		if status.RelaxStep != relaxStep || status.Flaps != flaps {
+			t.Errorf("%s: relax step/flaps mismatch. got=%d/%d, want=%d/%d", step, status.RelaxStep, status.Flaps, relaxStep, flaps)
+		}
+	}
+
+	manager.applyLimitForContainer("app-id", high)
+	expect("limit", "200", 0, 0)
+	manager.applyLimitForContainer("app-id", low)
+	expect("relax", "400", 1, 0)
+
+	// 放宽中再次触发：恢复完整限速并计一次抖动，冷却期内不开始放宽
+	manager.applyLimitForContainer("app-id", high)
+	expect("tighten", "200", 0, 1)
+	manager.applyLimitForContainer("app-id", low)
+	expect("cooldown", "200", 0, 1)
+	if cooldown := manager.flapCooldown(3); cooldown != 20*time.Minute {
+		t.Errorf("flap cooldown should double per flap. got=%v, want=20m", cooldown)
+	}
+
+	// 冷却结束后逐级放宽，放宽完所有级数后才解除
+	manager.limitStatus["app-id"].AppliedAt = time.Now().Add(-10 * time.Minute)
+	manager.applyLimitForContainer("app-id", low)
+	expect("relax 1", "400", 1, 1)
+	manager.applyLimitForContainer("app-id", low)
+	expect("relax 2", "800", 2, 1)
+	manager.applyLimitForContainer("app-id", low)
+	if status := manager.limitStatus["app-id"]; status.IsLimited || status.RemovedAt.IsZero() {
+		t.Errorf("limit should be removed after all relax steps: %+v", status)
+	}
+	if _, exists := client.pods[0].Annotations[key]; exists {
+		t.Error("write-iops annotation should be removed")
+	}
+
+	// 解除后不久再次触发仍计为抖动
+	manager.applyLimitForContainer("app-id", high)
+	expect("re-trigger", "200", 0, 2)
+}
+
+func TestRelaxFromAppliedLimitWithDefaultConfig(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 100
+	cfg.SmartLimitRemoveThreshold = 50
+	cfg.SmartLimitRemoveDelay = 0
+	cfg.SmartLimitRemoveCheckInterval = 0
+	cfg.SmartLimitRelaxFactor = 2
+	cfg.SmartLimitRelaxSteps = 2
+	manager := newTestManager(cfg)
+	client := &mockKubeClient{pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}}}
+	manager.kubeClient = client
+	manager.history["app-id"] = &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default"}
+	key := cfg.SmartLimitAnnotationPrefix + "/container.app.write-iops"
+
+	// 默认配置下窗口限速值为0，实际生效的是容器限额回退值
+	manager.applyLimitForContainer("app-id", &IOTrend{WriteIOPS15m: 1000})
+	if got := client.pods[0].Annotations[key]; got != strconv.Itoa(cfg.DefaultIOPSLimit) {
+		t.Fatalf("fallback limit mismatch. got=%s, want=%d", got, cfg.DefaultIOPSLimit)
+	}
+	low := &IOTrend{WriteIOPS15m: 10}
+	manager.applyLimitForContainer("app-id", low)
+	if got := client.pods[0].Annotations[key]; got != strconv.Itoa(cfg.DefaultIOPSLimit*2) {
+		t.Errorf("relax should double the applied limit. got=%s, want=%d", got, cfg.DefaultIOPSLimit*2)
+	}
+
+	// 写入失败时不推进放宽级数
+	client.updateErr = fmt.Errorf("conflict")
+	manager.applyLimitForContainer("app-id", low)
+	status := manager.limitStatus["app-id"]
+	if status.RelaxStep != 1 || status.Applied.WriteIOPS != cfg.DefaultIOPSLimit*2 {
+		t.Errorf("failed relax should keep the status: step=%d, applied=%+v", status.RelaxStep, status.Applied)
+	}
+	client.updateErr = nil
+	manager.applyLimitForContainer("app-id", low)
+	if got := client.pods[0].Annotations[key]; got != strconv.Itoa(cfg.DefaultIOPSLimit*4) || status.RelaxStep != 2 {
+		t.Errorf("relax should resume after failure. got=%s, step=%d", got, status.RelaxStep)
+	}
+}
+
+func TestRelaxAfterSnapshotRestore(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 100
+	cfg.SmartLimitRemoveThreshold = 50
+	cfg.SmartLimitRemoveDelay = 0
+	cfg.SmartLimitRemoveCheckInterval = 0
+	cfg.SmartLimitRelaxFactor = 2
+	cfg.SmartLimitRelaxSteps = 2
+	client := &mockKubeClient{pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}}}
+	history := &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default"}
+	key := cfg.SmartLimitAnnotationPrefix + "/container.app.write-iops"
+
+	// 窗口限速值为0，实际写入的是容器限额回退值，只记录在 Applied 中
+	old := newTestManager(cfg)
+	old.kubeClient = client
+	old.history["app-id"] = history
+	old.applyLimitForContainer("app-id", &IOTrend{WriteIOPS15m: 1000})
+
+	// 快照经JSON编码交接给新实例
+	var snapshot []*LimitStatus
+	for _, status := range old.GetAllLimitStatus() {
+		snapshot = append(snapshot, status)
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("marshal snapshot failed: %v", err)
+	}
+	var decoded []*LimitStatus
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal snapshot failed: %v", err)
+	}
+	restarted := newTestManager(cfg)
+	restarted.kubeClient = client
+	restarted.history["app-id"] = history
+	if restarted.RestoreLimitStatusSnapshot(decoded) != 1 {
+		t.Fatalf("expected one restored status")
+	}
+	status, _ := restarted.GetContainerLimitStatus("app-id")
+	if status.Applied == nil || status.Applied.WriteIOPS != cfg.DefaultIOPSLimit {
+		t.Fatalf("restored status should carry applied values, got=%+v", status.Applied)
+	}
+
+	// 放宽基于实际写入的值，而不是限速结果中的0
+	restarted.applyLimitForContainer("app-id", &IOTrend{WriteIOPS15m: 10})
+	if got := client.pods[0].Annotations[key]; got != strconv.Itoa(cfg.DefaultIOPSLimit*2) {
+		t.Errorf("relax after restore should double the applied limit. got=%s, want=%d", got, cfg.DefaultIOPSLimit*2)
+	}
+}
+
+func TestApplySmartLimitDropsUntriggeredDirection(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	manager := newTestManager(cfg)
+	prefix := cfg.SmartLimitAnnotationPrefix + "/container.app."
+	client := &mockKubeClient{pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}}}
+	manager.kubeClient = client
+	history := &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default"}
+
+	for _, result := range []*LimitResult{{TriggeredBy: "15m", ReadIOPS: 200}, {TriggeredBy: "15m", WriteIOPS: 300}} {
+		applied, err := manager.applySmartLimitWithResult(history, &IOTrend{}, result)
+		if err != nil {
+			t.Fatalf("apply smart limit failed: %v", err)
+		}
+		manager.updateLimitStatus("app-id", "app", "web", "default", true, result, applied)
+	}
+	annotations := client.pods[0].Annotations
+	if _, exists := annotations[prefix+"read-iops"]; exists || annotations[prefix+"write-iops"] != "300" {
+		t.Errorf("only the triggered direction should stay limited, got=%v", annotations)
+	}
+}
+
+func TestSmartLimitKeepsUserAnnotations(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	manager := newTestManager(cfg)
+	podPrefix := cfg.SmartLimitAnnotationPrefix + "/"
+	prefix := podPrefix + "container.app."
+	client := &mockKubeClient{pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+		podPrefix + "read-iops": "0",
+		prefix + "read-bps":     "0",
+		prefix + "write-bps":    "2097152",
+		prefix + "exclude":      "false",
+	}}}}}
+	manager.kubeClient = client
+	history := &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default"}
+
+	// Pod级read-iops为0、容器级read-bps为0时不下发这两项
+	result := &LimitResult{TriggeredBy: "15m", ReadIOPS: 200, WriteIOPS: 300, ReadBPS: 1024, Read: true, Write: true}
+	applied, err := manager.applySmartLimitWithResult(history, &IOTrend{}, result)
+	if err != nil {
+		t.Fatalf("apply smart limit failed: %v", err)
+	}
+	manager.updateLimitStatus("app-id", "app", "web", "default", true, result, applied)
+	annotations := client.pods[0].Annotations
+	if _, exists := annotations[prefix+"read-iops"]; exists {
+		t.Errorf("pod-level opt-out should skip read-iops, got=%v", annotations)
+	}
+	if annotations[prefix+"read-bps"] != "0" || annotations[prefix+"write-iops"] != "300" {
+		t.Errorf("unexpected applied annotations: %v", annotations)
+	}
+	if applied.ReadIOPS != 0 || applied.ReadBPS != 0 || applied.WriteIOPS != 300 {
+		t.Errorf("applied result should exclude opted-out items: %+v", applied)
+	}
+
+	// 解除限速只移除智能限速写入的注解
+	manager.removeSmartLimit(history, &IOTrend{}, manager.getLimitStatus("app-id"))
+	annotations = client.pods[0].Annotations
+	for key, want := range map[string]string{
+		podPrefix + "read-iops":  "0",
+		prefix + "read-bps":      "0",
+		prefix + "write-bps":     "2097152",
+		prefix + "exclude":       "false",
+		prefix + "limit-removed": "true",
+	} {
+		if got, exists := annotations[key]; !exists || got != want {
+			t.Errorf("annotation %s mismatch after removal. got=%q, want=%q", key, got, want)
+		}
+	}
+	for _, key := range []string{"write-iops", "triggered-by", "trigger-reason"} {
+		if _, exists := annotations[prefix+key]; exists {
+			t.Errorf("annotation %s should be removed, got=%v", key, annotations)
+		}
+	}
+}
+
+func TestAnomalyDetection(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitStoreDir = t.TempDir()
+	cfg.SmartLimitAnomalyWarmup = 10
+	cfg.SmartLimitAnomalySustain = 5
+	cfg.SmartLimitRemoveDelay = 0
+	cfg.SmartLimitRemoveCheckInterval = 0
+	anomaly := &limits.SmartLimitPolicy{Detector: limits.DetectorAnomaly}
+	manager := newTestManager(cfg)
+	manager.openStore()
+
+	// 每分钟采集一次：前20分钟写IOPS在90~110之间波动，之后7分钟突增到2000
+	start := time.Now().Add(-27 * time.Minute)
+	var writes int64
+	add := func(minute int, rate int64) {
+		writes += rate * 60
+		manager.addIOStats("app-id", "app", "web", "default", anomaly, &kubeclient.IOStats{ContainerID: "app-id", Timestamp: start.Add(time.Duration(minute) * time.Minute), WriteIOPS: writes})
+	}
+	for i := 0; i <= 20; i++ {
+		add(i, 90+int64(i%2)*20)
+	}
+	if shouldLimit, _ := manager.detect("app-id", &IOTrend{}, anomaly); shouldLimit {
+		t.Error("steady IO within baseline should not trigger")
+	}
+	for i := 21; i <= 27; i++ {
+		add(i, 2000)
+	}
+
+	// 默认检测方式为分级阈值，平均速率未超过阈值
+	if shouldLimit, _ := manager.detect("app-id", &IOTrend{}, nil); shouldLimit {
+		t.Error("threshold detector should not use the baseline")
+	}
+	shouldLimit, result := manager.detect("app-id", &IOTrend{}, anomaly)
+	if !shouldLimit || result.TriggeredBy != anomalyTrigger {
+		t.Fatalf("sustained burst should trigger anomaly limit, got=%v %+v", shouldLimit, result)
+	}
+	if result.ReadIOPS != 0 || result.ReadBPS != 0 {
+		t.Errorf("only write direction should be limited: %+v", result)
+	}
+	if result.WriteIOPS < cfg.SmartLimitAnomalyMinIOPS || result.WriteIOPS > 300 || result.WriteBPS != cfg.SmartLimitAnomalyMinBPS {
+		t.Errorf("limit should be the baseline upper band: %+v", result)
+	}
+	baseline, _ := manager.GetContainerBaseline("app-id")
+	if baseline.WriteIOPS.Mean > 300 {
+		t.Errorf("burst should not be absorbed into the baseline, mean=%.2f", baseline.WriteIOPS.Mean)
+	}
+
+	// 限速期间不学习，速率回落后满足解除条件
+	manager.updateLimitStatus("app-id", "app", "web", "default", true, result, result)
+	status := manager.getLimitStatus("app-id")
+	samples := baseline.Samples
+	add(28, int64(result.WriteIOPS))
+	if manager.shouldRemoveLimit(&IOTrend{}, status) {
+		t.Error("rate held at the limit should not release")
+	}
+	add(29, 100)
+	if !manager.shouldRemoveLimit(&IOTrend{}, status) {
+		t.Error("rate back within baseline should release")
+	}
+	if baseline, _ = manager.GetContainerBaseline("app-id"); baseline.Samples != samples {
+		t.Errorf("baseline should not learn while limited. got=%d, want=%d", baseline.Samples, samples)
+	}
+	manager.closeStore()
+
+	// 基线随本地存储在重启后恢复
+	restarted := newTestManager(cfg)
+	restarted.openStore()
+	defer restarted.closeStore()
+	if restored, ok := restarted.GetContainerBaseline("app-id"); !ok || restored.Samples != samples || restored.WriteIOPS != baseline.WriteIOPS {
+		t.Errorf("baseline should survive restart. got=%+v, want=%+v", restored, baseline)
+	}
+}
+
+func TestPercentileTriggers(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 1000
+	cfg.SmartLimitIOThreshold30m = 1000
+	cfg.SmartLimitIOThreshold60m = 1000
+	manager := newTestManager(cfg)
+
+	// 14个采集间隔写IOPS为100，1个间隔突增到3100：均值300，p50为100，p99和最大值为3100
+	now := time.Now()
+	var writes int64
+	stats := []*kubeclient.IOStats{{Timestamp: now.Add(-15 * time.Minute)}}
+	for i := 1; i <= 15; i++ {
+		rate := int64(100)
+		if i == 8 {
+			rate = 3100
+		}
+		writes += rate * 59
+		stats = append(stats, &kubeclient.IOStats{Timestamp: now.Add(time.Duration(i*59-900) * time.Second), WriteIOPS: writes})
+	}
+	trend := manager.AnalyzeContainerTrend(stats)
+	p := trend.Percentiles["15m"]
+	if p == nil {
+		t.Fatal("15m window should carry percentiles")
+	}
+	if trend.WriteIOPS15m != 300 || p.WriteIOPS.P50 != 100 || p.WriteIOPS.P99 != 3100 || p.WriteIOPS.Max != 3100 {
+		t.Errorf("unexpected write IOPS distribution: mean=%.2f %+v", trend.WriteIOPS15m, p.WriteIOPS)
+	}
+
+	// 均值未超过阈值，p99超过触发条件时限速写方向
+	if shouldLimit, _ := manager.shouldApplyLimitGraded(trend); shouldLimit {
+		t.Error("mean below threshold should not trigger without triggers")
+	}
+	manager.SetTriggers([]limits.Trigger{{Window: "15m", Statistic: limits.StatisticP99, Metric: limits.MetricWriteIOPS, Threshold: 2000}})
+	shouldLimit, result := manager.shouldApplyLimitGraded(trend)
+	if !shouldLimit || result.TriggeredBy != "15m" || !strings.Contains(result.Reason, "15m:p99:write_iops>2000") {
+		t.Fatalf("p99 trigger should limit, got=%v %+v", shouldLimit, result)
+	}
+	if !strings.Contains(result.Reason, "限速方向:写") {
+		t.Errorf("only write direction should be limited: %s", result.Reason)
+	}
+
+	// Pod策略的触发条件替换全局触发条件，阈值同样乘以倍数
+	policy := &limits.SmartLimitPolicy{Triggers: []limits.Trigger{{Window: "15m", Statistic: limits.StatisticP50, Metric: limits.MetricWriteIOPS, Threshold: 150}}}
+	if shouldLimit, _ := manager.shouldApplyLimitForPolicy(trend, policy); shouldLimit {
+		t.Error("policy triggers should replace global triggers")
+	}
+	policy.ThresholdScale = 0.5
+	if shouldLimit, _ := manager.shouldApplyLimitForPolicy(trend, policy); !shouldLimit {
+		t.Error("scaled policy trigger should limit")
+	}
+
+	// 触发条件限速的容器：均值满足解除条件但p99仍超过阈值时不解除
+	cfg.SmartLimitRemoveThreshold = 500
+	cfg.SmartLimitRemoveDelay = 0
+	cfg.SmartLimitRemoveCheckInterval = 0
+	manager.updateLimitStatus("app-id", "app", "web", "default", true, result, result)
+	status := manager.getLimitStatus("app-id")
+	if len(status.Triggers) != 1 {
+		t.Fatalf("limit status should record the fired trigger: %+v", status.Triggers)
+	}
+	if manager.shouldRemoveLimit(trend, status) {
+		t.Error("limit should be kept while the p99 stays above the trigger threshold")
+	}
+	if !manager.shouldRemoveLimit(&IOTrend{WriteIOPS15m: 300}, status) {
+		t.Error("limit should be released once the trigger statistic drops below the threshold")
+	}
+
+	// 检查点和交接快照复制的状态保留触发条件，恢复后仍按统计量判断是否解除
+	data, err := json.Marshal([]*LimitStatus{manager.GetAllLimitStatus()["app-id"]})
+	if err != nil {
+		t.Fatalf("marshal snapshot failed: %v", err)
+	}
+	var decoded []*LimitStatus
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal snapshot failed: %v", err)
+	}
+	restarted := newTestManager(cfg)
+	restarted.RestoreLimitStatusSnapshot(decoded)
+	restored, _ := restarted.GetContainerLimitStatus("app-id")
+	if len(restored.Triggers) != 1 || restarted.shouldRemoveLimit(trend, restored) {
+		t.Errorf("restored limit should be kept while the p99 stays above the trigger threshold: %+v", restored.Triggers)
+	}
+}
+
+func TestContentionGate(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 100
+	cfg.SmartLimitIOPSLimit15m = 200
+	cfg.SmartLimitContentionEnabled = true
+	cfg.SmartLimitContentionUtil = 80
+	cfg.SmartLimitContentionAwait = 50
+	cfg.SmartLimitContentionShare = 0.3
+	manager := newTestManager(cfg)
+	client := &mockKubeClient{pods: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}}}
+	manager.kubeClient = client
+
+	// 容器最近一个采集间隔写IOPS为500
+	now := time.Now()
+	manager.history["app-id"] = &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default",
+		Stats: []*kubeclient.IOStats{{Timestamp: now.Add(-time.Minute)}, {Timestamp: now, WriteIOPS: 30000}}}
+	high := &IOTrend{WriteIOPS15m: 500}
+
+	for _, tt := range []struct {
+		name    string
+		usage   *device.Usage
+		limited bool
+	}{
+		{"idle device", &device.Usage{Name: "sda", Utilization: 20, Await: 2, WriteIOPS: 600}, false},
+		{"busy device, small share", &device.Usage{Name: "sda", Utilization: 95, Await: 2, WriteIOPS: 5000}, false},
+		{"slow device, large share", &device.Usage{Name: "sda", Utilization: 40, Await: 80, WriteIOPS: 800}, true},
+	} {
+		tt.usage.SampledAt = now
+		manager.deviceUsage = tt.usage
+		manager.applyLimitForContainer("app-id", high)
+		status := manager.getLimitStatus("app-id")
+		if limited := status != nil && status.IsLimited; limited != tt.limited {
+			t.Fatalf("%s: limited mismatch. got=%v, want=%v", tt.name, limited, tt.limited)
+		}
+	}
+	if reason := manager.getLimitStatus("app-id").LimitResult.Reason; !strings.Contains(reason, "容器占比:62.5%") {
+		t.Errorf("reason should carry contention detail: %s", reason)
+	}
+
+	// 采样过期时不拦截
+	manager.limitStatus = make(map[string]*LimitStatus)
+	manager.deviceUsage = &device.Usage{Utilization: 0, SampledAt: now.Add(-time.Hour)}
+	if contended, _ := manager.checkContention(manager.history["app-id"]); !contended {
+		t.Error("stale device usage should not block limiting")
+	}
+}
+
+func TestIOLatency(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.SmartLimitIOThreshold15m = 1000
+	cfg.SmartLimitIOThreshold30m = 1000
+	cfg.SmartLimitIOThreshold60m = 1000
+	manager := newTestManager(cfg)
+
+	// 5个采集间隔中4个报告了读延迟：2ms、2ms、2ms、30ms，未报告延迟（为0）的采样不参与统计
+	now := time.Now()
+	stats := []*kubeclient.IOStats{{Timestamp: now.Add(-5 * time.Minute)}}
+	for i, latency := range []int64{2000, 2000, 0, 2000, 30000} {
+		stats = append(stats, &kubeclient.IOStats{Timestamp: now.Add(time.Duration(i-4) * time.Minute), ReadLatency: latency})
+	}
+	trend := manager.AnalyzeContainerTrend(stats)
+	if trend.ReadLatency15m != 9 || trend.WriteLatency15m != 0 {
+		t.Errorf("unexpected mean latency: read=%.2f write=%.2f", trend.ReadLatency15m, trend.WriteLatency15m)
+	}
+	if p := trend.Percentiles["15m"].ReadLatency; p.P50 != 2 || p.Max != 30 {
+		t.Errorf("unexpected read latency distribution: %+v", p)
+	}
+
+	// 延迟触发条件只限速读方向
+	manager.SetTriggers([]limits.Trigger{{Window: "15m", Statistic: limits.StatisticMax, Metric: limits.MetricReadLatency, Threshold: 20}})
+	shouldLimit, result := manager.shouldApplyLimitGraded(trend)
+	if !shouldLimit || !strings.Contains(result.Reason, "15m:max:read_latency>20") || !strings.Contains(result.Reason, "限速方向:读") {
+		t.Fatalf("latency trigger should limit reads, got=%v %+v", shouldLimit, result)
+	}
+
+	// 设备整体指标未达阈值，但邻居容器延迟达到阈值时视为繁忙
+	cfg.SmartLimitContentionEnabled = true
+	cfg.SmartLimitContentionShare = 0.3
+	manager.history["app-id"] = &ContainerIOHistory{ContainerID: "app-id", ContainerName: "app", PodName: "web", Namespace: "default",
+		Stats: []*kubeclient.IOStats{{Timestamp: now.Add(-time.Minute)}, {Timestamp: now, WriteIOPS: 30000}}}
+	manager.history["db-id"] = &ContainerIOHistory{ContainerID: "db-id", ContainerName: "db", PodName: "mysql", Namespace: "default",
+		Stats: []*kubeclient.IOStats{{Timestamp: now.Add(-time.Minute)}, {Timestamp: now, WriteIOPS: 30000, WriteLatency: 45000}}}
+	manager.deviceUsage = &device.Usage{Name: "sda", Utilization: 40, Await: 5, WriteIOPS: 800, SampledAt: now}
+	if contended, _ := manager.checkContention(manager.history["app-id"]); contended {
+		t.Error("latency signal is disabled by default")
+	}
+	cfg.SmartLimitContentionLatency = 40
+	// 未确认使用同一数据盘的容器不作为受影响方
+	if contended, _ := manager.checkContention(manager.history["app-id"]); contended {
+		t.Error("latency of a container on another disk should not count as a victim signal")
+	}
+	manager.dataDiskUsers["db-id"] = true
+	contended, detail := manager.checkContention(manager.history["app-id"])
+	if !contended || !strings.Contains(detail, "受影响容器default/mysql/db延迟:45.0ms") {
+		t.Errorf("slow neighbor should mark the disk contended, got=%v %s", contended, detail)
+	}
+	// 已限速容器的延迟不作为受影响方信号
+	manager.limitStatus["db-id"] = &LimitStatus{ContainerID: "db-id", IsLimited: true}
+	if contended, _ := manager.checkContention(manager.history["app-id"]); contended {
+		t.Error("latency of a limited container should not count as a victim signal")
+	}
+	delete(manager.limitStatus, "db-id")
+	// 自身延迟不作为受影响方信号
+	if contended, _ := manager.checkContention(manager.history["db-id"]); contended {
+		t.Error("container's own latency should not count as a victim signal")
+	}
+
+	// kubelet summary 路径：按cAdvisor累积加权IO时间的差值计算平均延迟，1.5秒/300次IO=5ms
+	manager.history["app-id"].Stats[1].IOTime = 10
+	sample := &kubeclient.IOStats{Timestamp: now.Add(time.Minute), ReadIOPS: 100, WriteIOPS: 30200}
+	manager.fillLatency("app-id", sample, map[string]float64{"app-id": 11.5})
+	if sample.IOTime != 11.5 || sample.ReadLatency != 5000 || sample.WriteLatency != 5000 {
+		t.Errorf("latency should come from the weighted IO time delta: %+v", sample)
+	}
+}
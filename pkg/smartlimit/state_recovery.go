@@ -1,6 +1,10 @@
 package smartlimit
 
-import "log"
+import (
+	"log"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+)
 
 // restoreLimitStatus 恢复限速状态
 func (m *SmartLimitManager) restoreLimitStatus() {
@@ -21,6 +25,10 @@ func (m *SmartLimitManager) restoreLimitStatus() {
 
 	restoredCount := 0
 	for _, pod := range pods {
+		if m.stopping() {
+			log.Println("Smart limit manager stopping, aborting limit status restoration")
+			return
+		}
 		if !m.shouldMonitorPod(pod) {
 			continue
 		}
@@ -34,7 +42,11 @@ func (m *SmartLimitManager) restoreLimitStatus() {
 				}
 
 				containerID := parseContainerID(container.ContainerID)
-				if m.restoreContainerLimitStatus(containerID, pod.Name, pod.Namespace, pod.Annotations) {
+				if m.getLimitStatus(containerID) != nil {
+					// 已从本地存储或交接快照恢复，保留其中的限速时间
+					continue
+				}
+				if m.restoreContainerLimitStatus(containerID, container.Name, pod.Name, pod.Namespace, pod.Annotations) {
 					restoredCount++
 				}
 			}
@@ -45,8 +57,12 @@ func (m *SmartLimitManager) restoreLimitStatus() {
 }
 
 // restoreContainerLimitStatus 恢复单个容器的限速状态
-func (m *SmartLimitManager) restoreContainerLimitStatus(containerID, podName, namespace string, annotations map[string]string) bool {
+// 优先读取容器级注解（<prefix>/container.<name>.*），兼容旧版本写入的Pod级注解
+func (m *SmartLimitManager) restoreContainerLimitStatus(containerID, containerName, podName, namespace string, annotations map[string]string) bool {
 	prefix := m.config.SmartLimitAnnotationPrefix + "/"
+	if _, exists := annotations[m.containerAnnotationKey(containerName, annotationkeys.TriggeredByAnnotationKey)]; exists {
+		prefix = m.containerAnnotationKey(containerName, "")
+	}
 
 	// 检查是否已被解除限速
 	if removed, exists := annotations[prefix+"limit-removed"]; exists && removed == "true" {
@@ -60,10 +76,10 @@ func (m *SmartLimitManager) restoreContainerLimitStatus(containerID, podName, na
 	}
 
 	// 解析限速值
-	readIOPS := m.parseIntAnnotation(annotations[prefix+"read-iops-limit"], 0)
-	writeIOPS := m.parseIntAnnotation(annotations[prefix+"write-iops-limit"], 0)
-	readBPS := m.parseIntAnnotation(annotations[prefix+"read-bps-limit"], 0)
-	writeBPS := m.parseIntAnnotation(annotations[prefix+"write-bps-limit"], 0)
+	readIOPS := m.restoredLimitValue(annotations, prefix, annotationkeys.ReadIopsAnnotationKey)
+	writeIOPS := m.restoredLimitValue(annotations, prefix, annotationkeys.WriteIopsAnnotationKey)
+	readBPS := m.restoredLimitValue(annotations, prefix, annotationkeys.ReadBpsAnnotationKey)
+	writeBPS := m.restoredLimitValue(annotations, prefix, annotationkeys.WriteBpsAnnotationKey)
 
 	// 解析触发原因
 	reason := annotations[prefix+"trigger-reason"]
@@ -75,12 +91,22 @@ func (m *SmartLimitManager) restoreContainerLimitStatus(containerID, podName, na
 		WriteIOPS:   writeIOPS,
 		ReadBPS:     readBPS,
 		WriteBPS:    writeBPS,
+		Read:        readIOPS > 0 || readBPS > 0,
+		Write:       writeIOPS > 0 || writeBPS > 0,
 		Reason:      reason,
 	}
 
 	// 更新限速状态
-	m.updateLimitStatus(containerID, podName, namespace, true, limitResult)
+	m.updateLimitStatus(containerID, containerName, podName, namespace, true, limitResult, limitResult)
 
 	log.Printf("Restored limit status for container %s: %s", containerID, reason)
 	return true
 }
+
+// restoredLimitValue 读取限速时写入的限速注解（如 read-iops），兼容早期版本的 read-iops-limit 写法
+func (m *SmartLimitManager) restoredLimitValue(annotations map[string]string, prefix, key string) int {
+	if value, exists := annotations[prefix+key]; exists {
+		return m.parseIntAnnotation(value, 0)
+	}
+	return m.parseIntAnnotation(annotations[prefix+key+"-limit"], 0)
+}
@@ -0,0 +1,218 @@
+package smartlimit
+
+import (
+	"math"
+	"sort"
+
+	"KubeDiskGuard/pkg/limits"
+)
+
+// windowConfig 单个时间窗口读写分开的触发阈值和限速值
+type windowConfig struct {
+	name              string
+	readIOThreshold   float64
+	writeIOThreshold  float64
+	readBPSThreshold  float64
+	writeBPSThreshold float64
+	readIOPSLimit     int
+	writeIOPSLimit    int
+	readBPSLimit      int
+	writeBPSLimit     int
+}
+
+// orFloat 分方向的配置为0时使用读写共用的值
+func orFloat(value, shared float64) float64 {
+	if value == 0 {
+		return shared
+	}
+	return value
+}
+
+// orInt 分方向的配置为0时使用读写共用的值
+func orInt(value, shared int) int {
+	if value == 0 {
+		return shared
+	}
+	return value
+}
+
+// windowConfigs 按优先级（15分钟 > 30分钟 > 60分钟）返回各窗口的阈值和限速值
+func (m *SmartLimitManager) windowConfigs() []windowConfig {
+	c := m.config
+	return []windowConfig{
+		{
+			name:              "15m",
+			readIOThreshold:   orFloat(c.SmartLimitReadIOThreshold15m, c.SmartLimitIOThreshold15m),
+			writeIOThreshold:  orFloat(c.SmartLimitWriteIOThreshold15m, c.SmartLimitIOThreshold15m),
+			readBPSThreshold:  orFloat(c.SmartLimitReadBPSThreshold15m, c.SmartLimitBPSThreshold15m),
+			writeBPSThreshold: orFloat(c.SmartLimitWriteBPSThreshold15m, c.SmartLimitBPSThreshold15m),
+			readIOPSLimit:     orInt(c.SmartLimitReadIOPSLimit15m, c.SmartLimitIOPSLimit15m),
+			writeIOPSLimit:    orInt(c.SmartLimitWriteIOPSLimit15m, c.SmartLimitIOPSLimit15m),
+			readBPSLimit:      orInt(c.SmartLimitReadBPSLimit15m, c.SmartLimitBPSLimit15m),
+			writeBPSLimit:     orInt(c.SmartLimitWriteBPSLimit15m, c.SmartLimitBPSLimit15m),
+		},
+		{
+			name:              "30m",
+			readIOThreshold:   orFloat(c.SmartLimitReadIOThreshold30m, c.SmartLimitIOThreshold30m),
+			writeIOThreshold:  orFloat(c.SmartLimitWriteIOThreshold30m, c.SmartLimitIOThreshold30m),
+			readBPSThreshold:  orFloat(c.SmartLimitReadBPSThreshold30m, c.SmartLimitBPSThreshold30m),
+			writeBPSThreshold: orFloat(c.SmartLimitWriteBPSThreshold30m, c.SmartLimitBPSThreshold30m),
+			readIOPSLimit:     orInt(c.SmartLimitReadIOPSLimit30m, c.SmartLimitIOPSLimit30m),
+			writeIOPSLimit:    orInt(c.SmartLimitWriteIOPSLimit30m, c.SmartLimitIOPSLimit30m),
+			readBPSLimit:      orInt(c.SmartLimitReadBPSLimit30m, c.SmartLimitBPSLimit30m),
+			writeBPSLimit:     orInt(c.SmartLimitWriteBPSLimit30m, c.SmartLimitBPSLimit30m),
+		},
+		{
+			name:              "60m",
+			readIOThreshold:   orFloat(c.SmartLimitReadIOThreshold60m, c.SmartLimitIOThreshold60m),
+			writeIOThreshold:  orFloat(c.SmartLimitWriteIOThreshold60m, c.SmartLimitIOThreshold60m),
+			readBPSThreshold:  orFloat(c.SmartLimitReadBPSThreshold60m, c.SmartLimitBPSThreshold60m),
+			writeBPSThreshold: orFloat(c.SmartLimitWriteBPSThreshold60m, c.SmartLimitBPSThreshold60m),
+			readIOPSLimit:     orInt(c.SmartLimitReadIOPSLimit60m, c.SmartLimitIOPSLimit60m),
+			writeIOPSLimit:    orInt(c.SmartLimitWriteIOPSLimit60m, c.SmartLimitIOPSLimit60m),
+			readBPSLimit:      orInt(c.SmartLimitReadBPSLimit60m, c.SmartLimitBPSLimit60m),
+			writeBPSLimit:     orInt(c.SmartLimitWriteBPSLimit60m, c.SmartLimitBPSLimit60m),
+		},
+	}
+}
+
+// window 返回指定时间窗口的平均读写速率
+func (t *IOTrend) window(name string) (readIOPS, writeIOPS, readBPS, writeBPS float64) {
+	switch name {
+	case "15m":
+		return t.ReadIOPS15m, t.WriteIOPS15m, t.ReadBPS15m, t.WriteBPS15m
+	case "30m":
+		return t.ReadIOPS30m, t.WriteIOPS30m, t.ReadBPS30m, t.WriteBPS30m
+	case "60m":
+		return t.ReadIOPS60m, t.WriteIOPS60m, t.ReadBPS60m, t.WriteBPS60m
+	}
+	return 0, 0, 0, 0
+}
+
+// latency 返回指定时间窗口的平均读写延迟（毫秒）
+func (t *IOTrend) latency(name string) (read, write float64) {
+	switch name {
+	case "15m":
+		return t.ReadLatency15m, t.WriteLatency15m
+	case "30m":
+		return t.ReadLatency30m, t.WriteLatency30m
+	case "60m":
+		return t.ReadLatency60m, t.WriteLatency60m
+	}
+	return 0, 0
+}
+
+// Percentiles 一组速率的分位数和最大值
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// WindowPercentiles 单个时间窗口内各项速率和延迟的分布
+type WindowPercentiles struct {
+	ReadIOPS  Percentiles `json:"read_iops"`
+	WriteIOPS Percentiles `json:"write_iops"`
+	ReadBPS   Percentiles `json:"read_bps"`
+	WriteBPS  Percentiles `json:"write_bps"`
+
+	ReadLatency  Percentiles `json:"read_latency"` // 毫秒
+	WriteLatency Percentiles `json:"write_latency"`
+}
+
+// mean 平均值，无数据时为0
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentiles 按最近秩法计算分位数，会对 values 排序
+func percentiles(values []float64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Float64s(values)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+	return Percentiles{P50: rank(0.5), P95: rank(0.95), P99: rank(0.99), Max: values[len(values)-1]}
+}
+
+// statistic 返回指定窗口某项速率或延迟的统计量（均值、分位数或最大值），窗口无数据时为0
+func (t *IOTrend) statistic(window, statistic, metric string) float64 {
+	if statistic == limits.StatisticMean {
+		readIOPS, writeIOPS, readBPS, writeBPS := t.window(window)
+		switch metric {
+		case limits.MetricReadIOPS:
+			return readIOPS
+		case limits.MetricWriteIOPS:
+			return writeIOPS
+		case limits.MetricReadBPS:
+			return readBPS
+		case limits.MetricWriteBPS:
+			return writeBPS
+		}
+		readLatency, writeLatency := t.latency(window)
+		switch metric {
+		case limits.MetricReadLatency:
+			return readLatency
+		case limits.MetricWriteLatency:
+			return writeLatency
+		}
+		return 0
+	}
+	wp := t.Percentiles[window]
+	if wp == nil {
+		return 0
+	}
+	var p Percentiles
+	switch metric {
+	case limits.MetricReadIOPS:
+		p = wp.ReadIOPS
+	case limits.MetricWriteIOPS:
+		p = wp.WriteIOPS
+	case limits.MetricReadBPS:
+		p = wp.ReadBPS
+	case limits.MetricWriteBPS:
+		p = wp.WriteBPS
+	case limits.MetricReadLatency:
+		p = wp.ReadLatency
+	case limits.MetricWriteLatency:
+		p = wp.WriteLatency
+	}
+	switch statistic {
+	case limits.StatisticP50:
+		return p.P50
+	case limits.StatisticP95:
+		return p.P95
+	case limits.StatisticP99:
+		return p.P99
+	case limits.StatisticMax:
+		return p.Max
+	}
+	return 0
+}
+
+// directionLabel 限速方向的描述
+func directionLabel(read, write bool) string {
+	switch {
+	case read && write:
+		return "读写"
+	case read:
+		return "读"
+	case write:
+		return "写"
+	}
+	return "无"
+}
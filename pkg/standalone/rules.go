@@ -0,0 +1,89 @@
+package standalone
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule 独立模式下的静态限速规则
+// 按容器名或镜像通配符匹配，Limits 的key与Pod注解保持一致（如 read-iops、write-bps）
+type Rule struct {
+	Name         string            `json:"name"`                    // 规则名称，仅用于日志
+	NamePattern  string            `json:"name_pattern,omitempty"`  // 容器名通配符，如 mysql-*
+	ImagePattern string            `json:"image_pattern,omitempty"` // 镜像通配符，如 *redis:*
+	Limits       map[string]string `json:"limits"`                  // 限速配置，如 {"write-iops": "300", "read-bps": "10M"}
+
+	nameRe  *regexp.Regexp
+	imageRe *regexp.Regexp
+}
+
+// RuleSet 规则集合，按顺序匹配，首个命中的规则生效
+type RuleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules 从JSON文件加载规则，路径为空时返回空规则集
+func LoadRules(file string) (*RuleSet, error) {
+	if file == "" {
+		return &RuleSet{}, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read standalone rules file %s: %v", file, err)
+	}
+	var rs RuleSet
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("failed to parse standalone rules file %s: %v", file, err)
+	}
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if r.NamePattern == "" && r.ImagePattern == "" {
+			return nil, fmt.Errorf("rule %d (%s) must set name_pattern or image_pattern", i, r.Name)
+		}
+		r.nameRe = compileGlob(r.NamePattern)
+		r.imageRe = compileGlob(r.ImagePattern)
+	}
+	return &rs, nil
+}
+
+// compileGlob 将通配符转换为完整匹配的正则，* 匹配任意字符（包括镜像路径中的 /），? 匹配单个字符
+func compileGlob(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// Match 返回首个匹配容器名和镜像的规则，未命中返回nil
+// 同时设置了 name_pattern 和 image_pattern 的规则需两者都匹配
+func (rs *RuleSet) Match(name, image string) *Rule {
+	if rs == nil {
+		return nil
+	}
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if r.nameRe != nil && !r.nameRe.MatchString(name) {
+			continue
+		}
+		if r.imageRe != nil && !r.imageRe.MatchString(image) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// Annotations 将规则限速配置转换为带前缀的注解形式，便于复用注解解析逻辑
+func (r *Rule) Annotations(prefix string) map[string]string {
+	annotations := make(map[string]string, len(r.Limits))
+	for k, v := range r.Limits {
+		annotations[prefix+"/"+k] = v
+	}
+	return annotations
+}
@@ -0,0 +1,62 @@
+package standalone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAndMatchRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.json")
+	content := `{"rules": [
+		{"name": "db", "name_pattern": "mysql-*", "limits": {"write-iops": "300"}},
+		{"name": "cache", "image_pattern": "*redis*", "limits": {"read-bps": "10M"}}
+	]}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := LoadRules(file)
+	assert.NoError(t, err)
+	assert.Len(t, rs.Rules, 2)
+
+	cases := []struct {
+		name     string
+		cname    string
+		image    string
+		expected string
+	}{
+		{"match by name", "mysql-0", "mysql:8", "db"},
+		{"match by image", "cache", "library/redis:7", "cache"},
+		{"no match", "web", "nginx:latest", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := rs.Match(tc.cname, tc.image)
+			if tc.expected == "" {
+				assert.Nil(t, r)
+				return
+			}
+			assert.NotNil(t, r)
+			assert.Equal(t, tc.expected, r.Name)
+		})
+	}
+
+	r := rs.Match("mysql-1", "mysql:8")
+	assert.Equal(t, map[string]string{"kubediskguard.io/write-iops": "300"}, r.Annotations("kubediskguard.io"))
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(file, []byte(`{"rules": [{"name": "empty"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadRules(file)
+	assert.Error(t, err)
+
+	rs, err := LoadRules("")
+	assert.NoError(t, err)
+	assert.Nil(t, rs.Match("any", "any"))
+}
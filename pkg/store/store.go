@@ -0,0 +1,225 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentPrefix 和 segmentSuffix 段文件名格式：segment-<起始时间UnixNano>.log
+const (
+	segmentPrefix = "segment-"
+	segmentSuffix = ".log"
+)
+
+// Record 一条持久化记录，Data 由调用方按 Kind 编解码
+type Record struct {
+	Time time.Time       `json:"time"`
+	Kind string          `json:"kind"`
+	Key  string          `json:"key,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Store 基于追加写段文件的嵌入式存储：每条记录为一行JSON，按时间滚动新段，
+// 压缩时整段删除已超出保留窗口的旧段，不改写已有文件
+type Store struct {
+	dir             string
+	segmentDuration time.Duration
+	mu              sync.Mutex
+	file            *os.File
+	writer          *bufio.Writer
+	segmentStart    time.Time
+}
+
+// Open 打开（不存在时创建）存储目录，segmentDuration 为单个段覆盖的时长
+func Open(dir string, segmentDuration time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory %s: %v", dir, err)
+	}
+	if segmentDuration <= 0 {
+		segmentDuration = time.Minute
+	}
+	return &Store{dir: dir, segmentDuration: segmentDuration}, nil
+}
+
+// Append 追加记录，当前段超过 segmentDuration 时滚动到新段
+func (s *Store) Append(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.file == nil || now.Sub(s.segmentStart) >= s.segmentDuration {
+		if err := s.rotate(now); err != nil {
+			return err
+		}
+	}
+	for _, rec := range records {
+		if rec.Time.IsZero() {
+			rec.Time = now
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %v", err)
+		}
+		s.writer.Write(data)
+		s.writer.WriteByte('\n')
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write segment %s: %v", s.file.Name(), err)
+	}
+	return nil
+}
+
+// rotate 关闭当前段并创建以 start 命名的新段，调用方需持有锁
+func (s *Store) rotate(start time.Time) error {
+	if err := s.closeSegment(); err != nil {
+		return err
+	}
+	name := filepath.Join(s.dir, segmentPrefix+strconv.FormatInt(start.UnixNano(), 10)+segmentSuffix)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment %s: %v", name, err)
+	}
+	s.file, s.writer, s.segmentStart = file, bufio.NewWriter(file), start
+	return nil
+}
+
+// closeSegment 刷盘并关闭当前段，调用方需持有锁
+func (s *Store) closeSegment() error {
+	if s.file == nil {
+		return nil
+	}
+	defer func() { s.file, s.writer = nil, nil }()
+	if err := s.writer.Flush(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to write segment %s: %v", s.file.Name(), err)
+	}
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to sync segment %s: %v", s.file.Name(), err)
+	}
+	return s.file.Close()
+}
+
+// segment 段文件及其起始时间
+type segment struct {
+	path  string
+	start time.Time
+}
+
+// segments 按起始时间升序列出段文件
+func (s *Store) segments() ([]segment, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list store directory %s: %v", s.dir, err)
+	}
+	var result []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, segment{path: filepath.Join(s.dir, name), start: time.Unix(0, nanos)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].start.Before(result[j].start) })
+	return result, nil
+}
+
+// Replay 按写入顺序回放 since 之后的记录；进程被杀时末尾可能留下不完整的行，跳过无法解析的行
+func (s *Store) Replay(since time.Time, fn func(Record)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer != nil {
+		if err := s.writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write segment %s: %v", s.file.Name(), err)
+		}
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		file, err := os.Open(seg.path)
+		if err != nil {
+			return fmt.Errorf("failed to open segment %s: %v", seg.path, err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		skipped := 0
+		for scanner.Scan() {
+			var rec Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				skipped++
+				continue
+			}
+			if rec.Time.Before(since) {
+				continue
+			}
+			fn(rec)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read segment %s: %v", seg.path, err)
+		}
+		if skipped > 0 {
+			log.Printf("Skipped %d corrupted records in segment %s", skipped, seg.path)
+		}
+	}
+	return nil
+}
+
+// Compact 滚动到新段并写入 checkpoint（调用方仍需保留的最新状态），然后删除结束时间早于 cutoff 的旧段。
+// 段的结束时间即下一个段的起始时间，因此仍包含 cutoff 之后记录的段会被保留
+func (s *Store) Compact(cutoff time.Time, checkpoint []Record) (int, error) {
+	s.mu.Lock()
+	now := time.Now()
+	if err := s.rotate(now); err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	s.mu.Unlock()
+	if len(checkpoint) > 0 {
+		if err := s.Append(checkpoint...); err != nil {
+			return 0, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	segments, err := s.segments()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i+1].start.After(cutoff) {
+			break
+		}
+		if err := os.Remove(segments[i].path); err != nil {
+			return removed, fmt.Errorf("failed to remove segment %s: %v", segments[i].path, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Close 刷盘并关闭当前段
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeSegment()
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSegment 写入一个指定起始时间的段，模拟上一个实例留下的文件
+func writeSegment(t *testing.T, dir string, start time.Time, lines ...string) {
+	var data []byte
+	for _, line := range lines {
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+	name := filepath.Join(dir, segmentPrefix+strconv.FormatInt(start.UnixNano(), 10)+segmentSuffix)
+	assert.NoError(t, os.WriteFile(name, data, 0644))
+}
+
+func record(t *testing.T, at time.Time, key string) string {
+	data, err := json.Marshal(Record{Time: at, Kind: "stats", Key: key, Data: json.RawMessage(`{"v":1}`)})
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestStoreAppendReplayCompact(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	// 旧实例留下的段：最早的段已完全超出窗口，末尾有被截断的行
+	writeSegment(t, dir, now.Add(-3*time.Hour), record(t, now.Add(-3*time.Hour), "expired"))
+	writeSegment(t, dir, now.Add(-90*time.Minute), record(t, now.Add(-90*time.Minute), "old"), record(t, now.Add(-30*time.Minute), "recent"), `{"time":"20`)
+
+	st, err := Open(dir, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, st.Append(Record{Kind: "decision", Key: "c1"}, Record{Kind: "stats", Key: "c2"}))
+
+	var keys []string
+	assert.NoError(t, st.Replay(now.Add(-time.Hour), func(rec Record) { keys = append(keys, rec.Key) }))
+	assert.Equal(t, []string{"recent", "c1", "c2"}, keys)
+
+	// 压缩只删除结束时间早于窗口的段，检查点写入新段
+	removed, err := st.Compact(now.Add(-time.Hour), []Record{{Kind: "status", Key: "c1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	keys = nil
+	assert.NoError(t, st.Replay(time.Time{}, func(rec Record) { keys = append(keys, rec.Kind+":"+rec.Key) }))
+	assert.Equal(t, []string{"stats:old", "stats:recent", "decision:c1", "stats:c2", "status:c1"}, keys)
+	assert.NoError(t, st.Close())
+
+	// 重新打开后数据仍在
+	st, err = Open(dir, time.Hour)
+	assert.NoError(t, err)
+	count := 0
+	assert.NoError(t, st.Replay(now.Add(-time.Hour), func(Record) { count++ }))
+	assert.Equal(t, 4, count)
+	assert.NoError(t, st.Close())
+}
@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/filter"
+	"KubeDiskGuard/pkg/limits"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Mutator 在Pod创建时把解析后的Pod级限速写成显式注解，容器启动即按注解限速，无需等待事后补丁
+type Mutator struct {
+	cfg        *config.Config
+	classes    *limits.ClassSet
+	profiles   *limits.ProfileSet
+	filters    *filter.RuleSet
+	namespaces func(namespace string) *corev1.Namespace // 按名称查询命名空间，为nil时不应用命名空间默认值和命名空间标签过滤
+}
+
+// NewMutator 创建注解注入器，filters 为nil时不过滤Pod
+func NewMutator(cfg *config.Config, classes *limits.ClassSet, profiles *limits.ProfileSet, filters *filter.RuleSet, namespaces func(namespace string) *corev1.Namespace) *Mutator {
+	return &Mutator{cfg: cfg, classes: classes, profiles: profiles, filters: filters, namespaces: namespaces}
+}
+
+// patchOperation JSONPatch 操作
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Review 按 限速等级 → 命名空间 → Pod（含引用的命名配置）解析限速，补全解析出的非0读写限速注解
+// 全局默认值由节点上的服务兜底，不写入注解；已显式设置的注解保持不变，被过滤规则或排除注解排除的Pod不注入；
+// 时间窗口和容器级注解仍由节点上的服务在下发时叠加
+func (m *Mutator) Review(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation != admissionv1.Create {
+		return resp
+	}
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{Code: http.StatusBadRequest, Message: fmt.Sprintf("failed to decode pod: %v", err)}
+		return resp
+	}
+	if req.Namespace != "" {
+		// 创建请求中Pod对象的namespace可能为空，以请求中的为准
+		pod.Namespace = req.Namespace
+	}
+	prefix := m.cfg.SmartLimitAnnotationPrefix
+	if _, done := pod.Annotations[prefix+"/"+annotationkeys.InjectedFromAnnotationKey]; done {
+		return resp
+	}
+
+	var ns *corev1.Namespace
+	if m.namespaces != nil {
+		ns = m.namespaces(pod.Namespace)
+	}
+	if !m.included(pod, ns) {
+		return resp
+	}
+	var nsAnnotations map[string]string
+	if ns != nil {
+		nsAnnotations = ns.Annotations
+	}
+	res := limits.ResolvePod(limits.Limits{}, m.classes, m.profiles, nsAnnotations, pod, prefix)
+
+	// 只记录实际改变了限速值的层
+	var sources []string
+	for i := 1; i < len(res.Chain); i++ {
+		layer := res.Chain[i]
+		if layer.Limits == res.Chain[i-1].Limits {
+			continue
+		}
+		if layer.Name == "" {
+			sources = append(sources, layer.Source)
+			continue
+		}
+		sources = append(sources, layer.Source+":"+layer.Name)
+	}
+	values := map[string]int{
+		annotationkeys.ReadIopsAnnotationKey:  res.Effective.ReadIOPS,
+		annotationkeys.WriteIopsAnnotationKey: res.Effective.WriteIOPS,
+		annotationkeys.ReadBpsAnnotationKey:   res.Effective.ReadBPS,
+		annotationkeys.WriteBpsAnnotationKey:  res.Effective.WriteBPS,
+	}
+
+	var patch []patchOperation
+	for _, key := range []string{
+		annotationkeys.ReadIopsAnnotationKey, annotationkeys.WriteIopsAnnotationKey,
+		annotationkeys.ReadBpsAnnotationKey, annotationkeys.WriteBpsAnnotationKey,
+	} {
+		full := prefix + "/" + key
+		if _, exists := pod.Annotations[full]; exists || values[key] == 0 {
+			continue
+		}
+		patch = append(patch, patchOperation{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(full), Value: strconv.Itoa(values[key])})
+	}
+	if len(patch) == 0 {
+		return resp
+	}
+	injectedFrom := strings.Join(sources, ",")
+	patch = append(patch, patchOperation{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(prefix+"/"+annotationkeys.InjectedFromAnnotationKey), Value: injectedFrom})
+	if pod.Annotations == nil {
+		patch = append([]patchOperation{{Op: "add", Path: "/metadata/annotations", Value: map[string]string{}}}, patch...)
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		resp.Result = &metav1.Status{Code: http.StatusInternalServerError, Message: fmt.Sprintf("failed to encode patch: %v", err)}
+		return resp
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	resp.Patch, resp.PatchType = data, &patchType
+	log.Printf("Injected limits into pod %s/%s: riops=%d wiops=%d rbps=%d wbps=%d (from %s)",
+		req.Namespace, podName(req, pod), res.Effective.ReadIOPS, res.Effective.WriteIOPS, res.Effective.ReadBPS, res.Effective.WriteBPS, injectedFrom)
+	return resp
+}
+
+// included 判断Pod是否需要注入：过滤规则和容器排除注解与节点上的服务一致，所有容器均被排除时不注入
+func (m *Mutator) included(pod corev1.Pod, ns *corev1.Namespace) bool {
+	target := filter.Target{Namespace: pod.Namespace, PodLabels: pod.Labels}
+	if ns != nil {
+		target.NamespaceLabels = ns.Labels
+	}
+	if result, decided := m.filters.EvaluatePod(target); decided && !result.Included() {
+		return false
+	}
+	prefix := m.cfg.SmartLimitAnnotationPrefix
+	for _, c := range pod.Spec.Containers {
+		key := prefix + "/" + annotationkeys.ContainerKey(c.Name, annotationkeys.ExcludeAnnotationKey)
+		if excluded, err := strconv.ParseBool(pod.Annotations[key]); err == nil && excluded {
+			continue
+		}
+		target.ContainerName, target.Image = c.Name, c.Image
+		if m.filters.EvaluateContainer(target).Included() {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP 处理 AdmissionReview（admission.k8s.io/v1）
+func (m *Mutator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveReview(w, r, m.Review)
+}
+
+// escapeJSONPointer 按 RFC 6901 转义注解key中的 ~ 和 /
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
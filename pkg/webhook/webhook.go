@@ -0,0 +1,369 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"KubeDiskGuard/pkg/annotationkeys"
+	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/filter"
+	"KubeDiskGuard/pkg/kubeclient"
+	"KubeDiskGuard/pkg/limits"
+	"KubeDiskGuard/pkg/schedule"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	iopsKeys = []string{annotationkeys.IopsAnnotationKey, annotationkeys.ReadIopsAnnotationKey, annotationkeys.WriteIopsAnnotationKey}
+	bpsKeys  = []string{annotationkeys.BpsAnnotationKey, annotationkeys.ReadBpsAnnotationKey, annotationkeys.WriteBpsAnnotationKey}
+	// 智能限速写入的状态注解，由本服务维护，不做校验
+	statusKeys = []string{
+		annotationkeys.TriggeredByAnnotationKey, annotationkeys.TriggerReasonAnnotationKey,
+		annotationkeys.LimitRemovedAnnotationKey, annotationkeys.RemovedAtAnnotationKey, annotationkeys.RemovedReasonAnnotationKey,
+		annotationkeys.InjectedFromAnnotationKey,
+	}
+	legacyIopsKeys = []string{annotationkeys.LegacyIopsAnnotationKey, annotationkeys.LegacyReadIopsAnnotationKey, annotationkeys.LegacyWriteIopsAnnotationKey}
+	legacyBpsKeys  = []string{annotationkeys.LegacyBpsAnnotationKey, annotationkeys.LegacyReadBpsAnnotationKey, annotationkeys.LegacyWriteBpsAnnotationKey}
+)
+
+// Validator 校验Pod上的限速注解，解析逻辑与限速下发时一致
+type Validator struct {
+	prefix   string
+	maxIOPS  int // 0表示不校验上限
+	maxBPS   int
+	reject   bool
+	profiles *limits.ProfileSet
+}
+
+// NewValidator 创建注解校验器，profiles 用于校验 profile 注解引用的配置是否存在
+func NewValidator(cfg *config.Config, profiles *limits.ProfileSet) *Validator {
+	return &Validator{
+		prefix:   cfg.SmartLimitAnnotationPrefix,
+		maxIOPS:  cfg.MaxIOPSLimit,
+		maxBPS:   cfg.MaxBPSLimit,
+		reject:   cfg.WebhookRejectInvalid,
+		profiles: profiles,
+	}
+}
+
+// Violation 一个非法的注解值（含超过上限的值）
+type Violation struct {
+	Key     string
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Key + ": " + v.Message
+}
+
+// Validate 校验注解，返回非法值和未知注解key，均按key排序
+func (v *Validator) Validate(annotations map[string]string) (violations []Violation, unknown []string) {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		known, err := v.validateKey(key, annotations[key])
+		if !known {
+			unknown = append(unknown, key)
+			continue
+		}
+		if err != nil {
+			violations = append(violations, Violation{Key: key, Message: err.Error()})
+		}
+	}
+	return violations, unknown
+}
+
+// validateKey 校验单个注解，known 为false表示是本服务前缀下无法识别的key（不带前缀的无关注解视为已知）
+func (v *Validator) validateKey(key, value string) (known bool, err error) {
+	switch {
+	case contains(legacyIopsKeys, key):
+		return true, v.checkIOPS(value)
+	case contains(legacyBpsKeys, key):
+		return true, v.checkBPS(value)
+	case !strings.HasPrefix(key, v.prefix+"/"):
+		return true, nil
+	}
+	name := strings.TrimPrefix(key, v.prefix+"/")
+
+	if rest, ok := strings.CutPrefix(name, annotationkeys.ContainerAnnotationKeyPrefix); ok {
+		container, subKey, found := strings.Cut(rest, ".")
+		if !found || container == "" {
+			return false, nil
+		}
+		if subKey == annotationkeys.ExcludeAnnotationKey {
+			return true, checkBool(value)
+		}
+		if subKey == annotationkeys.ProfileAnnotationKey {
+			return true, v.checkProfile(value)
+		}
+		return v.validateLimitKey(subKey, value)
+	}
+	if rest, ok := strings.CutPrefix(name, annotationkeys.VolumeAnnotationKeyPrefix); ok {
+		volume, subKey, found := strings.Cut(rest, ".")
+		if !found || volume == "" || !(contains(iopsKeys, subKey) || contains(bpsKeys, subKey)) {
+			return false, nil
+		}
+		return v.validateLimitKey(subKey, value)
+	}
+
+	switch name {
+	case annotationkeys.ProfileAnnotationKey:
+		return true, v.checkProfile(value)
+	case annotationkeys.ScheduleAnnotationKey:
+		return true, v.checkSchedule(value)
+	case annotationkeys.BudgetWeightAnnotationKey:
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight <= 0 {
+			return true, fmt.Errorf("invalid weight %q, expected a positive number", value)
+		}
+		return true, nil
+	}
+	return v.validateLimitKey(name, value)
+}
+
+// validateLimitKey 校验限速注解（Pod级和容器级共用）
+func (v *Validator) validateLimitKey(name, value string) (bool, error) {
+	switch {
+	case contains(iopsKeys, name):
+		return true, v.checkIOPS(value)
+	case contains(bpsKeys, name):
+		return true, v.checkBPS(value)
+	case name == annotationkeys.RemovedAnnotationKey:
+		return true, checkBool(value)
+	case contains(statusKeys, name):
+		return true, nil
+	}
+	return false, nil
+}
+
+func (v *Validator) checkIOPS(value string) error {
+	iops, err := limits.ParseIOPS(value)
+	if err != nil {
+		return err
+	}
+	if v.maxIOPS > 0 && iops > v.maxIOPS {
+		return fmt.Errorf("iops %d exceeds the maximum %d", iops, v.maxIOPS)
+	}
+	return nil
+}
+
+func (v *Validator) checkBPS(value string) error {
+	bps, err := limits.ParseBPS(value)
+	if err != nil {
+		return err
+	}
+	if v.maxBPS > 0 && bps > v.maxBPS {
+		return fmt.Errorf("bps %d exceeds the maximum %d", bps, v.maxBPS)
+	}
+	return nil
+}
+
+func (v *Validator) checkProfile(name string) error {
+	if v.profiles.Get(name) == nil {
+		return fmt.Errorf("unknown limit profile %q", name)
+	}
+	return nil
+}
+
+// checkSchedule 校验时间表格式，以及各窗口中的限速值和引用的命名配置
+func (v *Validator) checkSchedule(value string) error {
+	sched, err := schedule.Parse(value)
+	if err != nil {
+		return err
+	}
+	for i := range sched.Windows {
+		w := &sched.Windows[i]
+		violations, unknown := v.Validate(w.Annotations(v.prefix))
+		if len(unknown) > 0 {
+			return fmt.Errorf("window %s has unknown limit keys %s", w.Name, strings.Join(unknown, ", "))
+		}
+		if len(violations) > 0 {
+			return fmt.Errorf("window %s: %s", w.Name, violations[0])
+		}
+	}
+	return nil
+}
+
+func checkBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("invalid boolean %q", value)
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Review 处理一次 AdmissionReview 请求
+// 更新Pod时只有新增或修改的注解不合法才拒绝，已存在的问题只给出警告，避免阻塞与注解无关的更新
+func (v *Validator) Review(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	var pod, oldPod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{Code: http.StatusBadRequest, Message: fmt.Sprintf("failed to decode pod: %v", err)}
+		return resp
+	}
+	if req.Operation == admissionv1.Update && len(req.OldObject.Raw) > 0 {
+		if err := json.Unmarshal(req.OldObject.Raw, &oldPod); err != nil {
+			resp.Allowed = false
+			resp.Result = &metav1.Status{Code: http.StatusBadRequest, Message: fmt.Sprintf("failed to decode old pod: %v", err)}
+			return resp
+		}
+	}
+
+	violations, unknown := v.Validate(pod.Annotations)
+	var rejected []string
+	for _, violation := range violations {
+		old, existed := oldPod.Annotations[violation.Key]
+		if v.reject && (!existed || old != pod.Annotations[violation.Key]) {
+			rejected = append(rejected, violation.String())
+			continue
+		}
+		resp.Warnings = append(resp.Warnings, violation.String())
+	}
+	for _, key := range unknown {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("%s: unknown annotation, ignored by KubeDiskGuard", key))
+	}
+	if len(rejected) > 0 {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: "invalid KubeDiskGuard annotations: " + strings.Join(rejected, "; "),
+		}
+		log.Printf("Rejected pod %s/%s: %s", req.Namespace, podName(req, pod), strings.Join(rejected, "; "))
+	}
+	return resp
+}
+
+// podName 创建Pod时 generateName 生成的名称尚未确定，使用请求中的名称或 generateName
+func podName(req *admissionv1.AdmissionRequest, pod corev1.Pod) string {
+	if req.Name != "" {
+		return req.Name
+	}
+	return pod.GenerateName
+}
+
+// ServeHTTP 处理 AdmissionReview（admission.k8s.io/v1）
+func (v *Validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveReview(w, r, v.Review)
+}
+
+// serveReview 解码 AdmissionReview，调用 review 生成响应后写回
+func serveReview(w http.ResponseWriter, r *http.Request, review func(*admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 3<<20))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var ar admissionv1.AdmissionReview
+	if err := json.Unmarshal(body, &ar); err != nil || ar.Request == nil {
+		http.Error(w, "invalid AdmissionReview request", http.StatusBadRequest)
+		return
+	}
+	ar.Response = review(ar.Request)
+	ar.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ar); err != nil {
+		log.Printf("[WEBHOOK] Error encoding AdmissionReview response: %v", err)
+	}
+}
+
+// Run 以HTTPS运行 webhook，阻塞直到 ctx 取消：/validate 校验注解，/mutate 在Pod创建时注入解析后的限速注解
+func Run(ctx context.Context, cfg *config.Config) error {
+	profiles, err := limits.LoadProfiles(cfg.LimitProfilesFile)
+	if err != nil {
+		return err
+	}
+	classes, err := limits.LoadClasses(cfg.LimitClassesFile)
+	if err != nil {
+		return err
+	}
+	// 与节点上的服务使用相同的过滤规则，被排除的Pod不注入
+	filters := filter.LegacyRules(cfg.ExcludeKeywords, cfg.ExcludeNamespaces, cfg.ExcludeLabelSelector)
+	if cfg.FilterRulesFile != "" {
+		if filters, err = filter.LoadRules(cfg.FilterRulesFile); err != nil {
+			return err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/validate", NewValidator(cfg, profiles))
+	mux.Handle("/mutate", NewMutator(cfg, classes, profiles, filters, watchNamespaces(ctx, cfg)))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	server := &http.Server{Addr: cfg.WebhookAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Admission webhook listening on %s (reject invalid: %v)", cfg.WebhookAddr, cfg.WebhookRejectInvalid)
+		errCh <- server.ListenAndServeTLS(cfg.WebhookCertFile, cfg.WebhookKeyFile)
+	}()
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("webhook server error: %v", err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// watchNamespaces 监听命名空间，返回按名称查询命名空间的函数；无法访问API Server时返回nil，注入时不应用命名空间默认值和命名空间标签过滤
+func watchNamespaces(ctx context.Context, cfg *config.Config) func(namespace string) *corev1.Namespace {
+	// webhook 不按节点列Pod，NODE_NAME 未设置时使用占位值
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		nodeName = "webhook"
+	}
+	client, err := kubeclient.NewKubeClient(nodeName, cfg.KubeConfigPath)
+	if err != nil {
+		log.Printf("Namespace default limits disabled for injection: %v", err)
+		return nil
+	}
+	lw, err := client.NamespaceListWatcher()
+	if err != nil {
+		log.Printf("Namespace default limits disabled for injection: %v", err)
+		return nil
+	}
+	informer := cache.NewSharedIndexInformer(lw, &corev1.Namespace{}, 0, cache.Indexers{})
+	go informer.Run(ctx.Done())
+	syncCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		log.Printf("Warning: namespace cache not synced, namespace defaults apply once synced")
+	}
+	return func(namespace string) *corev1.Namespace {
+		obj, exists, err := informer.GetStore().GetByKey(namespace)
+		if err != nil || !exists {
+			return nil
+		}
+		ns, _ := obj.(*corev1.Namespace)
+		return ns
+	}
+}
@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"KubeDiskGuard/pkg/config"
+	"KubeDiskGuard/pkg/filter"
+	"KubeDiskGuard/pkg/limits"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func podRaw(t *testing.T, annotations map[string]string) runtime.RawExtension {
+	raw, err := json.Marshal(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Annotations: annotations},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}},
+	})
+	assert.NoError(t, err)
+	return runtime.RawExtension{Raw: raw}
+}
+
+func review(t *testing.T, server *httptest.Server, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  req,
+	})
+	assert.NoError(t, err)
+	resp, err := http.Post(server.URL+"/validate", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out admissionv1.AdmissionReview
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, req.UID, out.Response.UID)
+	return out.Response
+}
+
+func TestValidatingWebhook(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.MaxIOPSLimit = 2000
+	cfg.MaxBPSLimit = 100 * 1024 * 1024
+	prefix := cfg.SmartLimitAnnotationPrefix
+	mux := http.NewServeMux()
+	mux.Handle("/validate", NewValidator(cfg, &limits.ProfileSet{}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// 合法注解（含单位、容器级注解、遗留注解）直接放行
+	resp := review(t, server, &admissionv1.AdmissionRequest{
+		UID: types.UID("ok"), Operation: admissionv1.Create,
+		Object: podRaw(t, map[string]string{
+			prefix + "/write-iops":                 "800",
+			prefix + "/read-bps":                   "50M",
+			prefix + "/container.app.exclude":      "true",
+			"nvme-iops":                            "100",
+			"app.kubernetes.io/name":               "demo",
+			prefix + "/container.app.triggered-by": "15m",
+			prefix + "/container.loader.write-bps": "0",
+			prefix + "/budget-weight":              "2",
+		}),
+	})
+	assert.True(t, resp.Allowed)
+	assert.Empty(t, resp.Warnings)
+
+	// 非法值和超过上限的值被拒绝，未知key只警告
+	resp = review(t, server, &admissionv1.AdmissionRequest{
+		UID: types.UID("bad"), Operation: admissionv1.Create,
+		Object: podRaw(t, map[string]string{
+			prefix + "/write-bps":  "10 MB/s",
+			prefix + "/read-iops":  "5000",
+			prefix + "/profile":    "missing",
+			prefix + "/write-ipos": "100",
+		}),
+	})
+	assert.False(t, resp.Allowed)
+	assert.Contains(t, resp.Result.Message, prefix+"/write-bps")
+	assert.Contains(t, resp.Result.Message, "exceeds the maximum 2000")
+	assert.Contains(t, resp.Result.Message, `unknown limit profile "missing"`)
+	assert.Equal(t, []string{prefix + "/write-ipos: unknown annotation, ignored by KubeDiskGuard"}, resp.Warnings)
+
+	// 更新时已存在的非法注解只警告，新增或修改的非法注解仍被拒绝
+	old := podRaw(t, map[string]string{prefix + "/write-bps": "10 MB/s"})
+	resp = review(t, server, &admissionv1.AdmissionRequest{
+		UID: types.UID("update"), Operation: admissionv1.Update, OldObject: old,
+		Object: podRaw(t, map[string]string{prefix + "/write-bps": "10 MB/s", "team": "a"}),
+	})
+	assert.True(t, resp.Allowed)
+	assert.Len(t, resp.Warnings, 1)
+	resp = review(t, server, &admissionv1.AdmissionRequest{
+		UID: types.UID("update-bad"), Operation: admissionv1.Update, OldObject: old,
+		Object: podRaw(t, map[string]string{prefix + "/write-bps": "10 MB/s", prefix + "/schedule": `{"windows": [{"name": "night", "start": "22:00", "end": "06:00", "limits": {"write-iops": "abc"}}]}`}),
+	})
+	assert.False(t, resp.Allowed)
+	assert.Contains(t, resp.Result.Message, "window night")
+
+	// 只警告模式下放行
+	cfg.WebhookRejectInvalid = false
+	mux = http.NewServeMux()
+	mux.Handle("/validate", NewValidator(cfg, &limits.ProfileSet{}))
+	warnServer := httptest.NewServer(mux)
+	defer warnServer.Close()
+	resp = review(t, warnServer, &admissionv1.AdmissionRequest{
+		UID: types.UID("warn"), Operation: admissionv1.Create,
+		Object: podRaw(t, map[string]string{prefix + "/write-bps": "10 MB/s"}),
+	})
+	assert.True(t, resp.Allowed)
+	assert.Len(t, resp.Warnings, 1)
+}
+
+func TestValidateContainerProfile(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	prefix := cfg.SmartLimitAnnotationPrefix
+	file := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(file, []byte(`{"profiles": [{"name": "batch", "write_iops": 300}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := limits.LoadProfiles(file)
+	assert.NoError(t, err)
+	v := NewValidator(cfg, profiles)
+
+	// 容器级 profile 与Pod级一样校验引用的配置是否存在
+	violations, unknown := v.Validate(map[string]string{prefix + "/container.app.profile": "batch"})
+	assert.Empty(t, violations)
+	assert.Empty(t, unknown)
+	violations, unknown = v.Validate(map[string]string{prefix + "/container.app.profile": "bacth"})
+	assert.Empty(t, unknown)
+	assert.Equal(t, []Violation{{Key: prefix + "/container.app.profile", Message: `unknown limit profile "bacth"`}}, violations)
+}
+
+func TestMutatingWebhook(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.ContainerReadIOPSLimit = 500
+	cfg.ContainerWriteIOPSLimit = 500
+	prefix := cfg.SmartLimitAnnotationPrefix
+	classes := &limits.ClassSet{Classes: []limits.Class{
+		{Name: "best-effort", QOSClasses: []string{"BestEffort"}, Limits: map[string]string{"write-bps": "20M"}},
+	}}
+	namespaces := func(namespace string) *corev1.Namespace {
+		if namespace == "batch" {
+			return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Annotations: map[string]string{prefix + "/write-iops": "200"}}}
+		}
+		return nil
+	}
+	filters := filter.LegacyRules(nil, []string{"kube-system"}, "")
+	mux := http.NewServeMux()
+	mux.Handle("/mutate", NewMutator(cfg, classes, nil, filters, namespaces))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	post := func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		body, err := json.Marshal(admissionv1.AdmissionReview{Request: req})
+		assert.NoError(t, err)
+		resp, err := http.Post(server.URL+"/mutate", "application/json", bytes.NewReader(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var out admissionv1.AdmissionReview
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out.Response
+	}
+
+	// 显式注解保持不变，其余项按 限速等级 → 命名空间 → Pod 解析后补全非0值，全局默认值不写入
+	resp := post(&admissionv1.AdmissionRequest{
+		UID: types.UID("create"), Operation: admissionv1.Create, Namespace: "batch",
+		Object: podRaw(t, map[string]string{prefix + "/read-iops": "300"}),
+	})
+	assert.True(t, resp.Allowed)
+	assert.Equal(t, admissionv1.PatchTypeJSONPatch, *resp.PatchType)
+	var patch []patchOperation
+	assert.NoError(t, json.Unmarshal(resp.Patch, &patch))
+	values := map[string]interface{}{}
+	for _, op := range patch {
+		assert.Equal(t, "add", op.Op)
+		values[op.Path] = op.Value
+	}
+	assert.Equal(t, map[string]interface{}{
+		"/metadata/annotations/kubediskguard.io~1write-iops":    "200",
+		"/metadata/annotations/kubediskguard.io~1write-bps":     "20971520",
+		"/metadata/annotations/kubediskguard.io~1injected-from": "class:best-effort,namespace:batch,pod:app",
+	}, values)
+
+	// 没有注解的Pod先创建 annotations 对象；更新请求不做修改
+	resp = post(&admissionv1.AdmissionRequest{
+		UID: types.UID("empty"), Operation: admissionv1.Create, Namespace: "default", Object: podRaw(t, nil),
+	})
+	assert.NoError(t, json.Unmarshal(resp.Patch, &patch))
+	assert.Equal(t, "/metadata/annotations", patch[0].Path)
+	assert.Len(t, patch, 3)
+	resp = post(&admissionv1.AdmissionRequest{
+		UID: types.UID("update"), Operation: admissionv1.Update, Namespace: "default", Object: podRaw(t, nil),
+	})
+	assert.True(t, resp.Allowed)
+	assert.Nil(t, resp.Patch)
+
+	// 没有需要补全的非0值、被过滤规则排除、所有容器被排除注解排除时均不注入
+	for _, req := range []*admissionv1.AdmissionRequest{
+		{UID: types.UID("explicit"), Namespace: "default", Object: podRaw(t, map[string]string{prefix + "/write-bps": "10M"})},
+		{UID: types.UID("filtered"), Namespace: "kube-system", Object: podRaw(t, nil)},
+		{UID: types.UID("excluded"), Namespace: "batch", Object: podRaw(t, map[string]string{prefix + "/container.app.exclude": "true"})},
+	} {
+		req.Operation = admissionv1.Create
+		resp = post(req)
+		assert.True(t, resp.Allowed)
+		assert.Nil(t, resp.Patch, string(req.UID))
+	}
+}